@@ -15,44 +15,92 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 
+	"kuckuc/internal/database"
 	"kuckuc/internal/handlers"
+	"kuckuc/internal/mail"
 	"kuckuc/internal/middleware"
 	"kuckuc/internal/services"
+	"kuckuc/internal/storage"
 )
 
 func main() {
 	// Database connection
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432"
-	}
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		dbPort,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := database.Open()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
 	// Initialize services
-	authService := services.NewAuthService(db)
-	propertyService := services.NewPropertyService(db)
-	fileService := services.NewFileService(os.Getenv("UPLOAD_DIR"))
+	attemptStore, err := services.NewAttemptStore(os.Getenv("LOGIN_THROTTLE_STORE"), db)
+	if err != nil {
+		log.Fatalf("Failed to initialize login throttling: %v", err)
+	}
+	keys, err := services.LoadKeySet(os.Getenv("JWT_KEYS_DIR"))
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	go keys.Watch(context.Background(), time.Minute)
+	authService := services.NewAuthService(db, keys, services.NewLoginLimiter(attemptStore))
+	mailer, err := mail.New(mail.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	accountService := services.NewAccountService(db, mailer, appURL)
+	var oidcService *services.OIDCService
+	if oidcConfig := services.OIDCConfigFromEnv(); oidcConfig.Enabled() {
+		oidcService, err = services.NewOIDCService(db, authService, oidcConfig)
+		if err != nil {
+			log.Fatalf("Failed to initialize single sign-on: %v", err)
+		}
+	}
+	apiKeyService := services.NewAPIKeyService(db)
+	geocodingService := services.NewGeocodingService(db)
+	propertyService := services.NewPropertyService(db, geocodingService)
+	fileURLSecret := os.Getenv("FILE_URL_SECRET")
+	if fileURLSecret == "" {
+		log.Fatal("FILE_URL_SECRET must be set")
+	}
+	fileStorage, err := storage.New(storage.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+	fileService := services.NewFileService(fileStorage, fileURLSecret)
 	exportService := services.NewExportService(propertyService, fileService)
+	retention, err := services.RetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid retention period: %v", err)
+	}
+	retentionService := services.NewRetentionService(db, fileService, retention)
+	go retentionService.Run(context.Background(), time.Hour)
+	exportJobConfig, err := services.ExportJobConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid export settings: %v", err)
+	}
+	exportJobService := services.NewExportJobService(db, exportService, fileService, exportJobConfig)
+	// Stopped on shutdown, so running exports are queued again for the
+	// next server
+	exportCtx, stopExports := context.WithCancel(context.Background())
+	exportsStopped := make(chan struct{})
+	go func() {
+		exportJobService.Run(exportCtx, time.Minute)
+		close(exportsStopped)
+	}()
 
 	// Initialize handlers
 	authHandlers := handlers.NewAuthHandlers(authService)
-	propertyHandlers := handlers.NewPropertyHandlers(propertyService, exportService)
+	propertyHandlers := handlers.NewPropertyHandlers(propertyService)
 	fileHandlers := handlers.NewFileHandlers(fileService, propertyService)
+	accountHandlers := handlers.NewAccountHandlers(accountService)
+	oidcHandlers := handlers.NewOIDCHandlers(oidcService, appURL)
+	userHandlers := handlers.NewUserHandlers(authService, accountService)
+	apiKeyHandlers := handlers.NewAPIKeyHandlers(apiKeyService)
+	archiveHandlers := handlers.NewArchiveHandlers(propertyService, retentionService)
+	exportHandlers := handlers.NewExportHandlers(exportJobService, fileService)
 
 	// Initialize router
 	router := gin.Default()
@@ -72,8 +120,11 @@ func main() {
 		)
 	}))
 
-	// Serve static files
-	router.Static("/uploads", "./uploads")
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", authHandlers.JWKS)
+
+	// Serve public files; private documents go through signed download URLs
+	router.GET("/uploads/*filepath", fileHandlers.ServePublicFile)
 
 	// CORS middleware
 	allowedOrigins := strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
@@ -83,8 +134,8 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -92,14 +143,30 @@ func main() {
 	// Setup routes
 	api := router.Group("/api")
 	{
-		// Public routes
-		api.GET("/properties", propertyHandlers.GetProperties)
-		api.GET("/properties/:id", propertyHandlers.GetProperty)
+		// Public routes. Visitors only see published and reserved
+		// properties, signed-in users all of them
+		optionalAuth := middleware.OptionalAuth(authService, apiKeyService)
+		api.GET("/properties", optionalAuth, propertyHandlers.GetProperties)
+		api.GET("/properties/:id", optionalAuth, propertyHandlers.GetProperty)
+		api.GET("/files/:fileId/download", fileHandlers.DownloadFile)
+		api.GET("/exports/:id/download", exportHandlers.DownloadExport)
 
 		// Auth routes
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", authHandlers.Login)
+			auth.POST("/refresh", authHandlers.Refresh)
+			auth.POST("/logout", authHandlers.Logout)
+			auth.POST("/2fa/verify", authHandlers.VerifyLogin)
+			auth.POST("/2fa/enroll", authHandlers.EnrollTwoFactor)
+			auth.POST("/forgot-password", accountHandlers.ForgotPassword)
+			auth.POST("/reset-password", accountHandlers.ResetPassword)
+			auth.POST("/verify-email", accountHandlers.VerifyEmail)
+			auth.POST("/verify-email/resend", accountHandlers.ResendVerification)
+			auth.GET("/oidc", oidcHandlers.GetOIDCConfig)
+			auth.GET("/oidc/login", oidcHandlers.StartLogin)
+			auth.GET("/oidc/callback", oidcHandlers.Callback)
+			auth.POST("/oidc/exchange", oidcHandlers.Exchange)
 		}
 
 		// Protected routes
@@ -107,17 +174,73 @@ func main() {
 		protected.Use(middleware.AuthRequired(authService))
 		{
 			protected.GET("/auth/me", authHandlers.GetCurrentUser)
+			protected.GET("/auth/sessions", authHandlers.ListSessions)
+			protected.POST("/auth/logout-all", authHandlers.LogoutAll)
+			protected.GET("/auth/2fa", authHandlers.GetTwoFactorStatus)
+			protected.POST("/auth/2fa/setup", authHandlers.SetupTwoFactor)
+			protected.POST("/auth/2fa/enable", authHandlers.EnableTwoFactor)
+			protected.POST("/auth/2fa/recovery-codes", authHandlers.RegenerateRecoveryCodes)
+			protected.POST("/auth/2fa/disable", authHandlers.DisableTwoFactor)
+
+			// User management
+			admin := protected.Group("/admin", middleware.RequirePermission(services.PermUsersManage))
+			admin.GET("/users", userHandlers.ListUsers)
+			admin.POST("/users", userHandlers.CreateUser)
+			admin.PATCH("/users/:id", userHandlers.UpdateUser)
+			admin.PUT("/users/:id/password", userHandlers.ResetPassword)
+			admin.DELETE("/users/:id", userHandlers.DeleteUser)
+			admin.DELETE("/users/:id/two-factor", userHandlers.ResetTwoFactor)
+			admin.POST("/users/:id/verification", userHandlers.SendVerification)
+			admin.GET("/lockouts", userHandlers.ListLockouts)
+			admin.DELETE("/lockouts", userHandlers.ClearLockout)
+			admin.GET("/login-attempts", userHandlers.ListFailedLogins)
+			admin.GET("/api-keys", apiKeyHandlers.ListAPIKeys)
+			admin.POST("/api-keys", apiKeyHandlers.CreateAPIKey)
+			admin.DELETE("/api-keys/:id", apiKeyHandlers.RevokeAPIKey)
+			admin.GET("/audit-log", archiveHandlers.ListAuditLog)
+		}
+
+		// Property and file routes also accept API keys with the route's
+		// scope. Ownership of individual properties is checked in
+		// PropertyService.
+		integration := api.Group("/")
+		integration.Use(middleware.AuthOrAPIKey(authService, apiKeyService))
+		{
+			read := middleware.RequireScope(services.ScopePropertiesRead)
+			write := middleware.RequireScope(services.ScopePropertiesWrite)
+			upload := middleware.RequireScope(services.ScopeFilesUpload)
+			export := middleware.RequireScope(services.ScopePropertiesExport)
+			canEdit := middleware.RequirePermission(services.PermPropertiesEditOwn)
 
 			// Property routes
-			protected.POST("/properties", propertyHandlers.CreateProperty)
-			protected.PUT("/properties/:id", propertyHandlers.UpdateProperty)
-			protected.POST("/properties/export", propertyHandlers.ExportProperties)
-			protected.PUT("/properties/:id/status", propertyHandlers.UpdatePropertyStatus)
+			integration.POST("/properties", write, middleware.RequirePermission(services.PermPropertiesCreate), propertyHandlers.CreateProperty)
+			integration.PUT("/properties/:id", write, canEdit, propertyHandlers.UpdateProperty)
+			integration.PATCH("/properties/:id", write, canEdit, propertyHandlers.PatchProperty)
+			integration.DELETE("/properties/:id", write, canEdit, archiveHandlers.DeleteProperty)
+			integration.GET("/properties/deleted", write, canEdit, archiveHandlers.ListDeletedProperties)
+			integration.POST("/properties/:id/undelete", write, canEdit, archiveHandlers.RestoreDeletedProperty)
+			integration.POST("/properties/import", write, canEdit, propertyHandlers.ImportProperties)
+			integration.POST("/properties/export", export, middleware.RequirePermission(services.PermPropertiesExport), exportHandlers.ExportProperties)
+			integration.PUT("/properties/:id/status", write, canEdit, propertyHandlers.UpdatePropertyStatus)
+			integration.POST("/properties/:id/transitions", write, canEdit, propertyHandlers.TransitionProperty)
+			integration.GET("/properties/:id/history", read, middleware.RequirePermission(services.PermPropertiesRead), propertyHandlers.GetPropertyHistory)
+			integration.GET("/properties/:id/history/:historyId/snapshot", read, middleware.RequirePermission(services.PermPropertiesRead), propertyHandlers.PreviewRestore)
+			integration.POST("/properties/:id/history/:historyId/restore", write, canEdit, propertyHandlers.RestoreProperty)
+			integration.PUT("/properties/:id/assignee", write, middleware.RequirePermission(services.PermPropertiesAssign), propertyHandlers.AssignProperty)
 
 			// File routes
-			protected.POST("/properties/:id/files", fileHandlers.UploadFile)
-			protected.DELETE("/properties/:id/files/:fileId", fileHandlers.DeleteFile)
-			protected.PUT("/properties/:id/files/:fileId/visibility", fileHandlers.UpdateFileVisibility)
+			integration.POST("/properties/:id/files", upload, canEdit, fileHandlers.UploadFile)
+			integration.GET("/properties/:id/files/:fileId/url", read, middleware.RequirePermission(services.PermPropertiesRead), fileHandlers.GetFileURL)
+			integration.DELETE("/properties/:id/files/:fileId", upload, canEdit, fileHandlers.DeleteFile)
+			integration.PUT("/properties/:id/files/:fileId/visibility", upload, canEdit, fileHandlers.UpdateFileVisibility)
+			integration.GET("/properties/:id/files/deleted", upload, canEdit, archiveHandlers.ListDeletedFiles)
+			integration.POST("/properties/:id/files/:fileId/restore", upload, canEdit, archiveHandlers.RestoreFile)
+
+			// Export routes
+			canExport := middleware.RequirePermission(services.PermPropertiesExport)
+			integration.GET("/exports", export, canExport, exportHandlers.ListExports)
+			integration.GET("/exports/:id", export, canExport, exportHandlers.GetExport)
+			integration.POST("/exports/:id/cancel", export, canExport, exportHandlers.CancelExport)
 		}
 	}
 
@@ -152,5 +275,15 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	stopExports()
+	select {
+	case <-exportsStopped:
+	case <-ctx.Done():
+		log.Println("Exports did not stop in time")
+	}
+	if err := accountService.Wait(ctx); err != nil {
+		log.Println("Emails still being sent were dropped")
+	}
+
 	log.Println("Server exiting")
 }
@@ -0,0 +1,275 @@
+// backend/cmd/kuckuc-admin/main.go
+
+// Command kuckuc-admin manages users and JWT signing keys from the command
+// line:
+//
+//	kuckuc-admin users list
+//	kuckuc-admin users create -email admin@kuckuc.rs -role admin
+//	kuckuc-admin users set-role agent@kuckuc.rs senior_agent
+//	kuckuc-admin users disable agent@kuckuc.rs
+//	kuckuc-admin users enable agent@kuckuc.rs
+//	kuckuc-admin users delete agent@kuckuc.rs
+//	kuckuc-admin users reset-password agent@kuckuc.rs
+//	kuckuc-admin users require-2fa agent@kuckuc.rs on
+//	kuckuc-admin users reset-2fa agent@kuckuc.rs
+//	kuckuc-admin keys generate -alg EdDSA -activate 2026-11-01
+//
+// Passwords are prompted for on the terminal, read from stdin with
+// -password-stdin, or generated with -generate. Database settings are read
+// from the DB_* environment variables, the key directory from JWT_KEYS_DIR.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"kuckuc/internal/database"
+	"kuckuc/internal/models"
+	"kuckuc/internal/services"
+)
+
+const usage = `Usage: kuckuc-admin users <command> [flags] [arguments]
+       kuckuc-admin keys generate [-alg EdDSA|RS256] [-activate DATE] [-dir DIR] [-if-missing]
+
+Commands:
+  list                               list all users
+  create -email EMAIL -role ROLE     create a user
+  set-role EMAIL ROLE                change the role of a user
+  disable EMAIL                      disable a user and reject their tokens
+  enable EMAIL                       re-enable a disabled user
+  delete EMAIL                       delete a user without property history
+  reset-password EMAIL               set a new password
+  require-2fa EMAIL on|off           require two-factor authentication
+  reset-2fa EMAIL                    remove the second factor of a user
+
+Roles: admin, senior_agent, agent, viewer
+Password flags for create and reset-password:
+  -password-stdin                    read the password from stdin
+  -generate                          generate a random password and print it
+
+keys generate writes a new signing key to JWT_KEYS_DIR. It signs tokens from
+DATE (YYYY-MM-DD, default today); the API picks it up within a minute and
+publishes it in /.well-known/jwks.json right away. With -if-missing it only
+writes a key if the directory has none, e.g. to create a development key on
+first start.
+`
+
+func main() {
+	// Keys live on disk, so they need no database
+	if len(os.Args) >= 3 && os.Args[1] == "keys" && os.Args[2] == "generate" {
+		generateKey(os.Args[3:])
+		return
+	}
+	if len(os.Args) < 3 || os.Args[1] != "users" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		fail("failed to connect to database: %v", err)
+	}
+	authService := services.NewAuthService(db, nil, nil)
+
+	command, args := os.Args[2], os.Args[3:]
+	switch command {
+	case "list":
+		listUsers(authService)
+	case "create":
+		createUser(authService, args)
+	case "set-role":
+		user := userArg(authService, args, 2)
+		updated, err := authService.SetUserRole(user.ID, args[1])
+		check(err)
+		fmt.Printf("%s is now %s\n", updated.Email, updated.Role)
+	case "disable", "enable":
+		user := userArg(authService, args, 1)
+		_, err := authService.SetUserDisabled(user.ID, command == "disable")
+		check(err)
+		fmt.Printf("%s %sd\n", user.Email, command)
+	case "delete":
+		user := userArg(authService, args, 1)
+		check(authService.DeleteUser(user.ID))
+		fmt.Printf("%s deleted\n", user.Email)
+	case "reset-password":
+		resetPassword(authService, args)
+	case "require-2fa":
+		user := userArg(authService, args, 2)
+		if args[1] != "on" && args[1] != "off" {
+			fail("expected on or off, got %q", args[1])
+		}
+		_, err := authService.SetTwoFactorRequired(user.ID, args[1] == "on")
+		check(err)
+		fmt.Printf("Two-factor authentication for %s: required %s\n", user.Email, args[1])
+	case "reset-2fa":
+		user := userArg(authService, args, 1)
+		_, err := authService.ResetTwoFactor(user.ID)
+		check(err)
+		fmt.Printf("Two-factor authentication of %s removed\n", user.Email)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func listUsers(authService *services.AuthService) {
+	users, err := authService.ListUsers()
+	check(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tROLE\tSTATUS\t2FA\tCREATED")
+	for _, u := range users {
+		status := "active"
+		if u.DisabledAt != nil {
+			status = "disabled " + u.DisabledAt.Format("2006-01-02")
+		}
+		twoFactor := "off"
+		switch {
+		case u.TOTPEnabledAt != nil:
+			twoFactor = "on"
+		case u.TwoFactorRequired:
+			twoFactor = "required"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.Role, status, twoFactor, u.CreatedAt.Format(time.DateOnly))
+	}
+	w.Flush()
+}
+
+func createUser(authService *services.AuthService, args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	email := fs.String("email", "", "email address")
+	role := fs.String("role", services.RoleAgent, "admin, senior_agent, agent or viewer")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	generate := fs.Bool("generate", false, "generate a random password")
+	fs.Parse(args)
+
+	if *email == "" {
+		fail("-email is required")
+	}
+
+	password := readPassword(*passwordStdin, *generate)
+	user, err := authService.CreateUser(*email, password, *role)
+	check(err)
+	// Users created on the server need no verification link
+	check(authService.MarkEmailVerified(user.ID))
+	fmt.Printf("Created %s (id %d, role %s)\n", user.Email, user.ID, user.Role)
+}
+
+func resetPassword(authService *services.AuthService, args []string) {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	generate := fs.Bool("generate", false, "generate a random password")
+	fs.Parse(args)
+
+	user := userArg(authService, fs.Args(), 1)
+	password := readPassword(*passwordStdin, *generate)
+	_, err := authService.ResetPassword(user.ID, password)
+	check(err)
+	fmt.Printf("Password of %s updated\n", user.Email)
+}
+
+func generateKey(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	alg := fs.String("alg", services.AlgEdDSA, "EdDSA or RS256")
+	activate := fs.String("activate", time.Now().UTC().Format(time.DateOnly), "date the key starts signing, YYYY-MM-DD")
+	dir := fs.String("dir", os.Getenv("JWT_KEYS_DIR"), "key directory")
+	ifMissing := fs.Bool("if-missing", false, "do nothing if the directory has a key")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fail("-dir or JWT_KEYS_DIR is required")
+	}
+	if *ifMissing {
+		existing, err := filepath.Glob(filepath.Join(*dir, "*.pem"))
+		check(err)
+		if len(existing) > 0 {
+			return
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, *activate); err != nil {
+		fail("invalid -activate date %q", *activate)
+	}
+	key, err := services.GenerateSigningKey(*alg)
+	check(err)
+
+	check(os.MkdirAll(*dir, 0o700))
+	path := filepath.Join(*dir, *activate+"-"+strings.ToLower(*alg)+".pem")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	check(err)
+	_, err = f.Write(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	check(err)
+	fmt.Printf("Wrote %s (kid %s)\n", path, strings.TrimSuffix(filepath.Base(path), ".pem"))
+}
+
+// userArg looks up the user whose email is the first of n positional arguments.
+func userArg(authService *services.AuthService, args []string, n int) *models.User {
+	if len(args) != n {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	user, err := authService.GetUserByEmail(args[0])
+	check(err)
+	return user
+}
+
+func readPassword(fromStdin, generate bool) string {
+	switch {
+	case generate:
+		password, err := services.GeneratePassword()
+		check(err)
+		fmt.Printf("Generated password: %s\n", password)
+		return password
+
+	case fromStdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fail("failed to read password from stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n")
+
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		fmt.Fprint(os.Stderr, "Password: ")
+		first, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		check(err)
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		second, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		check(err)
+		if string(first) != string(second) {
+			fail("passwords do not match")
+		}
+		return string(first)
+
+	default:
+		fail("stdin is not a terminal; use -password-stdin or -generate")
+		return ""
+	}
+}
+
+func check(err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, services.ErrWeakPassword) {
+		fail("%v (at least %d characters with letters and digits)", err, services.MinPasswordLength)
+	}
+	fail("%v", err)
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "kuckuc-admin: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,384 @@
+// backend/cmd/tools/import_gazetteer/main.go
+
+// Command import_gazetteer loads settlements, districts and streets into the
+// local gazetteer used to geocode property addresses offline.
+//
+// Two formats are supported:
+//
+//   - GeoJSON FeatureCollection with OSM tags as feature properties, e.g. an
+//     extract exported with osmium:
+//
+//     osmium tags-filter serbia-latest.osm.pbf nwr/place nw/highway -o places.osm.pbf
+//     osmium export places.osm.pbf -o places.geojson
+//     go run ./cmd/tools/import_gazetteer -file places.geojson -source osm
+//
+//   - CSV with a header row: id,kind,parent_id,lat,lon,rank,name followed by
+//     any number of name:<lang> columns (name:sr, name:sr-Latn, name:en,
+//     name:ru). kind is settlement, district or street.
+//
+// Importing replaces everything previously imported from the same -source.
+// Database settings are read from the DB_* environment variables.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/database"
+	"kuckuc/internal/models"
+	"kuckuc/internal/services"
+)
+
+const batchSize = 1000
+
+func main() {
+	file := flag.String("file", "", "gazetteer file to import (.geojson or .csv)")
+	format := flag.String("format", "", "file format: geojson or csv (default: from the file extension)")
+	source := flag.String("source", "osm", "source label; a new import replaces the previous one with the same label")
+	dryRun := flag.Bool("dry-run", false, "parse the file and report what would be imported")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+		if *format == "json" {
+			*format = "geojson"
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	var read func(io.Reader, string, func(models.GazetteerPlace) error) error
+	switch *format {
+	case "geojson":
+		read = readGeoJSON
+	case "csv":
+		read = readCSV
+	default:
+		log.Fatalf("Unsupported format %q", *format)
+	}
+
+	counts := make(map[string]int)
+
+	if *dryRun {
+		err := read(bufio.NewReader(f), *source, func(place models.GazetteerPlace) error {
+			counts[place.Kind]++
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", *file, err)
+		}
+		log.Printf("Would import %d settlements, %d districts and %d streets",
+			counts[models.GazetteerSettlement], counts[models.GazetteerDistrict], counts[models.GazetteerStreet])
+		return
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	geocoder := services.NewGeocodingService(db)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := geocoder.DeleteSource(tx, *source); err != nil {
+			return fmt.Errorf("failed to delete previous import: %w", err)
+		}
+
+		batch := make([]models.GazetteerPlace, 0, batchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := geocoder.ImportPlaces(tx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			return nil
+		}
+
+		err := read(bufio.NewReader(f), *source, func(place models.GazetteerPlace) error {
+			counts[place.Kind]++
+			batch = append(batch, place)
+			if len(batch) == batchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		return geocoder.ResolveParents(tx, *source)
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	log.Printf("Imported %d settlements, %d districts and %d streets",
+		counts[models.GazetteerSettlement], counts[models.GazetteerDistrict], counts[models.GazetteerStreet])
+}
+
+// OSM place=* values mapped to a kind and settlement rank
+var osmPlaces = map[string]struct {
+	kind string
+	rank int
+}{
+	"city":              {models.GazetteerSettlement, 4},
+	"town":              {models.GazetteerSettlement, 3},
+	"village":           {models.GazetteerSettlement, 2},
+	"hamlet":            {models.GazetteerSettlement, 1},
+	"isolated_dwelling": {models.GazetteerSettlement, 1},
+	"borough":           {models.GazetteerDistrict, 0},
+	"city_district":     {models.GazetteerDistrict, 0},
+	"suburb":            {models.GazetteerDistrict, 0},
+	"quarter":           {models.GazetteerDistrict, 0},
+	"neighbourhood":     {models.GazetteerDistrict, 0},
+}
+
+type geoJSONFeature struct {
+	ID       interface{} `json:"id"`
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// readGeoJSON streams the features of a FeatureCollection, so extracts of
+// any size can be imported.
+func readGeoJSON(r io.Reader, source string, fn func(models.GazetteerPlace) error) error {
+	dec := json.NewDecoder(r)
+
+	// Skip to the features array
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("no features array found: %w", err)
+		}
+		if key, ok := tok.(string); ok && key == "features" {
+			if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+				return fmt.Errorf("features is not an array")
+			}
+			break
+		}
+	}
+
+	for i := 0; dec.More(); i++ {
+		var feature geoJSONFeature
+		if err := dec.Decode(&feature); err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		place, ok := osmPlace(feature, source, i)
+		if !ok {
+			continue
+		}
+		if err := fn(place); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func osmPlace(feature geoJSONFeature, source string, index int) (models.GazetteerPlace, bool) {
+	tags := make(map[string]string, len(feature.Properties))
+	for k, v := range feature.Properties {
+		if s, ok := v.(string); ok {
+			tags[k] = s
+		}
+	}
+	if tags["name"] == "" {
+		return models.GazetteerPlace{}, false
+	}
+
+	place := models.GazetteerPlace{Source: source}
+	if p, ok := osmPlaces[tags["place"]]; ok {
+		place.Kind, place.Rank = p.kind, p.rank
+	} else if tags["highway"] != "" {
+		place.Kind = models.GazetteerStreet
+	} else {
+		return models.GazetteerPlace{}, false
+	}
+
+	lat, lng, ok := centroid(feature.Geometry.Type, feature.Geometry.Coordinates)
+	if !ok {
+		return models.GazetteerPlace{}, false
+	}
+	place.Latitude, place.Longitude = lat, lng
+
+	switch id := feature.ID.(type) {
+	case string:
+		place.SourceID = id
+	case float64:
+		place.SourceID = strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	if place.SourceID == "" {
+		place.SourceID = tags["@id"]
+	}
+	if place.SourceID == "" {
+		place.SourceID = strconv.Itoa(index)
+	}
+
+	place.Names = append(place.Names, models.GazetteerName{Language: "", Name: tags["name"]})
+	for key, value := range tags {
+		switch {
+		case strings.HasPrefix(key, "name:"):
+			place.Names = append(place.Names, models.GazetteerName{Language: strings.TrimPrefix(key, "name:"), Name: value})
+		case key == "alt_name" || key == "old_name" || key == "int_name":
+			for _, name := range strings.Split(value, ";") {
+				place.Names = append(place.Names, models.GazetteerName{Language: "alt", Name: name})
+			}
+		}
+	}
+	return place, true
+}
+
+// centroid returns the average of all positions of a Point, LineString,
+// Polygon or their Multi variants.
+func centroid(geometryType string, raw json.RawMessage) (float64, float64, bool) {
+	var positions [][]float64
+	switch geometryType {
+	case "Point":
+		var p []float64
+		if json.Unmarshal(raw, &p) != nil {
+			return 0, 0, false
+		}
+		positions = [][]float64{p}
+	case "LineString", "MultiPoint":
+		if json.Unmarshal(raw, &positions) != nil {
+			return 0, 0, false
+		}
+	case "Polygon", "MultiLineString":
+		var rings [][][]float64
+		if json.Unmarshal(raw, &rings) != nil {
+			return 0, 0, false
+		}
+		for _, ring := range rings {
+			positions = append(positions, ring...)
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if json.Unmarshal(raw, &polygons) != nil {
+			return 0, 0, false
+		}
+		for _, polygon := range polygons {
+			for _, ring := range polygon {
+				positions = append(positions, ring...)
+			}
+		}
+	}
+
+	var lat, lng float64
+	var n int
+	for _, p := range positions {
+		if len(p) < 2 {
+			continue
+		}
+		lng += p[0]
+		lat += p[1]
+		n++
+	}
+	if n == 0 {
+		return 0, 0, false
+	}
+	return lat / float64(n), lng / float64(n), true
+}
+
+// readCSV reads the CSV format described in the package comment.
+func readCSV(r io.Reader, source string, fn func(models.GazetteerPlace) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"id", "kind", "lat", "lon", "name"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		place := models.GazetteerPlace{
+			Kind:           get("kind"),
+			Source:         source,
+			SourceID:       get("id"),
+			ParentSourceID: get("parent_id"),
+		}
+		switch place.Kind {
+		case models.GazetteerSettlement, models.GazetteerDistrict, models.GazetteerStreet:
+		default:
+			return fmt.Errorf("line %d: invalid kind %q", line, place.Kind)
+		}
+		if place.Latitude, err = strconv.ParseFloat(get("lat"), 64); err != nil {
+			return fmt.Errorf("line %d: invalid lat: %w", line, err)
+		}
+		if place.Longitude, err = strconv.ParseFloat(get("lon"), 64); err != nil {
+			return fmt.Errorf("line %d: invalid lon: %w", line, err)
+		}
+		if rank := get("rank"); rank != "" {
+			if place.Rank, err = strconv.Atoi(rank); err != nil {
+				return fmt.Errorf("line %d: invalid rank: %w", line, err)
+			}
+		}
+
+		for column := range columns {
+			if column != "name" && !strings.HasPrefix(column, "name:") {
+				continue
+			}
+			if value := get(column); value != "" {
+				place.Names = append(place.Names, models.GazetteerName{
+					Language: strings.TrimPrefix(strings.TrimPrefix(column, "name"), ":"),
+					Name:     value,
+				})
+			}
+		}
+		if len(place.Names) == 0 {
+			return fmt.Errorf("line %d: place has no name", line)
+		}
+
+		if err := fn(place); err != nil {
+			return err
+		}
+	}
+}
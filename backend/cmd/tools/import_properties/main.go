@@ -0,0 +1,113 @@
+// backend/cmd/tools/import_properties/main.go
+
+// Command import_properties creates and updates properties from a
+// spreadsheet, the same way as POST /properties/import:
+//
+//   - XLSX in the layout of the export, one sheet per property type
+//     (Houses, Apartments, Offices)
+//   - CSV with the same header row and a Property Type column, separated by
+//     commas or semicolons
+//
+// Rows are keyed by Property Code. An optional Language column lets one file
+// hold details in several languages; other rows are in -language. The import
+// runs as the user given with -as, with that user's permissions, and is
+// recorded in the property history under that user.
+//
+//	go run ./cmd/tools/import_properties -file properties.xlsx -as admin@example.com -dry-run
+//
+// Nothing is saved if any row has an error. Database settings are read from
+// the DB_* environment variables.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/database"
+	"kuckuc/internal/models"
+	"kuckuc/internal/services"
+)
+
+func main() {
+	file := flag.String("file", "", "file to import (.xlsx or .csv)")
+	format := flag.String("format", "", "file format: xlsx or csv (default: from the file extension)")
+	language := flag.String("language", "sr", "language of rows without a Language column (sr, en, ru)")
+	as := flag.String("as", "", "email of the user the import runs as")
+	dryRun := flag.Bool("dry-run", false, "validate the file and report what would change")
+	flag.Parse()
+
+	if *file == "" || *as == "" {
+		log.Fatal("-file and -as are required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	db, err := database.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	var user models.User
+	email := strings.ToLower(strings.TrimSpace(*as))
+	err = db.Where("email = ? AND disabled_at IS NULL", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Fatalf("No active user with email %s", email)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load user: %v", err)
+	}
+
+	propertyService := services.NewPropertyService(db, services.NewGeocodingService(db))
+	report, err := propertyService.ImportProperties(f, services.ImportOptions{
+		Format:   services.ImportFormat(*format),
+		Language: *language,
+		DryRun:   *dryRun,
+	}, services.Actor{UserID: user.ID, Role: user.Role})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, property := range report.Properties {
+		fmt.Printf("%-9s %s (%s)\n", property.Action, property.PropertyCode, strings.Join(property.Languages, ", "))
+		for _, change := range property.Changes {
+			fmt.Printf("          %s: %v -> %v\n", change.Field, change.Before, change.After)
+		}
+	}
+	if len(report.IgnoredColumns) > 0 {
+		log.Printf("Ignored columns: %s", strings.Join(report.IgnoredColumns, ", "))
+	}
+	for _, e := range report.Errors {
+		location := fmt.Sprintf("row %d", e.Row)
+		if e.Sheet != "" {
+			location = e.Sheet + " " + location
+		}
+		if e.Column != "" {
+			location += ", " + e.Column
+		}
+		log.Printf("%s: %s", location, e.Message)
+	}
+	if len(report.Errors) > 0 {
+		log.Fatalf("%d errors in %d rows; nothing was imported", len(report.Errors), report.Rows)
+	}
+
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	log.Printf("%s %d rows: %d created, %d updated, %d unchanged",
+		verb, report.Rows, report.Created, report.Updated, report.Unchanged)
+}
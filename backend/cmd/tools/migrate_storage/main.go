@@ -0,0 +1,100 @@
+// backend/cmd/tools/migrate_storage/main.go
+
+// Command migrate_storage copies uploaded files from one storage backend to
+// another, e.g. from the local uploads/ directory to an S3 bucket:
+//
+//	go run ./cmd/tools/migrate_storage -from local -to s3 -local-dir ./uploads
+//
+// S3 settings are read from the S3_* environment variables.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"kuckuc/internal/storage"
+)
+
+func main() {
+	from := flag.String("from", "local", "source storage driver (local, s3)")
+	to := flag.String("to", "s3", "destination storage driver (local, s3)")
+	localDir := flag.String("local-dir", os.Getenv("UPLOAD_DIR"), "directory used by the local driver")
+	prefix := flag.String("prefix", "", "only copy keys with this prefix")
+	overwrite := flag.Bool("overwrite", false, "overwrite objects that already exist at the destination")
+	dryRun := flag.Bool("dry-run", false, "list what would be copied without copying")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatal("source and destination drivers must differ")
+	}
+
+	src := openStorage(*from, *localDir)
+	dst := openStorage(*to, *localDir)
+
+	ctx := context.Background()
+	var copied, skipped, failed int
+
+	err := src.List(ctx, *prefix, func(obj storage.Object) error {
+		if !*overwrite {
+			existing, err := dst.Stat(ctx, obj.Key)
+			if err == nil && existing.Size == obj.Size {
+				skipped++
+				return nil
+			}
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("Error checking %s: %v", obj.Key, err)
+				failed++
+				return nil
+			}
+		}
+
+		if *dryRun {
+			log.Printf("Would copy %s (%d bytes)", obj.Key, obj.Size)
+			copied++
+			return nil
+		}
+
+		if err := copyObject(ctx, src, dst, obj); err != nil {
+			log.Printf("Error copying %s: %v", obj.Key, err)
+			failed++
+			return nil
+		}
+
+		log.Printf("Copied %s (%d bytes)", obj.Key, obj.Size)
+		copied++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to list source objects: %v", err)
+	}
+
+	log.Printf("Done: %d copied, %d skipped, %d failed", copied, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func openStorage(driver, localDir string) storage.Storage {
+	cfg := storage.ConfigFromEnv()
+	cfg.Driver = driver
+	cfg.LocalDir = localDir
+
+	s, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", driver, err)
+	}
+	return s
+}
+
+func copyObject(ctx context.Context, src, dst storage.Storage, obj storage.Object) error {
+	r, info, err := src.Get(ctx, obj.Key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Put(ctx, obj.Key, r, info.Size, info.ContentType)
+}
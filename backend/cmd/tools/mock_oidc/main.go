@@ -0,0 +1,38 @@
+// backend/cmd/tools/mock_oidc/main.go
+
+// Command mock_oidc is an OpenID Connect provider for trying single sign-on
+// locally. Its login page asks for an email and groups instead of a password:
+//
+//	go run ./cmd/tools/mock_oidc -issuer http://localhost:8090
+//
+// Point the API at it with OIDC_ISSUER_URL=http://localhost:8090 and
+// OIDC_CLIENT_ID=kuckuc. When the API runs in docker-compose, start the mock
+// with -internal-url http://mock-oidc:8090 and set OIDC_DISCOVERY_URL to the
+// same address, since the API cannot reach localhost:8090. The provider keeps
+// everything in memory and supports only the authorization code flow with
+// PKCE (S256).
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"kuckuc/internal/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	issuer := flag.String("issuer", "http://localhost:8090", "issuer URL, as seen by browsers")
+	internalURL := flag.String("internal-url", "", "base URL of the token, userinfo and JWKS endpoints, as seen by the API (default: issuer)")
+	clientID := flag.String("client-id", "kuckuc", "accepted client ID")
+	secret := flag.String("client-secret", "", "client secret to require (default: public client)")
+	flag.Parse()
+
+	provider, err := oidctest.New(*issuer, *internalURL, *clientID, *secret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Mock OIDC provider for client %q listening on %s, issuer %s", *clientID, *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
@@ -0,0 +1,33 @@
+// backend/internal/database/database.go
+
+package database
+
+import (
+	"fmt"
+	"os"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// DSNFromEnv builds the postgres DSN from the DB_* environment variables.
+func DSNFromEnv() string {
+	dbPort := os.Getenv("DB_PORT")
+	if dbPort == "" {
+		dbPort = "5432"
+	}
+
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+		dbPort,
+	)
+}
+
+// Open connects to the database configured in the environment. It is shared
+// by the API server and the command line tools.
+func Open() (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(DSNFromEnv()), &gorm.Config{})
+}
@@ -0,0 +1,139 @@
+// backend/internal/handlers/account.go
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/services"
+)
+
+// AccountHandlers serve the public password reset and email verification
+// endpoints.
+type AccountHandlers struct {
+	accountService *services.AccountService
+}
+
+func NewAccountHandlers(accountService *services.AccountService) *AccountHandlers {
+	return &AccountHandlers{
+		accountService: accountService,
+	}
+}
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type TokenPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPassword godoc
+// @Summary Request password reset
+// @Description Mail a single-use reset link. The response is the same whether or not the account exists
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Account email"
+// @Success 202 {object} map[string]string
+// @Router /auth/forgot-password [post]
+func (h *AccountHandlers) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.accountService.RequestPasswordReset(req.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "if the account exists, a reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from the reset link. Signs the user out everywhere
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TokenPasswordRequest true "Token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/reset-password [post]
+func (h *AccountHandlers) ResetPassword(c *gin.Context) {
+	var req TokenPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(req.Token, req.Password); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "password updated"})
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailTokenRequest true "Token from the verification link"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/verify-email [post]
+func (h *AccountHandlers) VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req.Token); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "email verified"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description The response is the same whether or not the account exists
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Account email"
+// @Success 202 {object} map[string]string
+// @Router /auth/verify-email/resend [post]
+func (h *AccountHandlers) ResendVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.accountService.ResendVerification(req.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "if the account needs verification, a link has been sent"})
+}
+
+func respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidEmailToken), errors.Is(err, services.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
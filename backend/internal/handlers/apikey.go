@@ -0,0 +1,107 @@
+// backend/internal/handlers/apikey.go
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/middleware"
+	"kuckuc/internal/services"
+)
+
+// APIKeyHandlers serve the admin API key endpoints. All routes require
+// services.PermUsersManage.
+type APIKeyHandlers struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandlers(apiKeyService *services.APIKeyService) *APIKeyHandlers {
+	return &APIKeyHandlers{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Router /admin/api-keys [get]
+// @Security Bearer
+func (h *APIKeyHandlers) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary Create API key
+// @Description Create a key that acts as user_id (default: the current user) with the given scopes: properties:read, properties:write, files:upload, properties:export. The key is only returned in this response. Clients send it as "Authorization: Bearer <key>". rate_limit (requests per minute, default 60) is counted by each API replica separately, so behind N replicas a key can make up to N times as many requests
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body services.CreateAPIKeyInput true "New key"
+// @Success 201 {object} services.CreatedAPIKey
+// @Failure 400 {object} map[string]string
+// @Router /admin/api-keys [post]
+// @Security Bearer
+func (h *APIKeyHandlers) CreateAPIKey(c *gin.Context) {
+	var input services.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := h.apiKeyService.Create(input, middleware.CurrentActor(c).UserID)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke API key
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} map[string]string
+// @Router /admin/api-keys/{id} [delete]
+// @Security Bearer
+func (h *APIKeyHandlers) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid API key id"})
+		return
+	}
+
+	key, err := h.apiKeyService.Revoke(uint(id))
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidScope), errors.Is(err, services.ErrInvalidAPIKeySettings),
+		errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrUserDisabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
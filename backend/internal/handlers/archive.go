@@ -0,0 +1,244 @@
+// backend/internal/handlers/archive.go
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/middleware"
+	"kuckuc/internal/models"
+	"kuckuc/internal/services"
+)
+
+// ArchiveHandlers delete properties and list, restore and audit what was
+// deleted. Deleted properties and documents are purged by the retention job.
+type ArchiveHandlers struct {
+	propertyService  *services.PropertyService
+	retentionService *services.RetentionService
+}
+
+func NewArchiveHandlers(propertyService *services.PropertyService, retentionService *services.RetentionService) *ArchiveHandlers {
+	return &ArchiveHandlers{
+		propertyService:  propertyService,
+		retentionService: retentionService,
+	}
+}
+
+// deletedProperty is a deleted property with the time it will be purged.
+type deletedProperty struct {
+	models.Property
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// deletedDocument is a deleted document with the time it will be purged.
+type deletedDocument struct {
+	models.Document
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// DeleteProperty godoc
+// @Summary Delete property
+// @Description Mark a property deleted. It is hidden everywhere but kept, with its documents and files, until it is purged after the retention period (DELETED_RETENTION_DAYS); until then it can be restored. If-Match is optional; when sent, only that version is deleted
+// @Tags properties
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /properties/{id} [delete]
+// @Security Bearer
+func (h *ArchiveHandlers) DeleteProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.propertyService.DeleteProperty(uint(id), version, middleware.CurrentActor(c)); err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "deleted",
+		"purge_at": h.retentionService.PurgeAt(time.Now()),
+	})
+}
+
+// ListDeletedProperties godoc
+// @Summary List deleted properties
+// @Description Deleted properties the user may restore, most recently deleted first, with the time each will be purged
+// @Tags properties
+// @Produce json
+// @Param language query string false "Language of the details (sr, en, ru)"
+// @Success 200 {array} map[string]interface{}
+// @Router /properties/deleted [get]
+// @Security Bearer
+func (h *ArchiveHandlers) ListDeletedProperties(c *gin.Context) {
+	language := c.DefaultQuery("language", "sr")
+	if language != "sr" && language != "en" && language != "ru" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language"})
+		return
+	}
+
+	properties, err := h.propertyService.ListDeletedProperties(middleware.CurrentActor(c), language)
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	deleted := make([]deletedProperty, 0, len(properties))
+	for _, property := range properties {
+		deleted = append(deleted, deletedProperty{
+			Property: property,
+			PurgeAt:  h.retentionService.PurgeAt(property.DeletedAt.Time),
+		})
+	}
+	c.JSON(http.StatusOK, deleted)
+}
+
+// RestoreDeletedProperty godoc
+// @Summary Restore deleted property
+// @Description Undo the deletion of a property that has not been purged yet
+// @Tags properties
+// @Produce json
+// @Param id path int true "Property ID"
+// @Success 200 {object} models.Property
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /properties/{id}/undelete [post]
+// @Security Bearer
+func (h *ArchiveHandlers) RestoreDeletedProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	property, err := h.propertyService.RestoreDeletedProperty(uint(id), middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.Header("ETag", propertyETag(property))
+	c.JSON(http.StatusOK, property)
+}
+
+// ListDeletedFiles godoc
+// @Summary List deleted files
+// @Description Deleted files of a property, most recently deleted first, with the time each will be purged
+// @Tags files
+// @Produce json
+// @Param id path int true "Property ID"
+// @Success 200 {array} map[string]interface{}
+// @Router /properties/{id}/files/deleted [get]
+// @Security Bearer
+func (h *ArchiveHandlers) ListDeletedFiles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	documents, err := h.propertyService.ListDeletedDocuments(uint(id), middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	deleted := make([]deletedDocument, 0, len(documents))
+	for _, document := range documents {
+		deleted = append(deleted, deletedDocument{
+			Document: document,
+			PurgeAt:  h.retentionService.PurgeAt(document.DeletedAt.Time),
+		})
+	}
+	c.JSON(http.StatusOK, deleted)
+}
+
+// RestoreFile godoc
+// @Summary Restore deleted file
+// @Description Undo the deletion of a property file that has not been purged yet
+// @Tags files
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param file_id path int true "File ID"
+// @Success 200 {object} models.Document
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /properties/{id}/files/{file_id}/restore [post]
+// @Security Bearer
+func (h *ArchiveHandlers) RestoreFile(c *gin.Context) {
+	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+
+	document, err := h.propertyService.RestoreDocument(uint(propertyID), uint(fileID), middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, document)
+}
+
+// ListAuditLog godoc
+// @Summary List audit log
+// @Description Actions that cannot be undone, such as purges of deleted properties and files, newest first
+// @Tags users
+// @Produce json
+// @Param action query string false "Only this action, e.g. purge"
+// @Param entity_type query string false "Only this entity type: property, document"
+// @Param entity_id query int false "Only this entity"
+// @Param limit query int false "Maximum number of entries (default 100, max 1000)"
+// @Success 200 {array} models.AuditLog
+// @Router /admin/audit-log [get]
+// @Security Bearer
+func (h *ArchiveHandlers) ListAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	var entityID uint64
+	if value := c.Query("entity_id"); value != "" {
+		if entityID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity_id"})
+			return
+		}
+	}
+
+	entries, err := h.retentionService.ListAuditLog(c.Query("action"), c.Query("entity_type"), uint(entityID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -3,9 +3,12 @@
 package handlers
 
 import (
+	"errors"
 	"kuckuc/internal/services"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,16 +28,21 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return a short-lived JWT access token with a refresh token. Accounts with two-factor authentication get a challenge_token for /auth/2fa/verify instead
 // @Tags auth
 // @Accept  json
 // @Produce  json
 // @Param credentials body LoginRequest true "Login credentials"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} services.LoginResult
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /auth/login [post]
 func (h *AuthHandlers) Login(c *gin.Context) {
 	var req LoginRequest
@@ -44,17 +52,142 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Login attempt for email: %s", req.Email)
+	result, err := h.authService.Login(req.Email, req.Password, services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		respondLoginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token. Each refresh token can be used once; reusing one revokes its session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshRequest true "Refresh token"
+// @Success 200 {object} services.TokenPair
+// @Failure 401 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *AuthHandlers) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	token, err := h.authService.Login(req.Email, req.Password)
+	pair, err := h.authService.Refresh(req.RefreshToken)
 	if err != nil {
-		log.Printf("Login failed for email %s: %v", req.Email, err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		respondSessionError(c, err)
 		return
 	}
 
-	log.Printf("Login successful for email: %s", req.Email)
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the session of the refresh token. Works with an expired access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/logout [post]
+func (h *AuthHandlers) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.Logout(req.RefreshToken); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens, selected by the kid header. Keys are published before they start signing and kept until tokens signed with them have expired
+// @Tags auth
+// @Produce json
+// @Success 200 {object} services.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandlers) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+// LogoutAll godoc
+// @Summary Logout all sessions
+// @Description Revoke every session of the current user, including this one
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /auth/logout-all [post]
+// @Security Bearer
+func (h *AuthHandlers) LogoutAll(c *gin.Context) {
+	if err := h.authService.LogoutAll(c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
+}
+
+// ListSessions godoc
+// @Summary List sessions
+// @Description Active sessions of the current user
+// @Tags auth
+// @Produce json
+// @Success 200 {array} models.AuthSession
+// @Router /auth/sessions [get]
+// @Security Bearer
+func (h *AuthHandlers) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"current": c.GetString("sessionID"), "sessions": sessions})
+}
+
+func respondLoginError(c *gin.Context, err error) {
+	var throttled *services.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrInvalidChallenge),
+		errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Login failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+	}
+}
+
+func respondSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRefreshToken), errors.Is(err, services.ErrRefreshTokenReused),
+		errors.Is(err, services.ErrSessionRevoked), errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrUserDisabled):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 // GetCurrentUser godoc
@@ -0,0 +1,212 @@
+// backend/internal/handlers/export.go
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/middleware"
+	"kuckuc/internal/models"
+	"kuckuc/internal/services"
+)
+
+// ExportHandlers start background exports, report their progress and serve
+// the finished ZIP through signed download links.
+type ExportHandlers struct {
+	exportJobService *services.ExportJobService
+	fileService      *services.FileService
+}
+
+func NewExportHandlers(exportJobService *services.ExportJobService, fileService *services.FileService) *ExportHandlers {
+	return &ExportHandlers{
+		exportJobService: exportJobService,
+		fileService:      fileService,
+	}
+}
+
+// exportJob is an export with a download link once it is completed. The
+// link is valid until download_expires_at; fetch the job again for a new one.
+type exportJob struct {
+	models.ExportJob
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+func (h *ExportHandlers) exportJob(job *models.ExportJob) exportJob {
+	response := exportJob{ExportJob: *job}
+	if job.Status == models.ExportCompleted {
+		expires, signature := h.fileService.SignExportURL(job.ID, services.SignedURLTTL)
+		expiresAt := time.Unix(expires, 0).UTC()
+		response.DownloadURL = fmt.Sprintf("/api/exports/%s/download?expires=%d&signature=%s", job.ID, expires, signature)
+		response.DownloadExpiresAt = &expiresAt
+	}
+	return response
+}
+
+// ExportProperties godoc
+// @Summary Export properties
+// @Description Start a background export of properties to a ZIP with a spreadsheet and the files and history of each property. Poll GET /exports/{id} for progress; once completed it has a download link. The result is kept for EXPORT_RESULT_TTL_HOURS; a user may have EXPORT_JOBS_PER_USER exports queued or running
+// @Tags properties
+// @Accept json
+// @Produce json
+// @Param language query string false "Language of the details (sr, en, ru)"
+// @Param request body map[string][]int true "property_ids"
+// @Success 202 {object} models.ExportJob
+// @Failure 429 {object} map[string]string
+// @Router /properties/export [post]
+// @Security Bearer
+func (h *ExportHandlers) ExportProperties(c *gin.Context) {
+	var request struct {
+		PropertyIDs []uint `json:"property_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	language := c.DefaultQuery("language", "sr")
+	if language != "sr" && language != "en" && language != "ru" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language"})
+		return
+	}
+
+	job, err := h.exportJobService.CreateJob(middleware.CurrentActor(c), request.PropertyIDs, language)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/exports/"+job.ID)
+	c.JSON(http.StatusAccepted, h.exportJob(job))
+}
+
+// ListExports godoc
+// @Summary List exports
+// @Description The user's exports that have not expired, newest first
+// @Tags properties
+// @Produce json
+// @Success 200 {array} models.ExportJob
+// @Router /exports [get]
+// @Security Bearer
+func (h *ExportHandlers) ListExports(c *gin.Context) {
+	jobs, err := h.exportJobService.ListJobs(middleware.CurrentActor(c))
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	response := make([]exportJob, 0, len(jobs))
+	for i := range jobs {
+		response = append(response, h.exportJob(&jobs[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetExport godoc
+// @Summary Get export
+// @Description Status and progress (processed of total properties) of an export, with a download link once it is completed
+// @Tags properties
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.ExportJob
+// @Failure 404 {object} map[string]string
+// @Router /exports/{id} [get]
+// @Security Bearer
+func (h *ExportHandlers) GetExport(c *gin.Context) {
+	job, err := h.exportJobService.GetJob(c.Param("id"), middleware.CurrentActor(c))
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.exportJob(job))
+}
+
+// CancelExport godoc
+// @Summary Cancel export
+// @Description Stop a queued or running export
+// @Tags properties
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.ExportJob
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /exports/{id}/cancel [post]
+// @Security Bearer
+func (h *ExportHandlers) CancelExport(c *gin.Context) {
+	job, err := h.exportJobService.CancelJob(c.Param("id"), middleware.CurrentActor(c))
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.exportJob(job))
+}
+
+// DownloadExport godoc
+// @Summary Download export
+// @Description Download the ZIP of a completed export using the signed link from GetExport. Supports range requests
+// @Tags properties
+// @Param id path string true "Export ID"
+// @Param expires query int true "Expiry timestamp"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Router /exports/{id}/download [get]
+func (h *ExportHandlers) DownloadExport(c *gin.Context) {
+	id := c.Param("id")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	if err := h.fileService.VerifyExportSignature(id, expires, c.Query("signature")); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, services.ErrSignatureExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, file, info, err := h.exportJobService.OpenResult(id)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("properties_export_%s.zip", job.CreatedAt.Format("2006-01-02"))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	http.ServeContent(c.Writer, c.Request, filename, info.ModTime, file)
+}
+
+// respondExportError maps export job errors to 400, 403, 404, 409, 429 or 500.
+func respondExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNothingToExport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrExportJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrExportJobFinished), errors.Is(err, services.ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTooManyExports):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Export error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,39 @@
+// backend/internal/handlers/export_test.go
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/services"
+)
+
+func TestRespondExportError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{services.ErrForbidden, http.StatusForbidden},
+		{services.ErrNothingToExport, http.StatusBadRequest},
+		{services.ErrExportJobNotFound, http.StatusNotFound},
+		{fmt.Errorf("export 1: %w", services.ErrExportJobNotFound), http.StatusNotFound},
+		{services.ErrExportJobFinished, http.StatusConflict},
+		{services.ErrExportNotReady, http.StatusConflict},
+		{services.ErrTooManyExports, http.StatusTooManyRequests},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondExportError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondExportError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
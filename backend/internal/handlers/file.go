@@ -3,16 +3,21 @@
 package handlers
 
 import (
-	"kuckuc/internal/services"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-
-	//      "os"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"kuckuc/internal/middleware"
 	"kuckuc/internal/models"
+	"kuckuc/internal/services"
 )
 
 type FileHandlers struct {
@@ -40,46 +45,55 @@ func NewFileHandlers(fileService *services.FileService, propertyService *service
 // @Success 200 {object} map[string]string
 // @Router /properties/{property_id}/files [post]
 // @Security Bearer
-
 func (h *FileHandlers) UploadFile(c *gin.Context) {
-	// Добавим отладочный вывод
-	log.Printf("Received id param: %s", c.Param("id"))
-
 	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil || propertyID == 0 {
-		log.Printf("Error parsing property ID: %v, raw value: %s", err, c.Param("id"))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
 		return
 	}
 
+	// Проверяем тип файла из query параметров
+	fileType := services.FileType(c.Query("file_type"))
+	maxSize, ok := services.MaxUploadSizes[fileType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file type", "code": services.UploadErrInvalidFileType})
+		return
+	}
+
+	// Не читаем тело запроса сверх лимита (с запасом на поля формы)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
 	// Получаем файл
 	file, err := c.FormFile("file")
 	if err != nil {
-		log.Printf("Error getting file from form: %v", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":    fmt.Sprintf("file exceeds the %d MB limit for %s files", maxSize>>20, fileType),
+				"code":     services.UploadErrFileTooLarge,
+				"max_size": maxSize,
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
 		return
 	}
 
-	// Проверяем тип файла из query параметров
-	fileType := services.FileType(c.Query("file_type"))
-	if fileType != services.FileTypeImage &&
-		fileType != services.FileTypeVideo &&
-		fileType != services.FileTypeDocument {
-		log.Printf("Invalid file type: %s", fileType)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file type"})
+	// Проверяем содержимое файла
+	upload, err := h.fileService.ValidateUpload(file, fileType)
+	if err != nil {
+		respondUploadError(c, err, maxSize)
 		return
 	}
 
-	// Дополнительная проверка существования property
-	_, err = h.propertyService.GetProperty(uint(propertyID), "en")
-	if err != nil {
-		log.Printf("Property not found: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
+	// Проверяем существование property и право его изменять
+	if err := h.propertyService.AuthorizeEdit(middleware.CurrentActor(c), uint(propertyID)); err != nil {
+		respondPropertyError(c, err)
 		return
 	}
 
 	// Сохраняем файл
-	filePath, err := h.fileService.SaveFile(file, fileType, uint(propertyID))
+	filePath, err := h.fileService.SaveFile(file, upload, uint(propertyID))
 	if err != nil {
 		log.Printf("Error saving file: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -95,9 +109,18 @@ func (h *FileHandlers) UploadFile(c *gin.Context) {
 		IsPublic:   isPublic,
 	}
 
-	if err := h.propertyService.AddDocument(&document); err != nil {
+	// Генерируем уменьшенные копии изображения
+	if fileType == services.FileTypeImage {
+		variants, err := h.fileService.SaveImageVariants(filePath)
+		if err != nil {
+			log.Printf("Error generating image variants for %s: %v", filePath, err)
+		}
+		document.Variants = variants
+	}
+
+	if err := h.propertyService.AddDocument(&document, middleware.CurrentActor(c)); err != nil {
 		log.Printf("Error adding document to database: %v", err)
-		_ = h.fileService.DeleteFile(filePath) // Очищаем файл при ошибке
+		_ = h.fileService.DeleteDocumentFiles(&document) // Очищаем файлы при ошибке
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -107,7 +130,7 @@ func (h *FileHandlers) UploadFile(c *gin.Context) {
 
 // DeleteFile godoc
 // @Summary Delete file
-// @Description Delete property file
+// @Description Mark a property file deleted. The file is kept until it is purged after the retention period, and can be restored until then
 // @Tags files
 // @Produce json
 // @Param id path int true "Property ID" // Изменено с property_id на id
@@ -116,13 +139,13 @@ func (h *FileHandlers) UploadFile(c *gin.Context) {
 // @Router /properties/{property_id}/files/{file_id} [delete]
 // @Security Bearer
 func (h *FileHandlers) DeleteFile(c *gin.Context) {
-	propertyID, err := strconv.ParseUint(c.Param("property_id"), 10, 32)
+	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
 		return
 	}
 
-	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 32)
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
 		return
@@ -139,14 +162,13 @@ func (h *FileHandlers) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Delete file from storage
-	if err := h.fileService.DeleteFile(document.FilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.propertyService.AuthorizeEdit(middleware.CurrentActor(c), uint(propertyID)); err != nil {
+		respondPropertyError(c, err)
 		return
 	}
 
-	// Delete document record
-	if err := h.propertyService.DeleteDocument(uint(fileID)); err != nil {
+	// The files stay in storage until the retention job purges the document
+	if err := h.propertyService.DeleteDocument(uint(fileID), middleware.CurrentActor(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -167,13 +189,13 @@ func (h *FileHandlers) DeleteFile(c *gin.Context) {
 // @Router /properties/{property_id}/files/{file_id}/visibility [put]
 // @Security Bearer
 func (h *FileHandlers) UpdateFileVisibility(c *gin.Context) {
-	propertyID, err := strconv.ParseUint(c.Param("property_id"), 10, 32)
+	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
 		return
 	}
 
-	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 32)
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
 		return
@@ -188,10 +210,160 @@ func (h *FileHandlers) UpdateFileVisibility(c *gin.Context) {
 		return
 	}
 
-	if err := h.propertyService.UpdateDocumentVisibility(uint(fileID), uint(propertyID), request.IsPublic); err != nil {
+	if err := h.propertyService.AuthorizeEdit(middleware.CurrentActor(c), uint(propertyID)); err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	if err := h.propertyService.UpdateDocumentVisibility(uint(fileID), uint(propertyID), request.IsPublic, middleware.CurrentActor(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
+
+// GetFileURL godoc
+// @Summary Get file URL
+// @Description Issue a download URL for a property file. Private files get a short-lived signed URL
+// @Tags files
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param file_id path int true "File ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /properties/{id}/files/{file_id}/url [get]
+// @Security Bearer
+func (h *FileHandlers) GetFileURL(c *gin.Context) {
+	propertyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+
+	document, err := h.propertyService.GetDocument(uint(fileID))
+	if err != nil || document.PropertyID != uint(propertyID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	if document.IsPublic {
+		c.JSON(http.StatusOK, gin.H{"url": "/uploads/" + filepath.ToSlash(document.FilePath)})
+		return
+	}
+
+	if !middleware.CurrentActor(c).Can(services.PermDocumentsPrivate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		return
+	}
+
+	expires, signature := h.fileService.SignDocumentURL(document.ID, services.SignedURLTTL)
+	c.JSON(http.StatusOK, gin.H{
+		"url":        fmt.Sprintf("/api/files/%d/download?expires=%d&signature=%s", document.ID, expires, signature),
+		"expires_at": time.Unix(expires, 0).UTC(),
+	})
+}
+
+// DownloadFile godoc
+// @Summary Download file
+// @Description Download a property file using a signed URL issued by GetFileURL. Supports range requests
+// @Tags files
+// @Param file_id path int true "File ID"
+// @Param expires query int true "Expiry timestamp"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Router /files/{file_id}/download [get]
+func (h *FileHandlers) DownloadFile(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	if err := h.fileService.VerifyDocumentSignature(uint(fileID), expires, c.Query("signature")); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, services.ErrSignatureExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, err := h.propertyService.GetDocument(uint(fileID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filepath.Base(document.FilePath)))
+	h.serveFile(c, document.FilePath)
+}
+
+// ServePublicFile serves files under /uploads, but only those that belong to
+// a public document. Private documents are reachable through DownloadFile only.
+func (h *FileHandlers) ServePublicFile(c *gin.Context) {
+	filePath := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+
+	isPublic, err := h.propertyService.IsPublicFile(filePath)
+	if err != nil || !isPublic {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	h.serveFile(c, filePath)
+}
+
+func (h *FileHandlers) serveFile(c *gin.Context, relativePath string) {
+	file, info, err := h.fileService.OpenFile(relativePath)
+	if err != nil {
+		log.Printf("Error opening file %s: %v", relativePath, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer file.Close()
+
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+
+	// ServeContent handles Range and conditional requests, which video playback relies on.
+	http.ServeContent(c.Writer, c.Request, path.Base(info.Key), info.ModTime, file)
+}
+
+func respondUploadError(c *gin.Context, err error, maxSize int64) {
+	var uploadErr *services.UploadError
+	if !errors.As(err, &uploadErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch uploadErr.Code {
+	case services.UploadErrFileTooLarge:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    uploadErr.Message,
+			"code":     uploadErr.Code,
+			"max_size": maxSize,
+		})
+		return
+	case services.UploadErrUnsupportedFormat, services.UploadErrContentMismatch, services.UploadErrUnsupportedImage,
+		services.UploadErrExecutableContent:
+		status = http.StatusUnsupportedMediaType
+	}
+
+	c.JSON(status, gin.H{"error": uploadErr.Message, "code": uploadErr.Code})
+}
@@ -0,0 +1,170 @@
+// backend/internal/handlers/oidc.go
+
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/services"
+)
+
+// oidcStateCookie binds a single sign-on login to the browser that started
+// it, so a callback URL cannot be replayed in another browser.
+const oidcStateCookie = "kuckuc_oidc_state"
+
+// OIDCHandlers serve the single sign-on endpoints. oidcService is nil when
+// single sign-on is not configured.
+type OIDCHandlers struct {
+	oidcService *services.OIDCService
+	// appURL is the frontend the callback redirects to
+	appURL string
+}
+
+func NewOIDCHandlers(oidcService *services.OIDCService, appURL string) *OIDCHandlers {
+	return &OIDCHandlers{
+		oidcService: oidcService,
+		appURL:      strings.TrimRight(appURL, "/"),
+	}
+}
+
+type OIDCExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetOIDCConfig godoc
+// @Summary Single sign-on settings
+// @Description Whether single sign-on is available and the name of the identity provider
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/oidc [get]
+func (h *OIDCHandlers) GetOIDCConfig(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "name": h.oidcService.ProviderName()})
+}
+
+// StartLogin godoc
+// @Summary Start single sign-on
+// @Description Redirect the browser to the identity provider
+// @Tags auth
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandlers) StartLogin(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		h.redirectToApp(c, url.Values{"error": {"single sign-on is not available right now"}})
+		return
+	}
+
+	h.setStateCookie(c, state, 600)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Single sign-on callback
+// @Description Redirect target of the identity provider. Sends the browser to the frontend's /login/sso with a one-time code for /auth/oidc/exchange, or with an error
+// @Tags auth
+// @Param state query string true "State from /auth/oidc/login"
+// @Param code query string false "Authorization code"
+// @Success 302
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandlers) Callback(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	boundState, _ := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "", -1)
+
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("OIDC provider returned %s: %s", providerError, c.Query("error_description"))
+		h.redirectToApp(c, url.Values{"error": {"the identity provider refused the login"}})
+		return
+	}
+
+	code, err := h.oidcService.Callback(c.Request.Context(), c.Query("state"), boundState, c.Query("code"))
+	if err != nil {
+		message := err.Error()
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCLogin), errors.Is(err, services.ErrOIDCEmailMissing),
+			errors.Is(err, services.ErrOIDCNoRole), errors.Is(err, services.ErrUserDisabled):
+			// Shown to the user as is
+		default:
+			log.Printf("OIDC callback failed: %v", err)
+			message = "single sign-on failed"
+		}
+		h.redirectToApp(c, url.Values{"error": {message}})
+		return
+	}
+
+	h.redirectToApp(c, url.Values{"code": {code}})
+}
+
+// Exchange godoc
+// @Summary Finish single sign-on
+// @Description Exchange the one-time code from the callback for an access and refresh token. Accounts with two-factor authentication get a challenge_token for /auth/2fa/verify instead
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body OIDCExchangeRequest true "One-time code"
+// @Success 200 {object} services.LoginResult
+// @Failure 401 {object} map[string]string
+// @Router /auth/oidc/exchange [post]
+func (h *OIDCHandlers) Exchange(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	var req OIDCExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.oidcService.Exchange(req.Code, services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCLogin), errors.Is(err, services.ErrUserNotFound),
+			errors.Is(err, services.ErrUserDisabled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			log.Printf("OIDC exchange failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *OIDCHandlers) setStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	// Lax, because the provider's redirect back is a cross-site navigation
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/auth/oidc", "", secure, true)
+}
+
+func (h *OIDCHandlers) redirectToApp(c *gin.Context, query url.Values) {
+	c.Redirect(http.StatusFound, h.appURL+"/login/sso?"+query.Encode())
+}
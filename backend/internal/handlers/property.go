@@ -3,27 +3,31 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"kuckuc/internal/middleware"
 	"kuckuc/internal/models"
 	"kuckuc/internal/services"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
 )
 
 type PropertyHandlers struct {
 	propertyService *services.PropertyService
-	exportService   *services.ExportService
 }
 
-func NewPropertyHandlers(propertyService *services.PropertyService, exportService *services.ExportService) *PropertyHandlers {
+func NewPropertyHandlers(propertyService *services.PropertyService) *PropertyHandlers {
 	return &PropertyHandlers{
 		propertyService: propertyService,
-		exportService:   exportService,
 	}
 }
 
@@ -41,13 +45,72 @@ func (h *PropertyHandlers) GetProperties(c *gin.Context) {
 		return
 	}
 
-	properties, err := h.propertyService.ListProperties(filter, language)
+	page, err := h.propertyService.ListProperties(filter, language, readActor(c))
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidSort) || errors.Is(err, services.ErrInvalidGeoFilter) ||
+			errors.Is(err, services.ErrInvalidLifecycleState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, properties)
+	var next, prev *string
+	if page.Page < page.TotalPages {
+		link := pageLink(c, page.Page+1, page.PerPage)
+		next = &link
+	}
+	if page.Page > 1 && page.TotalPages > 0 {
+		link := pageLink(c, min(page.Page-1, page.TotalPages), page.PerPage)
+		prev = &link
+	}
+
+	// format=geojson returns a FeatureCollection for map views. The paging
+	// fields are added as foreign members.
+	if c.Query("format") == "geojson" {
+		c.Header("Content-Type", "application/geo+json")
+		c.JSON(http.StatusOK, gin.H{
+			"type":        "FeatureCollection",
+			"features":    services.PropertiesToGeoJSON(page.Items).Features,
+			"total":       page.Total,
+			"page":        page.Page,
+			"per_page":    page.PerPage,
+			"total_pages": page.TotalPages,
+			"next":        next,
+			"prev":        prev,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       page.Items,
+		"total":       page.Total,
+		"page":        page.Page,
+		"per_page":    page.PerPage,
+		"total_pages": page.TotalPages,
+		"next":        next,
+		"prev":        prev,
+	})
+}
+
+// readActor is the user of a request to a public route, or an anonymous
+// visitor if there is none or the request uses an API key without
+// ScopePropertiesRead.
+func readActor(c *gin.Context) services.Actor {
+	if scopes, ok := c.Get("apiKeyScopes"); ok && !services.HasScope(scopes.([]string), services.ScopePropertiesRead) {
+		return services.Actor{}
+	}
+	return middleware.CurrentActor(c)
+}
+
+// pageLink returns the current request URL with the page number replaced,
+// keeping all filters and the sort order.
+func pageLink(c *gin.Context, page, perPage int) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+	return c.Request.URL.Path + "?" + query.Encode()
 }
 
 // GetProperty godoc
@@ -64,12 +127,13 @@ func (h *PropertyHandlers) GetProperty(c *gin.Context) {
 		return
 	}
 
-	property, err := h.propertyService.GetProperty(uint(id), language)
+	property, err := h.propertyService.GetProperty(uint(id), language, readActor(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
 		return
 	}
 
+	c.Header("ETag", propertyETag(property))
 	c.JSON(http.StatusOK, property)
 }
 
@@ -86,19 +150,32 @@ func (h *PropertyHandlers) CreateProperty(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetUint("userID")
 	log.Printf("Creating property with data: %+v", property)
 
-	if err := h.propertyService.CreateProperty(&property, userID); err != nil {
+	if err := h.propertyService.CreateProperty(&property, middleware.CurrentActor(c)); err != nil {
 		log.Printf("Error creating property: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondPropertyError(c, err)
 		return
 	}
 
+	c.Header("ETag", propertyETag(&property))
 	c.JSON(http.StatusCreated, property)
 }
 
 // UpdateProperty godoc
+// @Summary Update property
+// @Description Replace the fields of a property that are present in the body; omitted fields and details languages keep their values. The update must name the version it is based on, as If-Match with the ETag from GET, or as the version field of the body. "If-Match: *" overwrites any version. If the property changed meanwhile, the response is 409 with the current property in "current"
+// @Tags properties
+// @Accept json
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param If-Match header string false "ETag of the version being edited"
+// @Param property body models.Property true "Property"
+// @Success 200 {object} models.Property
+// @Failure 409 {object} map[string]interface{}
+// @Failure 428 {object} map[string]string
+// @Router /properties/{id} [put]
+// @Security Bearer
 func (h *PropertyHandlers) UpdateProperty(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -106,20 +183,200 @@ func (h *PropertyHandlers) UpdateProperty(c *gin.Context) {
 		return
 	}
 
-	var property models.Property
-	if err := c.ShouldBindJSON(&property); err != nil {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := expectedVersion(c, body)
+	if errors.Is(err, errVersionRequired) {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	property, err := h.propertyService.GetProperty(uint(id), "", middleware.CurrentActor(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
+		return
+	}
+	if err := decodePropertyUpdate(property, body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	property.ID = uint(id)
-	userID := c.GetUint("userID")
 
-	if err := h.propertyService.UpdateProperty(&property, userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.propertyService.UpdateProperty(property, version, middleware.CurrentActor(c)); err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			h.respondVersionConflict(c, uint(id))
+			return
+		}
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.Header("ETag", propertyETag(property))
+	c.JSON(http.StatusOK, property)
+}
+
+var errVersionRequired = errors.New("send If-Match with the ETag of the property, or its version")
+
+// expectedVersion returns the version an update is based on, from If-Match
+// or else the version field of body. "If-Match: *" returns 0, which skips
+// the version check.
+func expectedVersion(c *gin.Context, body []byte) (int, error) {
+	if version, ok, err := ifMatchVersion(c); ok || err != nil {
+		return version, err
+	}
+
+	var fields struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return 0, err
+	}
+	if fields.Version < 1 {
+		return 0, errVersionRequired
+	}
+	return fields.Version, nil
+}
+
+// ifMatchVersion returns the property version in If-Match and whether the
+// header was sent. "If-Match: *" returns 0.
+func ifMatchVersion(c *gin.Context) (int, bool, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		return 0, false, nil
+	}
+	if ifMatch == "*" {
+		return 0, true, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(ifMatch, `"`) || !strings.HasSuffix(ifMatch, `"`) {
+		return 0, true, errors.New("If-Match must be a single ETag of the property")
+	}
+	return version, true, nil
+}
+
+// decodePropertyUpdate applies body onto property, so fields missing from
+// body keep their values. Details are matched by language, and a language
+// missing from body is left as it is.
+func decodePropertyUpdate(property *models.Property, body []byte) error {
+	var fields struct {
+		Details []json.RawMessage `json:"details"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+
+	// Copied, since decoding body reuses the elements of property.Details
+	stored := append([]models.PropertyDetails(nil), property.Details...)
+	if err := json.Unmarshal(body, property); err != nil {
+		return err
+	}
+
+	if fields.Details != nil {
+		property.Details = make([]models.PropertyDetails, 0, len(fields.Details))
+		for _, raw := range fields.Details {
+			var language struct {
+				Language string `json:"language"`
+			}
+			if err := json.Unmarshal(raw, &language); err != nil {
+				return err
+			}
+			var detail models.PropertyDetails
+			for _, existing := range stored {
+				if existing.Language == language.Language {
+					detail = existing
+					break
+				}
+			}
+			if err := json.Unmarshal(raw, &detail); err != nil {
+				return err
+			}
+			property.Details = append(property.Details, detail)
+		}
+	} else {
+		property.Details = stored
+	}
+
+	return binding.Validator.ValidateStruct(property)
+}
+
+// respondVersionConflict sends 409 with the stored property, so the client
+// can merge its changes and retry with the new ETag.
+func (h *PropertyHandlers) respondVersionConflict(c *gin.Context, id uint) {
+	current, err := h.propertyService.GetProperty(id, "", middleware.CurrentActor(c))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrVersionConflict.Error()})
+		return
+	}
+	c.Header("ETag", propertyETag(current))
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   services.ErrVersionConflict.Error(),
+		"current": current,
+	})
+}
+
+// propertyETag is the strong ETag of a property version.
+func propertyETag(property *models.Property) string {
+	return strconv.Quote(strconv.Itoa(property.Version))
+}
+
+// PatchProperty godoc
+// @Summary Patch property
+// @Description Apply a JSON merge patch (RFC 7386). Details are an object keyed by language, e.g. {"details": {"en": {"price": 950}}}; a null language removes it. Codes, ownership, lifecycle fields and documents cannot be patched. If-Match is optional; when sent, the patch is only applied to that version
+// @Tags properties
+// @Accept json
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "Merge patch"
+// @Success 200 {object} models.Property
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 415 {object} map[string]string
+// @Router /properties/{id} [patch]
+// @Security Bearer
+func (h *PropertyHandlers) PatchProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	if contentType := c.ContentType(); contentType != "application/merge-patch+json" && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "send the patch as application/merge-patch+json"})
+		return
+	}
+
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	property, err := h.propertyService.PatchProperty(uint(id), patch, version, middleware.CurrentActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			h.respondVersionConflict(c, uint(id))
+			return
+		}
+		respondPropertyError(c, err)
 		return
 	}
 
+	c.Header("ETag", propertyETag(property))
 	c.JSON(http.StatusOK, property)
 }
 
@@ -140,20 +397,187 @@ func (h *PropertyHandlers) UpdatePropertyStatus(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetUint("userID")
+	if err := h.propertyService.UpdatePropertyStatus(uint(id), request.IsActive, middleware.CurrentActor(c)); err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+}
+
+// TransitionProperty godoc
+// @Summary Change lifecycle state
+// @Description Move a property along draft, published, reserved, sold or rented, and archived. Taking back a reservation or closed deal and reopening an archived property need a reason
+// @Tags properties
+// @Accept json
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param transition body TransitionRequest true "Next state"
+// @Success 200 {object} models.Property
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /properties/{id}/transitions [post]
+// @Security Bearer
+func (h *PropertyHandlers) TransitionProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	var request TransitionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	property, err := h.propertyService.TransitionProperty(uint(id), request.State, request.Reason, middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.Header("ETag", propertyETag(property))
+	c.JSON(http.StatusOK, property)
+}
+
+type TransitionRequest struct {
+	State  models.LifecycleState `json:"state" binding:"required"`
+	Reason string                `json:"reason"`
+}
+
+// GetPropertyHistory godoc
+// @Summary Property history
+// @Description Changes to a property, newest first. Each entry's details list the changed fields with their values before and after. Owner changes are only listed for users who may read private documents
+// @Tags properties
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param agent_id query int false "Only changes by this user"
+// @Param action query string false "Comma-separated action types: create, update, assign, state_change, status_update, document_add, document_delete, document_visibility, restore, delete, undelete, document_restore"
+// @Param from query string false "Date or RFC 3339 time"
+// @Param to query string false "Date (inclusive) or RFC 3339 time"
+// @Success 200 {array} models.History
+// @Failure 400 {object} map[string]string
+// @Router /properties/{id}/history [get]
+// @Security Bearer
+func (h *PropertyHandlers) GetPropertyHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
+	var filter services.HistoryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.propertyService.GetProperty(uint(id), "", middleware.CurrentActor(c)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
+		return
+	}
 
-	if err := h.propertyService.UpdatePropertyStatus(uint(id), request.IsActive, userID); err != nil {
+	history, err := h.propertyService.GetPropertyHistory(uint(id), filter, middleware.CurrentActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidHistoryFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+	c.JSON(http.StatusOK, history)
+}
+
+// PreviewRestore godoc
+// @Summary Preview property restore
+// @Description The property with its details and owner as it was after a history entry, and the changes restoring it would make. The owner is only included for users who may read private documents. Entries recorded before snapshots were kept have none
+// @Tags properties
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param historyId path int true "History entry ID"
+// @Success 200 {object} services.RestorePreview
+// @Failure 404 {object} map[string]string
+// @Router /properties/{id}/history/{historyId}/snapshot [get]
+// @Security Bearer
+func (h *PropertyHandlers) PreviewRestore(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+	historyID, err := strconv.ParseUint(c.Param("historyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid history id"})
+		return
+	}
+
+	preview, err := h.propertyService.PreviewRestore(uint(id), uint(historyID), middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// RestoreProperty godoc
+// @Summary Restore property
+// @Description Set the fields, details and owner of a property back to a history entry. The restore is recorded as a new history entry. The lifecycle state, assignment and documents are not restored. If-Match is optional; when sent, only that version is restored over
+// @Tags properties
+// @Produce json
+// @Param id path int true "Property ID"
+// @Param historyId path int true "History entry ID"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 200 {object} models.Property
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /properties/{id}/history/{historyId}/restore [post]
+// @Security Bearer
+func (h *PropertyHandlers) RestoreProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+	historyID, err := strconv.ParseUint(c.Param("historyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid history id"})
+		return
+	}
+
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	property, err := h.propertyService.RestoreProperty(uint(id), uint(historyID), version, middleware.CurrentActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			h.respondVersionConflict(c, uint(id))
+			return
+		}
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.Header("ETag", propertyETag(property))
+	c.JSON(http.StatusOK, property)
 }
 
-// ExportProperties godoc
-func (h *PropertyHandlers) ExportProperties(c *gin.Context) {
+// AssignProperty godoc
+func (h *PropertyHandlers) AssignProperty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return
+	}
+
 	var request struct {
-		PropertyIDs []uint `json:"property_ids"`
+		AgentID uint `json:"agent_id" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -161,21 +585,90 @@ func (h *PropertyHandlers) ExportProperties(c *gin.Context) {
 		return
 	}
 
-	language := c.DefaultQuery("language", "sr")
-	if language != "sr" && language != "en" && language != "ru" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language"})
+	if err := h.propertyService.AssignProperty(uint(id), request.AgentID, middleware.CurrentActor(c)); err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "assigned"})
+}
+
+// respondPropertyError maps service errors to 400, 403, 404, 409 or 500.
+func respondPropertyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLifecycleState), errors.Is(err, services.ErrTransitionReasonRequired),
+		errors.Is(err, services.ErrInvalidPatch), errors.Is(err, services.ErrInvalidImport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrNotDeleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSnapshotNotFound), errors.Is(err, services.ErrDocumentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// maxImportSize limits the size of an uploaded import file
+const maxImportSize = 20 << 20
+
+// ImportProperties godoc
+// @Summary Import properties
+// @Description Create and update properties from an XLSX file in the layout of the export, or a CSV file with the same columns and a Property Type column. Rows are keyed by Property Code; an optional Language column holds several languages in one file. Every row is validated and nothing is saved if any row has an error. With dry_run nothing is saved and the report shows what would change
+// @Tags properties
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "XLSX or CSV file"
+// @Param format query string false "xlsx or csv (default: from the file name)"
+// @Param language query string false "Language of rows without a Language column (sr, en, ru)"
+// @Param dry_run query bool false "Only validate and report"
+// @Success 200 {object} services.ImportReport
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} services.ImportReport
+// @Router /properties/import [post]
+// @Security Bearer
+func (h *PropertyHandlers) ImportProperties(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds the %d MB limit", maxImportSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
 		return
 	}
 
-	zipData, err := h.exportService.ExportProperties(services.PropertyFilter{}, language, request.PropertyIDs)
+	format := services.ImportFormat(strings.ToLower(c.Query("format")))
+	if format == "" {
+		format = services.ImportFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), "."))
+	}
+
+	f, err := file.Open()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer f.Close()
 
-	filename := fmt.Sprintf("properties_export_%s.zip", time.Now().Format("2006-01-02"))
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Data(http.StatusOK, "application/zip", zipData)
+	report, err := h.propertyService.ImportProperties(f, services.ImportOptions{
+		Format:   format,
+		Language: c.DefaultQuery("language", "sr"),
+		DryRun:   c.Query("dry_run") == "true",
+	}, middleware.CurrentActor(c))
+	if err != nil {
+		respondPropertyError(c, err)
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
 }
@@ -0,0 +1,104 @@
+// backend/internal/handlers/property_test.go
+
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/models"
+)
+
+func TestExpectedVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		body    string
+		want    int
+		wantErr error // nil for no error, errVersionRequired or any other
+	}{
+		{"if-match", `"7"`, `{}`, 7, nil},
+		{"if-match wins over body", `"7"`, `{"version": 3}`, 7, nil},
+		{"any version", `*`, `{}`, 0, nil},
+		{"body", "", `{"version": 3}`, 3, nil},
+		{"missing", "", `{"price": 1}`, 0, errVersionRequired},
+		{"zero version", "", `{"version": 0}`, 0, errVersionRequired},
+		{"weak etag", `W/"7"`, `{}`, 0, errors.New("")},
+		{"unquoted", `7`, `{}`, 0, errors.New("")},
+		{"several etags", `"7", "8"`, `{}`, 0, errors.New("")},
+		{"negative", `"-1"`, `{}`, 0, errors.New("")},
+		{"invalid body", "", `{`, 0, errors.New("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("PUT", "/properties/1", nil)
+			if tt.ifMatch != "" {
+				c.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			version, err := expectedVersion(c, []byte(tt.body))
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("error = %v", err)
+			case tt.wantErr == errVersionRequired && !errors.Is(err, errVersionRequired):
+				t.Fatalf("error = %v, want errVersionRequired", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("version = %d, want an error", version)
+			}
+			if err == nil && version != tt.want {
+				t.Errorf("version = %d, want %d", version, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePropertyUpdate(t *testing.T) {
+	lat, lng := 44.8, 20.4
+	property := &models.Property{
+		ID:           1,
+		PropertyType: models.Apartment,
+		DealType:     models.Sale,
+		Latitude:     &lat,
+		Longitude:    &lng,
+		Details: []models.PropertyDetails{
+			{ID: 10, Language: "sr", City: "Beograd", Price: 100000, Rooms: 3},
+			{ID: 11, Language: "en", City: "Belgrade", Price: 100000, Rooms: 3},
+		},
+	}
+
+	body := `{"deal_type": "rent", "details": [{"language": "sr", "price": 900}, {"language": "ru", "city": "Белград"}]}`
+	if err := decodePropertyUpdate(property, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if property.DealType != models.Rent || property.PropertyType != models.Apartment || property.Latitude == nil {
+		t.Errorf("property = %+v, want only deal_type changed", property)
+	}
+	if len(property.Details) != 2 {
+		t.Fatalf("details = %+v, want the sr and ru details", property.Details)
+	}
+	sr, ru := property.Details[0], property.Details[1]
+	if sr.ID != 10 || sr.City != "Beograd" || sr.Price != 900 || sr.Rooms != 3 {
+		t.Errorf("sr details = %+v, want the stored details with the new price", sr)
+	}
+	if ru.ID != 0 || ru.Language != "ru" || ru.City != "Белград" {
+		t.Errorf("ru details = %+v, want new details", ru)
+	}
+
+	// Without details in the body, the stored ones stay
+	property.Details = []models.PropertyDetails{{ID: 10, Language: "sr", City: "Beograd"}}
+	if err := decodePropertyUpdate(property, []byte(`{"status": "new"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(property.Details) != 1 || property.Details[0].City != "Beograd" {
+		t.Errorf("details = %+v, want them unchanged", property.Details)
+	}
+
+	if err := decodePropertyUpdate(property, []byte(`{"latitude": 91}`)); err == nil {
+		t.Error("latitude out of range was accepted")
+	}
+}
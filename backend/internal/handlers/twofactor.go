@@ -0,0 +1,213 @@
+// backend/internal/handlers/twofactor.go
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/services"
+)
+
+type ChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type VerifyLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifyLogin godoc
+// @Summary Second login step
+// @Description Complete a login with a TOTP code or a recovery code. For accounts that had to enrol, the code confirms the new secret and the response includes the recovery codes. A challenge completes one login only
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyLoginRequest true "Challenge and code"
+// @Success 200 {object} services.LoginResult
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /auth/2fa/verify [post]
+func (h *AuthHandlers) VerifyLogin(c *gin.Context) {
+	var req VerifyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.authService.VerifyLogin(req.ChallengeToken, req.Code, services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		respondLoginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// EnrollTwoFactor godoc
+// @Summary Enrol during login
+// @Description Generate a TOTP secret for an account that must set up two-factor authentication before its login completes
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ChallengeRequest true "Enrolment challenge"
+// @Success 200 {object} services.TwoFactorSetup
+// @Failure 401 {object} map[string]string
+// @Router /auth/2fa/enroll [post]
+func (h *AuthHandlers) EnrollTwoFactor(c *gin.Context) {
+	var req ChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	setup, err := h.authService.EnrollChallenge(req.ChallengeToken)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// GetTwoFactorStatus godoc
+// @Summary Two-factor status
+// @Tags auth
+// @Produce json
+// @Success 200 {object} services.TwoFactorStatus
+// @Router /auth/2fa [get]
+// @Security Bearer
+func (h *AuthHandlers) GetTwoFactorStatus(c *gin.Context) {
+	status, err := h.authService.GetTwoFactorStatus(c.GetUint("userID"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTwoFactor godoc
+// @Summary Start two-factor setup
+// @Description Generate a TOTP secret and provisioning URI. It takes effect after /auth/2fa/enable
+// @Tags auth
+// @Produce json
+// @Success 200 {object} services.TwoFactorSetup
+// @Failure 409 {object} map[string]string
+// @Router /auth/2fa/setup [post]
+// @Security Bearer
+func (h *AuthHandlers) SetupTwoFactor(c *gin.Context) {
+	setup, err := h.authService.SetupTwoFactor(c.GetUint("userID"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableTwoFactor godoc
+// @Summary Enable two-factor authentication
+// @Description Confirm the secret from /auth/2fa/setup with a code. Returns the recovery codes, which are not shown again
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string][]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/2fa/enable [post]
+// @Security Bearer
+func (h *AuthHandlers) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.EnableTwoFactor(c.GetUint("userID"), req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes after checking a TOTP code
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string][]string
+// @Router /auth/2fa/recovery-codes [post]
+// @Security Bearer
+func (h *AuthHandlers) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.GetUint("userID"), req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Not allowed when an admin requires two-factor authentication for the account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /auth/2fa/disable [post]
+// @Security Bearer
+func (h *AuthHandlers) DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(c.GetUint("userID"), req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "disabled"})
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrTwoFactorNotSetUp):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
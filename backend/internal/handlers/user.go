@@ -0,0 +1,337 @@
+// backend/internal/handlers/user.go
+
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"kuckuc/internal/middleware"
+	"kuckuc/internal/services"
+)
+
+// UserHandlers serve the admin user management API. All routes require
+// services.PermUsersManage.
+type UserHandlers struct {
+	authService    *services.AuthService
+	accountService *services.AccountService
+}
+
+func NewUserHandlers(authService *services.AuthService, accountService *services.AccountService) *UserHandlers {
+	return &UserHandlers{
+		authService:    authService,
+		accountService: accountService,
+	}
+}
+
+type CreateUserRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+type UpdateUserRequest struct {
+	Role              *string `json:"role"`
+	Disabled          *bool   `json:"disabled"`
+	TwoFactorRequired *bool   `json:"two_factor_required"`
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Tags users
+// @Produce json
+// @Success 200 {array} models.User
+// @Router /admin/users [get]
+// @Security Bearer
+func (h *UserHandlers) ListUsers(c *gin.Context) {
+	users, err := h.authService.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// CreateUser godoc
+// @Summary Create user
+// @Description The user gets an email verification link and can log in once the address is verified
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body CreateUserRequest true "New user"
+// @Success 201 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users [post]
+// @Security Bearer
+func (h *UserHandlers) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.CreateUser(req.Email, req.Password, req.Role)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	if err := h.accountService.SendVerification(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// SendVerification godoc
+// @Summary Resend verification email
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 202 {object} map[string]string
+// @Router /admin/users/{id}/verification [post]
+// @Security Bearer
+func (h *UserHandlers) SendVerification(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.SendVerification(c.Request.Context(), id); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "sent"})
+}
+
+// UpdateUser godoc
+// @Summary Change role, disable user or require two-factor authentication
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body UpdateUserRequest true "Fields to change"
+// @Success 200 {object} models.User
+// @Router /admin/users/{id} [patch]
+// @Security Bearer
+func (h *UserHandlers) UpdateUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Admins cannot lock themselves out
+	if id == middleware.CurrentActor(c).UserID &&
+		((req.Role != nil && *req.Role != services.RoleAdmin) || (req.Disabled != nil && *req.Disabled)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot demote or disable yourself"})
+		return
+	}
+
+	user, err := h.authService.UpdateUser(id, services.UserChanges{
+		Role:              req.Role,
+		Disabled:          req.Disabled,
+		TwoFactorRequired: req.TwoFactorRequired,
+	})
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetTwoFactor godoc
+// @Summary Disable two-factor authentication of a user
+// @Description For users who lost their authenticator. If two-factor authentication is required, the user enrols again on the next login
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Router /admin/users/{id}/two-factor [delete]
+// @Security Bearer
+func (h *UserHandlers) ResetTwoFactor(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.ResetTwoFactor(id)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetPassword godoc
+// @Summary Reset user password
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param password body ResetPasswordRequest true "New password"
+// @Success 200 {object} map[string]string
+// @Router /admin/users/{id}/password [put]
+// @Security Bearer
+func (h *UserHandlers) ResetPassword(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.authService.ResetPassword(id, req.Password); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Delete a user without property history. Users with history can only be disabled
+// @Tags users
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users/{id} [delete]
+// @Security Bearer
+func (h *UserHandlers) DeleteUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if id == middleware.CurrentActor(c).UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete yourself"})
+		return
+	}
+
+	if err := h.authService.DeleteUser(id); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListLockouts godoc
+// @Summary List login lockouts
+// @Description Accounts and IP addresses with recent failed logins. locked is true for a full lockout, otherwise locked_until is a backoff delay
+// @Tags users
+// @Produce json
+// @Success 200 {array} services.Lockout
+// @Router /admin/lockouts [get]
+// @Security Bearer
+func (h *UserHandlers) ListLockouts(c *gin.Context) {
+	lockouts, err := h.authService.LoginLimiter().Lockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lockouts)
+}
+
+// ClearLockout godoc
+// @Summary Clear login lockout
+// @Description Reset the failed login counter of an account or an IP address
+// @Tags users
+// @Param email query string false "Account email"
+// @Param ip query string false "IP address"
+// @Success 200 {object} map[string]string
+// @Router /admin/lockouts [delete]
+// @Security Bearer
+func (h *UserHandlers) ClearLockout(c *gin.Context) {
+	email, ip := c.Query("email"), c.Query("ip")
+	if (email == "") == (ip == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either email or ip is required"})
+		return
+	}
+
+	limiter := h.authService.LoginLimiter()
+	var err error
+	if email != "" {
+		err = limiter.ClearAccount(email)
+	} else {
+		err = limiter.ClearIP(ip)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
+
+// ListFailedLogins godoc
+// @Summary List failed logins
+// @Tags users
+// @Produce json
+// @Param email query string false "Only attempts for this email"
+// @Param ip query string false "Only attempts from this IP address"
+// @Param limit query int false "Maximum number of attempts (default 100, max 1000)"
+// @Success 200 {array} models.LoginAttempt
+// @Router /admin/login-attempts [get]
+// @Security Bearer
+func (h *UserHandlers) ListFailedLogins(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	attempts, err := h.authService.ListFailedLogins(c.Query("email"), c.Query("ip"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrUserInUse), errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,55 @@
+// backend/internal/mail/file.go
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every message as an .eml file into a directory, for
+// local development and tests.
+type FileMailer struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, errors.New("MAIL_DIR must be set for the file mail driver")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0644)
+}
+
+// LogMailer prints messages to the log instead of sending them.
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail from %s to %s: %s\n%s", m.from, msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,127 @@
+// backend/internal/mail/mail.go
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer sends account emails such as password resets.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Driver string
+	From   string
+
+	// SMTP driver
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// File driver
+	Dir string
+}
+
+// ConfigFromEnv reads the mail configuration from MAIL_DRIVER, MAIL_FROM,
+// MAIL_DIR and the SMTP_* variables.
+func ConfigFromEnv() Config {
+	driver := os.Getenv("MAIL_DRIVER")
+	if driver == "" {
+		driver = "log"
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Kuckuc <no-reply@kuckuc.rs>"
+	}
+	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if port == 0 {
+		port = 587
+	}
+
+	return Config{
+		Driver:       driver,
+		From:         from,
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     port,
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		Dir:          os.Getenv("MAIL_DIR"),
+	}
+}
+
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "file":
+		return NewFileMailer(cfg.Dir, cfg.From)
+	case "log":
+		return NewLogMailer(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// render formats msg as an RFC 5322 message with a quoted-printable UTF-8
+// body.
+func render(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+
+	headers := []struct{ name, value string }{
+		{"From", from},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(id) + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, h := range headers {
+		if strings.ContainsAny(h.value, "\r\n") {
+			return nil, fmt.Errorf("invalid %s header", h.name)
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.name, h.value)
+	}
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+// backend/internal/mail/mail_test.go
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	msg := Message{
+		To:      "milica@kuckuc.rs",
+		Subject: "Promena lozinke – Kuckuc",
+		Text:    "Zdravo,\nlink: https://kuckuc.rs/reset-password?token=abc\n",
+	}
+	data, err := render("Kuckuc <no-reply@kuckuc.rs>", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("To"); got != msg.To {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasSuffix(id, "@kuckuc.rs>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(quotedprintable.NewReader(parsed.Body)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.ReplaceAll(body.String(), "\r\n", "\n"); got != msg.Text {
+		t.Errorf("body = %q, want %q", got, msg.Text)
+	}
+}
+
+func TestRenderHeaderInjection(t *testing.T) {
+	msg := Message{To: "a@kuckuc.rs\r\nBcc: everyone@example.com", Subject: "Hi"}
+	if _, err := render("no-reply@kuckuc.rs", msg); err == nil {
+		t.Error("recipient with a line break was accepted")
+	}
+
+	// Subjects are encoded, which keeps line breaks out of the header
+	msg = Message{To: "a@kuckuc.rs", Subject: "Hi\nBcc: everyone@example.com"}
+	data, err := render("no-reply@kuckuc.rs", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("\nBcc:")) {
+		t.Errorf("subject added a header:\n%s", data)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if m, err := New(Config{Driver: "log"}); err != nil {
+		t.Errorf("log driver: %v", err)
+	} else if _, ok := m.(*LogMailer); !ok {
+		t.Errorf("log driver returned %T", m)
+	}
+	if _, err := New(Config{Driver: "file"}); err == nil {
+		t.Error("file driver without a directory was accepted")
+	}
+	if _, err := New(Config{Driver: "smtp"}); err == nil {
+		t.Error("smtp driver without a host was accepted")
+	}
+	if _, err := New(Config{Driver: "pigeon"}); err == nil {
+		t.Error("unknown driver was accepted")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "no-reply@kuckuc.rs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{"a@kuckuc.rs", "b@kuckuc.rs"} {
+		if err := m.Send(context.Background(), Message{To: to, Subject: "Hi", Text: "Hello\n"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d .eml files, want 2", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("To: a@kuckuc.rs\r\n")) {
+		t.Errorf("first message is not addressed to a@kuckuc.rs:\n%s", data)
+	}
+}
@@ -0,0 +1,114 @@
+// backend/internal/mail/smtp.go
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer sends mail through an SMTP server. Port 465 uses implicit TLS,
+// other ports upgrade with STARTTLS when the server offers it.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	// envelopeFrom is the bare address of from
+	envelopeFrom string
+}
+
+func NewSMTPMailer(cfg Config) (*SMTPMailer, error) {
+	if cfg.SMTPHost == "" {
+		return nil, errors.New("SMTP_HOST must be set for the smtp mail driver")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+
+	return &SMTPMailer{
+		host:         cfg.SMTPHost,
+		port:         cfg.SMTPPort,
+		username:     cfg.SMTPUsername,
+		password:     cfg.SMTPPassword,
+		from:         cfg.From,
+		envelopeFrom: from.Address,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	body, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	tlsConfig := &tls.Config{ServerName: m.host}
+
+	var conn net.Conn
+	if m.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.envelopeFrom); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
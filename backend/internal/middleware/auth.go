@@ -4,7 +4,9 @@ package middleware
 
 import (
 	"kuckuc/internal/services"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,26 +14,22 @@ import (
 
 func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "no authorization header"})
+		token, ok := bearerToken(c)
+		if !ok {
 			return
 		}
-
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		if strings.HasPrefix(token, services.APIKeyPrefix) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used here"})
 			return
 		}
 
-		claims, err := authService.ValidateToken(tokenParts[1])
+		claims, err := authService.ValidateToken(token)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
-		// Check if user is an agent
-		if claims.Role != "agent" {
+		if !services.ValidRole(claims.Role) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
 			return
 		}
@@ -40,7 +38,109 @@ func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
 		c.Set("userRole", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// AuthOrAPIKey accepts an access token like AuthRequired, or an API key sent
+// the same way. Requests with a key act as the key's user, only on routes
+// with a RequireScope the key has, and count against the key's rate limit.
+func AuthOrAPIKey(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	authRequired := AuthRequired(authService)
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(token, services.APIKeyPrefix) {
+			authRequired(c)
+			return
+		}
+
+		apiKey, user, err := apiKeyService.Authenticate(token, c.ClientIP())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(apiKey.RateLimit))
+		if allowed, retryAfter := apiKeyService.Allow(apiKey); !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "API key rate limit exceeded"})
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("userEmail", user.Email)
+		c.Set("userRole", user.Role)
+		c.Set("apiKeyID", apiKey.ID)
+		c.Set("apiKeyScopes", apiKey.Scopes)
+
+		c.Next()
+	}
+}
+
+// OptionalAuth authenticates requests that have an Authorization header
+// like AuthOrAPIKey, and lets requests without one through anonymously.
+// Public routes use it to show more to signed-in users.
+func OptionalAuth(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	authOrAPIKey := AuthOrAPIKey(authService, apiKeyService)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authOrAPIKey(c)
+	}
+}
+
+// RequireScope rejects requests made with an API key that lacks scope.
+// Requests with an access token are not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get("apiKeyScopes"); ok && !services.HasScope(scopes.([]string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
+			return
+		}
+		c.Next()
+	}
+}
 
+// bearerToken returns the token of the Authorization header, or aborts the
+// request.
+func bearerToken(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "no authorization header"})
+		return "", false
+	}
+
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		return "", false
+	}
+	return tokenParts[1], true
+}
+
+// RequirePermission rejects requests whose role does not grant permission.
+// It must run after AuthRequired.
+func RequirePermission(permission services.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CurrentActor(c).Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
 		c.Next()
 	}
 }
+
+// CurrentActor returns the authenticated user set by AuthRequired.
+func CurrentActor(c *gin.Context) services.Actor {
+	return services.Actor{
+		UserID: c.GetUint("userID"),
+		Role:   c.GetString("userRole"),
+	}
+}
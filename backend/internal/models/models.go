@@ -5,6 +5,8 @@ package models
 import (
 	"encoding/json"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type PropertyType string
@@ -24,20 +26,65 @@ const (
 	Shared PropertyStatus = "shared"
 )
 
+// LifecycleState is the stage of a listing. PropertyService allows only
+// some transitions between them.
+type LifecycleState string
+
+const (
+	StateDraft     LifecycleState = "draft"
+	StatePublished LifecycleState = "published"
+	StateReserved  LifecycleState = "reserved"
+	StateSold      LifecycleState = "sold"
+	StateRented    LifecycleState = "rented"
+	StateArchived  LifecycleState = "archived"
+)
+
+var LifecycleStates = []LifecycleState{StateDraft, StatePublished, StateReserved, StateSold, StateRented, StateArchived}
+
+// ValidLifecycleState reports whether state is one of LifecycleStates.
+func ValidLifecycleState(state LifecycleState) bool {
+	for _, s := range LifecycleStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
 type Property struct {
-	ID           uint              `json:"id" gorm:"primaryKey"`
-	AgentCode    string            `json:"agent_code" gorm:"unique;not null"`
-	PropertyCode string            `json:"property_code" gorm:"unique;not null"`
-	PropertyType PropertyType      `json:"property_type"`
-	DealType     DealType          `json:"deal_type"`
-	Status       PropertyStatus    `json:"status"`
-	IsActive     bool              `json:"is_active" gorm:"default:true"`
-	CreatedAt    time.Time         `json:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at"`
-	Details      []PropertyDetails `json:"details" gorm:"foreignKey:PropertyID"`
-	Documents    []Document        `json:"documents" gorm:"foreignKey:PropertyID"`
-	Owner        PropertyOwner     `json:"owner" gorm:"foreignKey:PropertyID"`
-	History      []History         `json:"history" gorm:"foreignKey:PropertyID"`
+	ID              uint              `json:"id" gorm:"primaryKey"`
+	AgentCode       string            `json:"agent_code" gorm:"unique;not null"`
+	PropertyCode    string            `json:"property_code" gorm:"unique;not null"`
+	PropertyType    PropertyType      `json:"property_type"`
+	DealType        DealType          `json:"deal_type"`
+	Status          PropertyStatus    `json:"status"`
+	LifecycleState  LifecycleState    `json:"lifecycle_state" gorm:"default:draft"`
+	IsActive        bool              `json:"is_active"`
+	StateChangedAt  *time.Time        `json:"state_changed_at"`
+	PublishedAt     *time.Time        `json:"published_at"`
+	ReservedAt      *time.Time        `json:"reserved_at"`
+	ClosedAt        *time.Time        `json:"closed_at"`
+	ArchivedAt      *time.Time        `json:"archived_at"`
+	Version         int               `json:"version" gorm:"default:1"`
+	Latitude        *float64          `json:"latitude" binding:"omitempty,gte=-90,lte=90,required_with=Longitude"`
+	Longitude       *float64          `json:"longitude" binding:"omitempty,gte=-180,lte=180,required_with=Latitude"`
+	LocationSource  string            `json:"location_source" gorm:"type:varchar(20);default:null"`
+	CreatedBy       *uint             `json:"created_by"`
+	AssignedAgentID *uint             `json:"assigned_agent_id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `json:"deleted_at,omitempty"`
+	DeletedBy       *uint             `json:"deleted_by,omitempty"`
+	Details         []PropertyDetails `json:"details" gorm:"foreignKey:PropertyID"`
+	Documents       []Document        `json:"documents" gorm:"foreignKey:PropertyID"`
+	Owner           PropertyOwner     `json:"owner" gorm:"foreignKey:PropertyID"`
+	History         []History         `json:"history" gorm:"foreignKey:PropertyID"`
+
+	// Set by full-text search only
+	SearchRank float64 `json:"search_rank,omitempty" gorm:"-"`
+	Highlight  string  `json:"highlight,omitempty" gorm:"-"`
+	// Set by radius search only, in meters
+	Distance *float64 `json:"distance,omitempty" gorm:"-"`
 }
 
 // backend/internal/models/models.go
@@ -85,18 +132,38 @@ type PropertyOwner struct {
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 type Document struct {
-	ID         uint      `json:"id" gorm:"primaryKey;table:property_documents"`
-	PropertyID uint      `json:"property_id"`
-	FileType   string    `json:"file_type"`
-	FilePath   string    `json:"file_path"`
-	IsPublic   bool      `json:"is_public"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         uint              `json:"id" gorm:"primaryKey;table:property_documents"`
+	PropertyID uint              `json:"property_id"`
+	FileType   string            `json:"file_type"`
+	FilePath   string            `json:"file_path"`
+	IsPublic   bool              `json:"is_public"`
+	CreatedAt  time.Time         `json:"created_at"`
+	DeletedAt  gorm.DeletedAt    `json:"deleted_at,omitempty"`
+	DeletedBy  *uint             `json:"deleted_by,omitempty"`
+	Variants   []DocumentVariant `json:"variants,omitempty" gorm:"foreignKey:DocumentID"`
 }
 
 func (Document) TableName() string {
 	return "property_documents"
 }
 
+// DocumentVariant is a resized, metadata-free copy of an image document.
+type DocumentVariant struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DocumentID uint      `json:"document_id"`
+	Variant    string    `json:"variant"`
+	Format     string    `json:"format"`
+	FilePath   string    `json:"file_path"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (DocumentVariant) TableName() string {
+	return "document_variants"
+}
+
 type History struct {
 	ID         uint            `json:"id" gorm:"primaryKey"`
 	PropertyID uint            `json:"property_id"`
@@ -104,17 +171,246 @@ type History struct {
 	ActionDate time.Time       `json:"action_date"`
 	AgentID    uint            `json:"agent_id"`
 	Details    json.RawMessage `json:"details"`
+	// AgentEmail is only set by PropertyService.GetPropertyHistory
+	AgentEmail string `json:"agent_email,omitempty" gorm:"->;-:migration"`
 }
 
 type User struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	Email        string    `json:"email" gorm:"unique;not null"`
-	PasswordHash string    `json:"-" gorm:"not null"`
-	Role         string    `json:"role" gorm:"type:user_role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	Email             string     `json:"email" gorm:"unique;not null"`
+	PasswordHash      string     `json:"-" gorm:"not null"`
+	Role              string     `json:"role" gorm:"type:user_role"`
+	DisabledAt        *time.Time `json:"disabled_at"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	TOTPSecret        string     `json:"-" gorm:"column:totp_secret"`
+	TOTPEnabledAt     *time.Time `json:"totp_enabled_at" gorm:"column:totp_enabled_at"`
+	TOTPLastStep      int64      `json:"-" gorm:"column:totp_last_step"`
+	TwoFactorRequired bool       `json:"two_factor_required"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// AuthSession is one login of a user, see services.AuthService.
+type AuthSession struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID     uint       `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// RefreshToken stores the SHA-256 of a refresh token, never the token itself.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	SessionID string     `json:"session_id" gorm:"type:uuid"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// Purposes of email tokens
+const (
+	EmailTokenPasswordReset     = "password_reset"
+	EmailTokenEmailVerification = "email_verification"
+)
+
+// EmailToken is a single-use token sent by email, stored hashed.
+type EmailToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a one-time two-factor recovery code, stored hashed.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UsedChallenge is the ID of a two-factor login challenge that completed a
+// login, so the challenge cannot complete another. It is kept until the
+// challenge expires.
+type UsedChallenge struct {
+	ID        string `gorm:"primaryKey;type:uuid"`
+	ExpiresAt time.Time
+}
+
+// LoginAttempt records a failed login.
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginThrottle counts recent login failures for an account or IP address.
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// UserIdentity links a user to an account at the OpenID Connect provider.
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OIDCLogin is a single sign-on login in progress, see services.OIDCService.
+type OIDCLogin struct {
+	StateHash    string `gorm:"primaryKey"`
+	Nonce        string
+	CodeVerifier string
+	UserID       *uint
+	CodeHash     *string
+	ExpiresAt    time.Time
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
+
+func (OIDCLogin) TableName() string {
+	return "oidc_logins"
+}
+
+// APIKey lets a partner integration or script act as a user, limited to
+// its scopes, see services.APIKeyService.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	UserID     uint       `json:"user_id"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	RateLimit  int        `json:"rate_limit"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedBy  *uint      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
 }
 
 func (History) TableName() string {
 	return "property_history"
 }
+
+// AuditLog records an action that cannot be undone, such as a purge.
+// ActorID is nil for background jobs.
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   uint            `json:"entity_id"`
+	ActorID    *uint           `json:"actor_id"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// PropertySnapshot is the state of a property with its details and owner
+// right after a history entry.
+type PropertySnapshot struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	PropertyID uint            `json:"property_id"`
+	HistoryID  uint            `json:"history_id"`
+	Version    int             `json:"version"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Property.LocationSource values. Coordinates from the gazetteer are
+// refreshed when the address changes, manual ones are kept.
+const (
+	LocationSourceManual    = "manual"
+	LocationSourceGazetteer = "gazetteer"
+)
+
+const (
+	GazetteerSettlement = "settlement"
+	GazetteerDistrict   = "district"
+	GazetteerStreet     = "street"
+)
+
+// GazetteerPlace is a settlement, district or street from the local gazetteer.
+type GazetteerPlace struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	Kind           string          `json:"kind"`
+	ParentID       *uint           `json:"parent_id"`
+	Rank           int             `json:"rank"`
+	Latitude       float64         `json:"latitude"`
+	Longitude      float64         `json:"longitude"`
+	Source         string          `json:"source"`
+	SourceID       string          `json:"source_id"`
+	ParentSourceID string          `json:"parent_source_id" gorm:"default:null"`
+	Names          []GazetteerName `json:"names" gorm:"foreignKey:PlaceID"`
+}
+
+// GazetteerName is one spelling of a place. Normalized is used for lookups.
+type GazetteerName struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	PlaceID    uint   `json:"place_id"`
+	Language   string `json:"language"`
+	Name       string `json:"name"`
+	Normalized string `json:"normalized"`
+}
+
+// ExportJobStatus is the state of a background export.
+type ExportJobStatus string
+
+const (
+	ExportQueued    ExportJobStatus = "queued"
+	ExportRunning   ExportJobStatus = "running"
+	ExportCompleted ExportJobStatus = "completed"
+	ExportFailed    ExportJobStatus = "failed"
+	ExportCanceled  ExportJobStatus = "canceled"
+)
+
+// ExportJob is a background export of properties to a ZIP in file storage,
+// see services.ExportJobService. Processed counts the properties written
+// so far out of Total. Skipped counts the files and histories that could not
+// be read and were left out; Warnings describes the first of them.
+type ExportJob struct {
+	ID          string          `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID      uint            `json:"user_id"`
+	Status      ExportJobStatus `json:"status"`
+	Language    string          `json:"language"`
+	PropertyIDs json.RawMessage `json:"property_ids"`
+	Total       int             `json:"total"`
+	Processed   int             `json:"processed"`
+	Skipped     int             `json:"skipped"`
+	Warnings    json.RawMessage `json:"warnings"`
+	ResultKey   string          `json:"-" gorm:"default:null"`
+	ResultSize  int64           `json:"result_size"`
+	Error       string          `json:"error,omitempty" gorm:"default:null"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	StartedAt   *time.Time      `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+	ExpiresAt   *time.Time      `json:"expires_at"`
+}
@@ -0,0 +1,306 @@
+// backend/internal/oidctest/provider.go
+
+// Package oidctest is an OpenID Connect provider for trying and testing
+// single sign-on. Its login page asks for an email and groups instead of a
+// password, and the same email always gets the same subject. It keeps
+// everything in memory and supports only the authorization code flow with
+// PKCE (S256).
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock"
+
+// grant is an issued authorization code or access token.
+type grant struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	groups        []string
+	expiresAt     time.Time
+}
+
+// Provider is an in-memory OpenID Connect provider.
+type Provider struct {
+	issuer      string
+	internalURL string
+	clientID    string
+	secret      string
+	key         *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]grant
+	tokens map[string]grant
+}
+
+// New returns a provider for issuer that accepts clientID, and secret if it
+// is not empty. internalURL is the base URL of the token, userinfo and JWKS
+// endpoints; if empty, it is the issuer.
+func New(issuer, internalURL, clientID, secret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		issuer:      strings.TrimRight(issuer, "/"),
+		internalURL: strings.TrimRight(internalURL, "/"),
+		clientID:    clientID,
+		secret:      secret,
+		key:         key,
+		codes:       make(map[string]grant),
+		tokens:      make(map[string]grant),
+	}
+	if p.internalURL == "" {
+		p.internalURL = p.issuer
+	}
+	return p, nil
+}
+
+// Handler serves the provider's endpoints.
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.userinfo)
+	mux.HandleFunc("/jwks", p.jwks)
+	return mux
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.internalURL + "/token",
+		"userinfo_endpoint":                     p.internalURL + "/userinfo",
+		"jwks_uri":                              p.internalURL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+		"claims_supported":                      []string{"sub", "email", "email_verified", "groups"},
+	})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OIDC login</title></head>
+<body style="font-family: sans-serif; max-width: 24rem; margin: 4rem auto">
+<h2>Mock OIDC login</h2>
+<form method="post">
+{{range $name, $value := .}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Email<br><input name="email" type="email" required autofocus style="width: 100%"></label></p>
+<p><label>Groups, comma-separated<br><input name="groups" style="width: 100%"></label></p>
+<p><button type="submit" name="action" value="allow">Log in</button>
+<button type="submit" name="action" value="deny">Deny</button></p>
+</form>
+</body>
+</html>
+`))
+
+// authorize shows the login form and, when it is submitted, redirects back
+// to the client with a code.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := map[string]string{}
+	for _, name := range []string{"client_id", "redirect_uri", "response_type", "state", "nonce", "code_challenge", "code_challenge_method", "scope"} {
+		params[name] = r.Form.Get(name)
+	}
+	switch {
+	case params["client_id"] != p.clientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case params["response_type"] != "code":
+		http.Error(w, "only response_type=code is supported", http.StatusBadRequest)
+		return
+	case params["code_challenge_method"] != "S256" || params["code_challenge"] == "":
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(params["redirect_uri"])
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		loginPage.Execute(w, params)
+		return
+	}
+
+	query := redirectURI.Query()
+	query.Set("state", params["state"])
+	if r.Form.Get("action") != "allow" {
+		query.Set("error", "access_denied")
+	} else {
+		code := randomString()
+		p.mu.Lock()
+		p.codes[code] = grant{
+			clientID:      params["client_id"],
+			redirectURI:   params["redirect_uri"],
+			codeChallenge: params["code_challenge"],
+			nonce:         params["nonce"],
+			email:         strings.TrimSpace(r.Form.Get("email")),
+			groups:        splitGroups(r.Form.Get("groups")),
+			expiresAt:     time.Now().Add(time.Minute),
+		}
+		p.mu.Unlock()
+		query.Set("code", code)
+	}
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if clientID != p.clientID || (p.secret != "" && secret != p.secret) {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if r.Form.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	code := r.Form.Get("code")
+	p.mu.Lock()
+	g, found := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !found || time.Now().After(g.expiresAt) || g.clientID != clientID ||
+		g.redirectURI != r.Form.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != g.codeChallenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := p.claims(g)
+	claims["iss"] = p.issuer
+	claims["aud"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessToken := randomString()
+	g.expiresAt = now.Add(time.Hour)
+	p.mu.Lock()
+	p.tokens[accessToken] = g
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func (p *Provider) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	g, found := p.tokens[token]
+	p.mu.Unlock()
+	if !found || time.Now().After(g.expiresAt) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.claims(g))
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// claims are the user claims. The subject is derived from the email, so
+// the same email always logs in as the same account.
+func (p *Provider) claims(g grant) jwt.MapClaims {
+	sum := sha256.Sum256([]byte(strings.ToLower(g.email)))
+	return jwt.MapClaims{
+		"sub":            "mock-" + hex.EncodeToString(sum[:8]),
+		"email":          g.email,
+		"email_verified": true,
+		"groups":         g.groups,
+	}
+}
+
+func splitGroups(value string) []string {
+	groups := []string{}
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func randomString() string {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		log.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
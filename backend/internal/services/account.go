@@ -0,0 +1,280 @@
+// backend/internal/services/account.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/mail"
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrInvalidEmailToken = errors.New("invalid or expired link")
+	ErrEmailNotVerified  = errors.New("email address is not verified")
+)
+
+const (
+	PasswordResetTTL     = time.Hour
+	EmailVerificationTTL = 48 * time.Hour
+	// emailResendInterval limits how often one user is mailed a new token
+	emailResendInterval = time.Minute
+	// backgroundMailTimeout bounds a mail sent after the response
+	backgroundMailTimeout = time.Minute
+)
+
+// AccountService sends password reset and email verification links and
+// redeems their tokens.
+type AccountService struct {
+	db     *gorm.DB
+	mailer mail.Mailer
+	// appURL is the frontend address used in links, e.g. https://kuckuc.rs
+	appURL string
+
+	background sync.WaitGroup
+}
+
+func NewAccountService(db *gorm.DB, mailer mail.Mailer, appURL string) *AccountService {
+	return &AccountService{
+		db:     db,
+		mailer: mailer,
+		appURL: strings.TrimRight(appURL, "/"),
+	}
+}
+
+// RequestPasswordReset mails a reset link if the email belongs to an active
+// user. It reports nothing about whether the account exists: the lookup and
+// the mail happen in the background, so the caller returns as fast either
+// way.
+func (s *AccountService) RequestPasswordReset(email string) {
+	s.inBackground("password reset", func(ctx context.Context) error {
+		return s.sendPasswordReset(ctx, email)
+	})
+}
+
+func (s *AccountService) sendPasswordReset(ctx context.Context, email string) error {
+	var user models.User
+	err := s.db.Where("LOWER(email) = LOWER(?) AND disabled_at IS NULL", strings.TrimSpace(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(user.ID, models.EmailTokenPasswordReset, PasswordResetTTL)
+	if err != nil || token == "" {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Kuckuc password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your Kuckuc account.\n\n"+
+			"Open this link to choose a new password:\n%s\n\n"+
+			"The link is valid for %d minutes and can be used once. "+
+			"If you did not ask for this, ignore this email.\n",
+			s.link("/reset-password", token), int(PasswordResetTTL.Minutes())),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset.
+// All sessions of the user are revoked, and the email counts as verified.
+func (s *AccountService) ResetPassword(token, password string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		emailToken, err := redeemToken(tx, token, models.EmailTokenPasswordReset)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, emailToken.UserID).Error; err != nil {
+			return ErrInvalidEmailToken
+		}
+		if user.DisabledAt != nil {
+			return ErrInvalidEmailToken
+		}
+		if err := ValidatePassword(password, user.Email); err != nil {
+			return err
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		user.PasswordHash = string(hash)
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		// Other reset links of the user are spent as well
+		if err := tx.Model(&models.EmailToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, models.EmailTokenPasswordReset).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID)
+	})
+}
+
+// SendVerification mails an email verification link to an unverified user.
+func (s *AccountService) SendVerification(ctx context.Context, userID uint) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.issueToken(user.ID, models.EmailTokenEmailVerification, EmailVerificationTTL)
+	if err != nil || token == "" {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your Kuckuc email address",
+		Text: fmt.Sprintf("A Kuckuc account was created for %s.\n\n"+
+			"Open this link to confirm your email address:\n%s\n\n"+
+			"The link is valid for %d hours.\n",
+			user.Email, s.link("/verify-email", token), int(EmailVerificationTTL.Hours())),
+	})
+}
+
+// ResendVerification is the public variant of SendVerification, keyed by
+// email. Like RequestPasswordReset it runs in the background and does not
+// reveal whether the account exists.
+func (s *AccountService) ResendVerification(email string) {
+	s.inBackground("verification", func(ctx context.Context) error {
+		return s.resendVerification(ctx, email)
+	})
+}
+
+func (s *AccountService) resendVerification(ctx context.Context, email string) error {
+	var user models.User
+	err := s.db.Where("LOWER(email) = LOWER(?) AND disabled_at IS NULL AND email_verified_at IS NULL", strings.TrimSpace(email)).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.SendVerification(ctx, user.ID)
+}
+
+// inBackground runs send after the response. Failures are only logged.
+func (s *AccountService) inBackground(what string, send func(ctx context.Context) error) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundMailTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("Failed to send %s email: %v", what, err)
+		}
+	}()
+}
+
+// Wait waits for the mails sent in the background, or until ctx is done.
+func (s *AccountService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// VerifyEmail marks the email of the token's user as verified.
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		emailToken, err := redeemToken(tx, token, models.EmailTokenEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", emailToken.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+}
+
+// issueToken stores a new token for the user. It returns an empty token
+// without error if one was issued less than emailResendInterval ago, so the
+// endpoints cannot be used to flood a mailbox.
+func (s *AccountService) issueToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	var recent int64
+	if err := s.db.Model(&models.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, time.Now().Add(-emailResendInterval)).
+		Count(&recent).Error; err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		log.Printf("Not sending another %s email to user %d so soon", purpose, userID)
+		return "", nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.db.Create(&models.EmailToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemToken marks an unused, unexpired token as used and returns it.
+func redeemToken(tx *gorm.DB, token, purpose string) (*models.EmailToken, error) {
+	var emailToken models.EmailToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+		First(&emailToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidEmailToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if emailToken.UsedAt != nil || time.Now().After(emailToken.ExpiresAt) {
+		return nil, ErrInvalidEmailToken
+	}
+
+	now := time.Now()
+	emailToken.UsedAt = &now
+	if err := tx.Model(&emailToken).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &emailToken, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.appURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
@@ -0,0 +1,92 @@
+// backend/internal/services/account_test.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"kuckuc/internal/mail"
+)
+
+// recordingMailer keeps the messages it is asked to send.
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// tokenFrom returns the token of the link in a message.
+func tokenFrom(t *testing.T, msg mail.Message) string {
+	t.Helper()
+	for _, field := range strings.Fields(msg.Text) {
+		if link, err := url.Parse(field); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link in %q", msg.Text)
+	return ""
+}
+
+func TestAccountLink(t *testing.T) {
+	s := NewAccountService(nil, nil, "https://kuckuc.rs/")
+	got := s.link("/reset-password", "a+b/c=")
+	if want := "https://kuckuc.rs/reset-password?token=a%2Bb%2Fc%3D"; got != want {
+		t.Errorf("link = %q, want %q", got, want)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	mailer := &recordingMailer{}
+	accounts := NewAccountService(db, mailer, "https://kuckuc.rs")
+	ctx := context.Background()
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	pair, err := auth.Login(user.Email, testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown addresses are not told apart, and a second request within a
+	// minute is not mailed
+	for _, email := range []string{"nobody@kuckuc.rs", " Agent@Kuckuc.rs ", user.Email} {
+		accounts.RequestPasswordReset(email)
+		if err := accounts.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mailer.messages) != 1 || mailer.messages[0].To != user.Email {
+		t.Fatalf("messages = %+v, want one to %s", mailer.messages, user.Email)
+	}
+	token := tokenFrom(t, mailer.messages[0])
+
+	if err := accounts.ResetPassword(token, "short1"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak password: error = %v, want ErrWeakPassword", err)
+	}
+	// The failed attempt rolled back, so the token still works
+	if err := accounts.ResetPassword(token, "rainy5terrace"); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.ResetPassword(token, "windy6garden"); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("token used twice: error = %v, want ErrInvalidEmailToken", err)
+	}
+
+	if _, err := auth.Refresh(pair.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session survived the reset: error = %v", err)
+	}
+	if _, err := auth.Login(user.Email, "rainy5terrace", ClientInfo{}); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// backend/internal/services/apikeys.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("invalid API key scope")
+	// ErrInvalidAPIKeySettings is wrapped with the reason
+	ErrInvalidAPIKeySettings = errors.New("invalid API key settings")
+)
+
+// API key scopes. A request with a key needs the route's scope in addition
+// to the permissions of the key's user.
+const (
+	ScopePropertiesRead   = "properties:read"
+	ScopePropertiesWrite  = "properties:write"
+	ScopeFilesUpload      = "files:upload"
+	ScopePropertiesExport = "properties:export"
+)
+
+var APIKeyScopes = []string{ScopePropertiesRead, ScopePropertiesWrite, ScopeFilesUpload, ScopePropertiesExport}
+
+const (
+	// APIKeyPrefix starts every key, so keys are told apart from access
+	// tokens and easy to find in leaked code
+	APIKeyPrefix = "kuckuc_"
+	// DefaultAPIKeyRateLimit is in requests per minute
+	DefaultAPIKeyRateLimit = 60
+	// apiKeyUsageInterval limits how often last_used_at is written
+	apiKeyUsageInterval = time.Minute
+	// apiKeyLimiterIdle is how long an unused rate limiter is kept. A
+	// limiter refills its whole burst, one minute of requests, in a minute,
+	// so after that it is no different from a new one.
+	apiKeyLimiterIdle = time.Minute
+)
+
+// APIKeyService manages API keys and authenticates requests made with them.
+//
+// Rate limits are counted in memory by each API replica, so with N replicas
+// behind a load balancer a key can make up to N times its rate_limit. Keys
+// unused for a minute are forgotten, which resets nothing, since their
+// limit has refilled by then.
+type APIKeyService struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	limiters  map[uint]*apiKeyLimiter
+	lastSweep time.Time
+}
+
+type apiKeyLimiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{
+		db:       db,
+		limiters: make(map[uint]*apiKeyLimiter),
+	}
+}
+
+type CreateAPIKeyInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// UserID is the user the key acts as; it defaults to the creator
+	UserID    uint       `json:"user_id"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// RateLimit is in requests per minute
+	RateLimit int `json:"rate_limit"`
+}
+
+// CreatedAPIKey is returned once when a key is created; only its hash is
+// stored.
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// Create issues a new key for input.UserID, created by createdBy.
+func (s *APIKeyService) Create(input CreateAPIKeyInput, createdBy uint) (*CreatedAPIKey, error) {
+	if len(input.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, scope := range input.Scopes {
+		if !HasScope(APIKeyScopes, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeySettings)
+	}
+	if input.RateLimit < 0 {
+		return nil, fmt.Errorf("%w: rate_limit must be positive", ErrInvalidAPIKeySettings)
+	}
+	if input.RateLimit == 0 {
+		input.RateLimit = DefaultAPIKeyRateLimit
+	}
+	if input.UserID == 0 {
+		input.UserID = createdBy
+	}
+
+	var user models.User
+	if err := s.db.First(&user, input.UserID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrUserDisabled
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	key := APIKeyPrefix + secret
+	apiKey := models.APIKey{
+		Name:      strings.TrimSpace(input.Name),
+		Prefix:    key[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(key),
+		UserID:    user.ID,
+		Scopes:    input.Scopes,
+		RateLimit: input.RateLimit,
+		ExpiresAt: input.ExpiresAt,
+		CreatedBy: &createdBy,
+	}
+	if err := s.db.Create(&apiKey).Error; err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// List returns all keys, newest first.
+func (s *APIKeyService) List() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke disables a key immediately.
+func (s *APIKeyService) Revoke(id uint) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.First(&apiKey, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := s.db.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.limiters, apiKey.ID)
+	s.mu.Unlock()
+	return &apiKey, nil
+}
+
+// Authenticate returns the key and its user. Revoked and expired keys and
+// keys of disabled users are rejected with ErrInvalidAPIKey.
+func (s *APIKeyService) Authenticate(key, ip string) (*models.APIKey, *models.User, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	var apiKey models.APIKey
+	err := s.db.Where("key_hash = ? AND revoked_at IS NULL", hashToken(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "email", "role", "disabled_at").First(&user, apiKey.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// Busy keys would otherwise write on every request
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyUsageInterval || apiKey.LastUsedIP != ip {
+		if err := s.db.Model(&apiKey).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return &apiKey, &user, nil
+}
+
+// Allow takes one request from the key's rate limit. If none is left it
+// returns false and how long to wait.
+func (s *APIKeyService) Allow(apiKey *models.APIKey) (bool, time.Duration) {
+	return s.allowAt(apiKey, time.Now())
+}
+
+func (s *APIKeyService) allowAt(apiKey *models.APIKey, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) > apiKeyLimiterIdle {
+		for id, limiter := range s.limiters {
+			if now.Sub(limiter.lastUsed) > apiKeyLimiterIdle {
+				delete(s.limiters, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	limiter, ok := s.limiters[apiKey.ID]
+	if !ok {
+		limiter = &apiKeyLimiter{Limiter: rate.NewLimiter(rate.Limit(float64(apiKey.RateLimit)/60), apiKey.RateLimit)}
+		s.limiters[apiKey.ID] = limiter
+	}
+	limiter.lastUsed = now
+	s.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// HasScope reports whether scopes contains scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
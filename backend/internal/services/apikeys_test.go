@@ -0,0 +1,99 @@
+// backend/internal/services/apikeys_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kuckuc/internal/models"
+)
+
+func TestAPIKeyAllow(t *testing.T) {
+	s := NewAPIKeyService(nil)
+	key := &models.APIKey{ID: 1, RateLimit: 3}
+	other := &models.APIKey{ID: 2, RateLimit: 3}
+	start := time.Now()
+
+	for i := 0; i < key.RateLimit; i++ {
+		if ok, _ := s.allowAt(key, start); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	ok, retryAfter := s.allowAt(key, start)
+	if ok || retryAfter <= 0 || retryAfter > 20*time.Second {
+		t.Errorf("request over the limit: %v, retry after %s", ok, retryAfter)
+	}
+	if ok, _ := s.allowAt(other, start); !ok {
+		t.Error("another key was limited")
+	}
+
+	// One request per 20 seconds comes back
+	if ok, _ := s.allowAt(key, start.Add(20*time.Second)); !ok {
+		t.Error("refilled request refused")
+	}
+	if ok, _ := s.allowAt(key, start.Add(20*time.Second)); ok {
+		t.Error("more than the refill allowed")
+	}
+}
+
+func TestAPIKeyLimiterEviction(t *testing.T) {
+	s := NewAPIKeyService(nil)
+	idle := &models.APIKey{ID: 1, RateLimit: 2}
+	busy := &models.APIKey{ID: 2, RateLimit: 2}
+	start := time.Now()
+
+	s.allowAt(idle, start)
+	s.allowAt(busy, start)
+	for at := start.Add(30 * time.Second); at.Before(start.Add(2 * apiKeyLimiterIdle)); at = at.Add(30 * time.Second) {
+		s.allowAt(busy, at)
+	}
+
+	if _, ok := s.limiters[idle.ID]; ok {
+		t.Error("idle limiter kept")
+	}
+	if _, ok := s.limiters[busy.ID]; !ok {
+		t.Error("busy limiter evicted")
+	}
+
+	// An evicted key starts with a full limit, as it would have anyway
+	later := start.Add(3 * apiKeyLimiterIdle)
+	for i := 0; i < idle.RateLimit; i++ {
+		if ok, _ := s.allowAt(idle, later); !ok {
+			t.Fatalf("request %d after eviction refused", i+1)
+		}
+	}
+	if ok, _ := s.allowAt(idle, later); ok {
+		t.Error("limit not applied after eviction")
+	}
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewAPIKeyService(db)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		input   CreateAPIKeyInput
+		wantErr error
+	}{
+		{"no scopes", CreateAPIKeyInput{Name: "crm"}, ErrInvalidScope},
+		{"unknown scope", CreateAPIKeyInput{Name: "crm", Scopes: []string{ScopePropertiesRead, "users:manage"}}, ErrInvalidScope},
+		{"expired", CreateAPIKeyInput{Name: "crm", Scopes: []string{ScopePropertiesRead}, ExpiresAt: &past}, ErrInvalidAPIKeySettings},
+		{"negative rate limit", CreateAPIKeyInput{Name: "crm", Scopes: []string{ScopePropertiesRead}, RateLimit: -1}, ErrInvalidAPIKeySettings},
+	}
+	for _, tt := range tests {
+		if _, err := s.Create(tt.input, 1); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if len(*statements) > 0 {
+		t.Errorf("invalid keys reached the database: %q", *statements)
+	}
+
+	if _, _, err := s.Authenticate("Bearer abc", "127.0.0.1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("key without prefix: error = %v, want ErrInvalidAPIKey", err)
+	}
+}
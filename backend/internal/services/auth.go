@@ -7,79 +7,251 @@ import (
 	"kuckuc/internal/models"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Reasons stored with failed login attempts
+const (
+	LoginFailedUnknownEmail  = "unknown_email"
+	LoginFailedWrongPassword = "wrong_password"
+	LoginFailedDisabled      = "disabled"
+	LoginFailedThrottled     = "throttled"
+	LoginFailedUnverified    = "unverified"
+)
+
 type AuthService struct {
-	db        *gorm.DB
-	jwtSecret []byte
+	db      *gorm.DB
+	keys    *KeySet
+	limiter *LoginLimiter
+	// issuer and audience are the iss and aud claims of access tokens
+	issuer   string
+	audience string
+	// totpIssuer names the account in authenticator apps
+	totpIssuer string
 }
 
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
-	jwt.StandardClaims
+	// SessionID ties the token to an auth session, so revoking the session
+	// invalidates the token before it expires
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
+// tokenLeeway is the clock skew allowed when checking exp, nbf and iat
+const tokenLeeway = 30 * time.Second
+
+// NewAuthService creates the auth service. keys signs and verifies tokens
+// and limiter throttles Login; both may be nil for tools that never log
+// users in.
+func NewAuthService(db *gorm.DB, keys *KeySet, limiter *LoginLimiter) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: []byte(os.Getenv("JWT_SECRET")),
+		db:         db,
+		keys:       keys,
+		limiter:    limiter,
+		issuer:     envOr("JWT_ISSUER", "kuckuc"),
+		audience:   envOr("JWT_AUDIENCE", "kuckuc-api"),
+		totpIssuer: envOr("TOTP_ISSUER", "Kuckuc"),
 	}
 }
 
-func (s *AuthService) Login(email, password string) (string, error) {
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// dummyPasswordHash is checked for unknown emails. It has the cost of real
+// password hashes and matches no password a user can type.
+var dummyPasswordHash = []byte("$2a$10$PDAD.zHP2SsyhCKqbkDCCucwWl5L8UrdE5JY2rFS2m.9WOQQK0R0a")
+
+// Login checks the credentials and starts a new session. Accounts with
+// two-factor authentication get a challenge for VerifyLogin instead. Repeated
+// failures make it return a *LoginThrottledError without checking the
+// password.
+func (s *AuthService) Login(email, password string, client ClientInfo) (*LoginResult, error) {
+	// Emails are stored as CreateUser normalizes them
+	email = strings.ToLower(strings.TrimSpace(email))
+	if s.limiter != nil {
+		if err := s.limiter.Check(email, client.IPAddress); err != nil {
+			s.recordFailedLogin(email, client, LoginFailedThrottled)
+			return nil, err
+		}
+	}
+
 	var user models.User
 	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
-		log.Printf("User not found: %v", err)
-		return "", errors.New("invalid credentials")
+		// Takes as long as a wrong password, so response times do not tell
+		// which emails have accounts
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, s.loginFailed(email, client, LoginFailedUnknownEmail)
 	}
 
-	log.Printf("Found user with hash: %s", user.PasswordHash)
-
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		log.Printf("Password comparison failed: %v", err)
-		return "", errors.New("invalid credentials")
+		return nil, s.loginFailed(email, client, LoginFailedWrongPassword)
 	}
 
-	// Create token
-	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-		},
+	if user.DisabledAt != nil {
+		log.Printf("Login refused for disabled user %d", user.ID)
+		return nil, s.loginFailed(email, client, LoginFailedDisabled)
+	}
+
+	// The password was right, so saying why the login fails leaks nothing
+	if user.EmailVerifiedAt == nil {
+		s.recordFailedLogin(email, client, LoginFailedUnverified)
+		return nil, ErrEmailNotVerified
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.Succeed(email); err != nil {
+			log.Printf("Failed to reset login failures of user %d: %v", user.ID, err)
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
+	result, err := s.completeLogin(&user, client)
 	if err != nil {
 		log.Printf("Token generation failed: %v", err)
-		return "", err
+		return nil, err
 	}
 
-	log.Printf("Token generated successfully")
-	return tokenString, nil
+	return result, nil
 }
 
-func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.jwtSecret, nil
-	})
+// loginFailed records a failed attempt, counts it against the account and
+// the IP address and returns ErrInvalidCredentials.
+func (s *AuthService) loginFailed(email string, client ClientInfo, reason string) error {
+	s.recordFailedLogin(email, client, reason)
+	if s.limiter != nil {
+		if err := s.limiter.Fail(email, client.IPAddress); err != nil {
+			log.Printf("Failed to count login failure: %v", err)
+		}
+	}
+	return ErrInvalidCredentials
+}
 
-	if err != nil {
+func (s *AuthService) recordFailedLogin(email string, client ClientInfo, reason string) {
+	attempt := models.LoginAttempt{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	}
+	if err := s.db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// LoginLimiter returns the limiter passed to NewAuthService.
+func (s *AuthService) LoginLimiter() *LoginLimiter {
+	return s.limiter
+}
+
+// ListFailedLogins returns the most recent failed logins, optionally only
+// those for one email or IP address.
+func (s *AuthService) ListFailedLogins(email, ip string, limit int) ([]models.LoginAttempt, error) {
+	query := s.db.Order("created_at DESC").Limit(limit)
+	if email != "" {
+		query = query.Where("LOWER(email) = LOWER(?)", strings.TrimSpace(email))
+	}
+	if ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Find(&attempts).Error; err != nil {
 		return nil, err
 	}
+	return attempts, nil
+}
+
+// accessToken signs a short-lived access token for the session.
+func (s *AuthService) accessToken(user *models.User, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL)
+	claims := &Claims{
+		UserID:           user.ID,
+		Email:            user.Email,
+		Role:             user.Role,
+		SessionID:        sessionID,
+		RegisteredClaims: s.registeredClaims(user.ID, s.audience, now, expiresAt),
+	}
 
+	tokenString, err := s.keys.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+func (s *AuthService) registeredClaims(userID uint, audience string, now, expiresAt time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		ID:        uuid.New().String(),
+	}
+}
+
+// parseToken verifies the signature and the registered claims of a token
+// signed by s.keys for audience.
+func (s *AuthService) parseToken(tokenString, audience string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.verificationKey,
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(tokenLeeway),
+	)
+	if err != nil {
+		return err
+	}
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// JWKS returns the public keys of the access tokens.
+func (s *AuthService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := s.parseToken(tokenString, s.audience, claims); err != nil {
+		return nil, err
+	}
+
+	// Disabled or deleted users are rejected immediately, and role changes
+	// apply without a new login
+	var user models.User
+	if err := s.db.Select("id", "role", "disabled_at").First(&user, claims.UserID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrUserDisabled
+	}
+	claims.Role = user.Role
+
+	// So are tokens of sessions that were logged out
+	if err := s.checkSession(claims.SessionID, claims.UserID); err != nil {
+		return nil, err
 	}
 
 	return claims, nil
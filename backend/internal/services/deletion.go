@@ -0,0 +1,169 @@
+// backend/internal/services/deletion.go
+
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	// ErrNotDeleted is returned when restoring a property or document that
+	// is not deleted
+	ErrNotDeleted       = errors.New("not deleted")
+	ErrDocumentNotFound = errors.New("file not found")
+)
+
+// DeleteProperty marks a property deleted. It disappears from all lists and
+// lookups, but it is kept with its details, documents, files and history
+// until the retention job purges it, and it can be restored until then.
+// version is only checked if it is not 0.
+func (s *PropertyService) DeleteProperty(id uint, version int, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.lockForEdit(tx, actor, id, version)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.Property{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": now,
+			"deleted_by": actor.UserID,
+			"version":    existing.Version + 1,
+		}).Error; err != nil {
+			return err
+		}
+
+		return recordHistory(tx, id, HistoryDelete, actor, historyDetails{
+			Action:  "property_deleted",
+			Changes: []FieldChange{{Field: "deleted_at", After: now}},
+		})
+	})
+}
+
+// RestoreDeletedProperty undoes DeleteProperty. The property comes back in
+// the lifecycle state it was deleted in.
+func (s *PropertyService) RestoreDeletedProperty(id uint, actor Actor) (*models.Property, error) {
+	var property models.Property
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Property
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, id).Error; err != nil {
+			return err
+		}
+		if _, err := s.authorizeEdit(tx.Unscoped(), actor, id); err != nil {
+			return err
+		}
+		if !existing.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+
+		if err := tx.Unscoped().Model(&models.Property{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+			"version":    existing.Version + 1,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := recordHistory(tx, id, HistoryUndelete, actor, historyDetails{
+			Action:  "property_undeleted",
+			Changes: []FieldChange{{Field: "deleted_at", Before: existing.DeletedAt.Time}},
+		}); err != nil {
+			return err
+		}
+
+		return tx.Preload("Details").First(&property, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &property, nil
+}
+
+// ListDeletedProperties returns the deleted properties actor may restore,
+// most recently deleted first, with their details in language.
+func (s *PropertyService) ListDeletedProperties(actor Actor, language string) ([]models.Property, error) {
+	if !actor.Can(PermPropertiesEditOwn) && !actor.Can(PermPropertiesEditAny) {
+		return nil, ErrForbidden
+	}
+
+	query := s.db.Unscoped().
+		Preload("Details", "language = ?", language).
+		Where("deleted_at IS NOT NULL")
+	if !actor.Can(PermPropertiesEditAny) {
+		query = query.Where("created_by = ? OR assigned_agent_id = ?", actor.UserID, actor.UserID)
+	}
+
+	var properties []models.Property
+	if err := query.Order("deleted_at DESC, id DESC").Find(&properties).Error; err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+// ListDeletedDocuments returns the deleted documents of a property, most
+// recently deleted first. Documents of a deleted property are listed once
+// the property is restored.
+func (s *PropertyService) ListDeletedDocuments(propertyID uint, actor Actor) ([]models.Document, error) {
+	if _, err := s.authorizeEdit(s.db, actor, propertyID); err != nil {
+		return nil, err
+	}
+
+	var documents []models.Document
+	if err := s.db.Unscoped().Preload("Variants").
+		Where("property_id = ? AND deleted_at IS NOT NULL", propertyID).
+		Order("deleted_at DESC, id DESC").
+		Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// RestoreDocument undoes DeleteDocument.
+func (s *PropertyService) RestoreDocument(propertyID, documentID uint, actor Actor) (*models.Document, error) {
+	var document models.Document
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.authorizeEdit(tx, actor, propertyID); err != nil {
+			return err
+		}
+
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND property_id = ?", documentID, propertyID).
+			First(&document).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDocumentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !document.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+
+		if err := tx.Unscoped().Model(&document).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := recordHistory(tx, propertyID, HistoryDocumentRestore, actor, historyDetails{
+			Action:     "document_restored",
+			DocumentID: document.ID,
+			Changes:    []FieldChange{{Field: "document", After: documentSummary(&document)}},
+		}); err != nil {
+			return err
+		}
+
+		return tx.Preload("Variants").First(&document, documentID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
@@ -4,15 +4,12 @@ package services
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"fmt"
-
-	//   "io"
+	"io"
 	"log"
-	"os"
 	"path/filepath"
 
-	//   "time"
-
 	"kuckuc/internal/models"
 
 	"github.com/xuri/excelize/v2"
@@ -23,30 +20,27 @@ type ExportService struct {
 	fileService     *FileService
 }
 
-type ExportResult struct {
-	ExcelData []byte
-	ZipData   []byte
-}
-
 func NewExportService(propertyService *PropertyService, fileService *FileService) *ExportService {
 	return &ExportService{
 		propertyService: propertyService,
 		fileService:     fileService,
 	}
 }
-func (s *ExportService) addPropertyFiles(w *zip.Writer, prop models.Property, propDir string) error {
+
+// addPropertyFiles copies the documents of a property into the archive. It
+// returns a warning for every document that could not be copied; an error
+// means the export must stop.
+func (s *ExportService) addPropertyFiles(ctx context.Context, w *zip.Writer, prop models.Property, propDir string) ([]string, error) {
 	docs, err := s.propertyService.GetAllDocuments(prop.ID)
 	if err != nil {
-		return err
+		return []string{fmt.Sprintf("%s: files not exported: %v", prop.PropertyCode, err)}, nil
 	}
 
+	var warnings []string
 	for _, doc := range docs {
-		filePath := s.fileService.GetFilePath(doc.FilePath)
-		fileData, err := os.ReadFile(filePath)
-		if err != nil {
-			continue
+		if err := ctx.Err(); err != nil {
+			return warnings, err
 		}
-
 		fileName := fmt.Sprintf("%s/%s_%s_%v",
 			propDir,
 			doc.FileType,
@@ -54,20 +48,33 @@ func (s *ExportService) addPropertyFiles(w *zip.Writer, prop models.Property, pr
 			doc.IsPublic,
 		)
 
-		fileWriter, err := w.Create(fileName)
-		if err != nil {
-			continue
+		if err := s.copyDocument(w, doc, fileName); err != nil {
+			log.Printf("Error adding file %s to export: %v", doc.FilePath, err)
+			warnings = append(warnings, fmt.Sprintf("%s: file %s not exported: %v", prop.PropertyCode, filepath.Base(doc.FilePath), err))
 		}
+	}
+	return warnings, nil
+}
 
-		if _, err := fileWriter.Write(fileData); err != nil {
-			continue
-		}
+// copyDocument streams a stored file into the archive without buffering it in memory.
+func (s *ExportService) copyDocument(w *zip.Writer, doc models.Document, fileName string) error {
+	src, _, err := s.fileService.OpenFile(doc.FilePath)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer src.Close()
+
+	fileWriter, err := w.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fileWriter, src)
+	return err
 }
 
 func (s *ExportService) addPropertyHistory(w *zip.Writer, prop models.Property, propDir string) error {
-	history, err := s.propertyService.GetPropertyHistory(prop.ID)
+	history, err := s.propertyService.propertyHistory(prop.ID, HistoryFilter{})
 	if err != nil {
 		return err
 	}
@@ -91,151 +98,70 @@ func (s *ExportService) addPropertyHistory(w *zip.Writer, prop models.Property,
 	_, err = historyWriter.Write(historyContent.Bytes())
 	return err
 }
-func (s *ExportService) ExportProperties(filter PropertyFilter, language string, propertyIDs []uint) ([]byte, error) {
-	log.Printf("Starting export for properties: %v", propertyIDs)
-	properties, err := s.propertyService.ListPropertiesByIDs(propertyIDs, language)
-	if err != nil {
-		log.Printf("Error listing properties: %v", err)
-		return nil, err
-	}
 
-	log.Printf("Found %d properties", len(properties))
-	if len(properties) == 0 {
-		return nil, fmt.Errorf("no properties found")
-	}
-
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
+// WriteExport writes a ZIP with properties_export.xlsx and the files and
+// history of every property to w. progress is called after each property
+// with the number written so far and a warning for each of its files or
+// history that was left out; an error from it, or ctx being done, stops
+// the export.
+func (s *ExportService) WriteExport(ctx context.Context, w io.Writer, properties []models.Property, language string, progress func(done int, warnings []string) error) error {
+	zipWriter := zip.NewWriter(w)
 
 	log.Printf("Creating Excel file...")
 	excelData, err := s.createExcelFile(properties, language)
 	if err != nil {
 		log.Printf("Excel creation error: %v", err)
-		return nil, err
+		return err
 	}
 	log.Printf("Excel file created, size: %d bytes", len(excelData))
 
 	excelWriter, err := zipWriter.Create("properties_export.xlsx")
 	if err != nil {
-		return nil, fmt.Errorf("excel file creation error: %w", err)
+		return fmt.Errorf("excel file creation error: %w", err)
 	}
 
 	_, err = excelWriter.Write(excelData)
 	if err != nil {
-		return nil, fmt.Errorf("excel write error: %w", err)
+		return fmt.Errorf("excel write error: %w", err)
 	}
 
 	// Добавляем файлы и историю для каждого объекта
-	for _, prop := range properties {
+	for i, prop := range properties {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		propDir := fmt.Sprintf("files/%s_%s", prop.PropertyCode, prop.AgentCode)
 
-		if err := s.addPropertyFiles(zipWriter, prop, propDir); err != nil {
-			log.Printf("Error adding files for property %s: %v", prop.PropertyCode, err)
-			continue
+		warnings, err := s.addPropertyFiles(ctx, zipWriter, prop, propDir)
+		if err != nil {
+			return err
 		}
-
 		if err := s.addPropertyHistory(zipWriter, prop, propDir); err != nil {
 			log.Printf("Error adding history for property %s: %v", prop.PropertyCode, err)
-			continue
+			warnings = append(warnings, fmt.Sprintf("%s: history not exported: %v", prop.PropertyCode, err))
 		}
-	}
 
-	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("zip close error: %w", err)
-	}
-
-	data := buf.Bytes()
-	if len(data) < 100 {
-		return nil, fmt.Errorf("suspicious zip size: %d bytes", len(data))
-	}
-
-	return data, nil
-}
-
-func (s *ExportService) createDocumentsArchive(properties []models.Property) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
-
-	for _, prop := range properties {
-		// Создаем директорию для файлов объекта
-		propDir := fmt.Sprintf("%s_%s", prop.PropertyCode, prop.AgentCode)
-
-		// Получаем все документы объекта
-		docs, err := s.propertyService.GetAllDocuments(prop.ID)
-		if err != nil {
-			continue // Пропускаем объект при ошибке
-		}
-
-		// Добавляем файлы
-		for _, doc := range docs {
-			filePath := s.fileService.GetFilePath(doc.FilePath)
-			fileData, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			// Создаем имя файла с метаинформацией
-			fileName := fmt.Sprintf("%s/%s_%s_%v",
-				propDir,
-				doc.FileType,
-				filepath.Base(doc.FilePath),
-				doc.IsPublic,
-			)
-
-			fileWriter, err := zipWriter.Create(fileName)
-			if err != nil {
-				continue
-			}
-
-			if _, err := fileWriter.Write(fileData); err != nil {
-				continue
-			}
-		}
-
-		// Добавляем историю
-		history, err := s.propertyService.GetPropertyHistory(prop.ID)
-		if err == nil {
-			var historyContent bytes.Buffer
-			historyContent.WriteString(fmt.Sprintf("История операций для объекта %s\n\n", prop.PropertyCode))
-
-			for _, record := range history {
-				historyContent.WriteString(fmt.Sprintf("Дата: %s\n", record.ActionDate.Format("2006-01-02 15:04:05")))
-				historyContent.WriteString(fmt.Sprintf("Действие: %s\n", record.ActionType))
-				historyContent.WriteString(fmt.Sprintf("Агент ID: %d\n", record.AgentID))
-				historyContent.WriteString(fmt.Sprintf("Детали: %s\n", string(record.Details)))
-				historyContent.WriteString("------------------\n")
-			}
-
-			historyWriter, err := zipWriter.Create(fmt.Sprintf("%s/history.txt", propDir))
-			if err == nil {
-				historyWriter.Write(historyContent.Bytes())
-			}
+		if err := progress(i+1, warnings); err != nil {
+			return err
 		}
 	}
 
 	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close zip: %w", err)
+		return fmt.Errorf("zip close error: %w", err)
 	}
-
-	return buf.Bytes(), nil
+	return nil
 }
 
 func (s *ExportService) createExcelFile(properties []models.Property, language string) ([]byte, error) {
 	f := excelize.NewFile()
 
-	// Создаем листы для разных типов недвижимости
-	sheets := map[models.PropertyType]string{
-		models.House:     "Houses",
-		models.Apartment: "Apartments",
-		models.Office:    "Offices",
-	}
-
 	// Заголовки для Excel
 	headers := []string{
 		"Agent Code",
 		"Property Code",
 		"Deal Type",
 		"Status",
+		"Lifecycle State",
 		"City",
 		"District",
 		"Address",
@@ -261,7 +187,7 @@ func (s *ExportService) createExcelFile(properties []models.Property, language s
 	}
 
 	// Заполняем листы данными
-	for propertyType, sheetName := range sheets {
+	for propertyType, sheetName := range propertySheets {
 		index, _ := f.NewSheet(sheetName)
 
 		// Устанавливаем заголовки
@@ -298,6 +224,7 @@ func (s *ExportService) createExcelFile(properties []models.Property, language s
 				prop.PropertyCode,
 				prop.DealType,
 				prop.Status,
+				prop.LifecycleState,
 				details.City,
 				details.District,
 				details.Address,
@@ -0,0 +1,554 @@
+// backend/internal/services/export_jobs.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
+)
+
+var (
+	ErrExportJobNotFound = errors.New("export not found")
+	// ErrExportJobFinished is returned when canceling an export that has
+	// already completed, failed or been canceled
+	ErrExportJobFinished = errors.New("export has already finished")
+	ErrExportNotReady    = errors.New("export is not completed")
+	ErrTooManyExports    = errors.New("too many exports running")
+	ErrNothingToExport   = errors.New("no properties found")
+)
+
+// Defaults for ExportJobConfigFromEnv
+const (
+	DefaultExportTTL         = 24 * time.Hour
+	DefaultExportJobsPerUser = 2
+)
+
+const (
+	// exportWorkers is how many exports run at once on one server; more
+	// jobs wait as queued
+	exportWorkers = 2
+	// exportPoll is how often idle workers look for jobs queued on other
+	// servers or left queued by a server that stopped
+	exportPoll = 10 * time.Second
+	// exportHeartbeat is how often a running export touches its job. Running
+	// jobs not touched for exportStale are failed by Run.
+	exportHeartbeat = time.Minute
+	exportStale     = 5 * time.Minute
+	// exportWarnings is how many warnings a job keeps; Skipped counts all
+	exportWarnings = 100
+)
+
+// errExportCanceled stops the worker of a job canceled through the database,
+// possibly by another server.
+var errExportCanceled = errors.New("export canceled")
+
+// ExportJobConfig is read from EXPORT_RESULT_TTL_HOURS and
+// EXPORT_JOBS_PER_USER.
+type ExportJobConfig struct {
+	// TTL is how long the result of an export is kept for download
+	TTL time.Duration
+	// PerUser is how many exports one user may have queued or running
+	PerUser int
+}
+
+// ExportJobConfigFromEnv returns the export job settings, with defaults for
+// the variables that are not set.
+func ExportJobConfigFromEnv() (ExportJobConfig, error) {
+	config := ExportJobConfig{TTL: DefaultExportTTL, PerUser: DefaultExportJobsPerUser}
+	if value := os.Getenv("EXPORT_RESULT_TTL_HOURS"); value != "" {
+		hours, err := strconv.Atoi(value)
+		if err != nil || hours < 1 {
+			return config, errors.New("EXPORT_RESULT_TTL_HOURS must be a whole number of hours, at least 1")
+		}
+		config.TTL = time.Duration(hours) * time.Hour
+	}
+	if value := os.Getenv("EXPORT_JOBS_PER_USER"); value != "" {
+		jobs, err := strconv.Atoi(value)
+		if err != nil || jobs < 1 {
+			return config, errors.New("EXPORT_JOBS_PER_USER must be a whole number, at least 1")
+		}
+		config.PerUser = jobs
+	}
+	return config, nil
+}
+
+// ExportJobService runs exports in the background. A job is created queued
+// and claimed by the first free worker of any server running Run, so queued
+// jobs survive a restart. It leaves a ZIP in file storage that can be
+// downloaded until it expires. Jobs can be canceled while queued or running,
+// from any server: the worker checks the job after every property.
+type ExportJobService struct {
+	db      *gorm.DB
+	exports *ExportService
+	files   *FileService
+	config  ExportJobConfig
+	// queued wakes a worker of this server for a new job
+	queued chan struct{}
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewExportJobService(db *gorm.DB, exports *ExportService, files *FileService, config ExportJobConfig) *ExportJobService {
+	return &ExportJobService{
+		db:      db,
+		exports: exports,
+		files:   files,
+		config:  config,
+		queued:  make(chan struct{}, 1),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// CreateJob queues an export of the properties in propertyIDs with their
+// details in language and starts it. A user may have config.PerUser exports
+// queued or running at a time.
+func (s *ExportJobService) CreateJob(actor Actor, propertyIDs []uint, language string) (*models.ExportJob, error) {
+	if !actor.Can(PermPropertiesExport) {
+		return nil, ErrForbidden
+	}
+	if len(propertyIDs) == 0 {
+		return nil, ErrNothingToExport
+	}
+
+	ids, err := json.Marshal(propertyIDs)
+	if err != nil {
+		return nil, err
+	}
+	job := models.ExportJob{
+		ID:          uuid.New().String(),
+		UserID:      actor.UserID,
+		Status:      models.ExportQueued,
+		Language:    language,
+		PropertyIDs: ids,
+		Total:       len(propertyIDs),
+		Warnings:    json.RawMessage("[]"),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Serializes the limit check of one user
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, actor.UserID).Error; err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&models.ExportJob{}).
+			Where("user_id = ? AND status IN ?", actor.UserID, []models.ExportJobStatus{models.ExportQueued, models.ExportRunning}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if int(active) >= s.config.PerUser {
+			return ErrTooManyExports
+		}
+
+		return tx.Create(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// GetJob returns an export of actor.
+func (s *ExportJobService) GetJob(id string, actor Actor) (*models.ExportJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrExportJobNotFound
+	}
+	var job models.ExportJob
+	err := s.db.Where("id = ? AND user_id = ?", id, actor.UserID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the exports of actor that have not expired, newest first.
+func (s *ExportJobService) ListJobs(actor Actor) ([]models.ExportJob, error) {
+	var jobs []models.ExportJob
+	if err := s.db.Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", actor.UserID, time.Now()).
+		Order("created_at DESC").
+		Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CancelJob stops a queued or running export of actor. Its partial result
+// is discarded.
+func (s *ExportJobService) CancelJob(id string, actor Actor) (*models.ExportJob, error) {
+	job, err := s.GetJob(id, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expires := now.Add(s.config.TTL)
+	result := s.db.Model(&models.ExportJob{}).
+		Where("id = ? AND status IN ?", id, []models.ExportJobStatus{models.ExportQueued, models.ExportRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ExportCanceled,
+			"finished_at": now,
+			"expires_at":  expires,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrExportJobFinished
+	}
+
+	// A worker on this server stops right away; one on another server at
+	// its next check
+	s.mu.Lock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	job.Status = models.ExportCanceled
+	job.FinishedAt, job.ExpiresAt = &now, &expires
+	return job, nil
+}
+
+// OpenResult opens the ZIP of a completed export that has not expired. The
+// caller must close it. Callers check access, e.g. with a signed URL.
+func (s *ExportJobService) OpenResult(id string) (*models.ExportJob, io.ReadSeekCloser, *storage.Object, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, nil, ErrExportJobNotFound
+	}
+	var job models.ExportJob
+	err := s.db.Where("id = ? AND (expires_at IS NULL OR expires_at > ?)", id, time.Now()).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if job.Status != models.ExportCompleted {
+		return nil, nil, nil, ErrExportNotReady
+	}
+
+	file, info, err := s.files.OpenFile(job.ResultKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &job, file, info, nil
+}
+
+// work runs queued jobs one at a time until ctx is done.
+func (s *ExportJobService) work(ctx context.Context) {
+	poll := time.NewTicker(exportPoll)
+	defer poll.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := s.claim()
+			if err != nil {
+				log.Printf("Failed to claim export: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.queued:
+		case <-poll.C:
+		}
+	}
+}
+
+// claim marks the oldest queued job running and returns it, or nil if no
+// job is queued. Workers on other servers skip a job being claimed.
+func (s *ExportJobService) claim() (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.ExportQueued).
+			Order("created_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job.Status, job.StartedAt, job.UpdatedAt = models.ExportRunning, &now, now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":     models.ExportRunning,
+			"started_at": now,
+			"updated_at": now,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run does the work of a claimed job. A job interrupted because ctx is done,
+// i.e. the server is stopping, is queued again for another worker.
+func (s *ExportJobService) run(ctx context.Context, job *models.ExportJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, job.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	err := s.export(jobCtx, job)
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		// A job canceled meanwhile is not running, so it stays canceled
+		log.Printf("Export %s interrupted, queuing it again", job.ID)
+		if err := s.db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", job.ID, models.ExportRunning).
+			Updates(map[string]interface{}{
+				"status":     models.ExportQueued,
+				"processed":  0,
+				"skipped":    0,
+				"warnings":   json.RawMessage("[]"),
+				"started_at": nil,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+			log.Printf("Failed to queue export %s again: %v", job.ID, err)
+		}
+	case errors.Is(err, errExportCanceled), errors.Is(err, context.Canceled):
+		log.Printf("Export %s canceled", job.ID)
+	default:
+		log.Printf("Export %s failed: %v", job.ID, err)
+		now := time.Now()
+		if err := s.db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", job.ID, models.ExportRunning).
+			Updates(map[string]interface{}{
+				"status":      models.ExportFailed,
+				"error":       err.Error(),
+				"finished_at": now,
+				"expires_at":  now.Add(s.config.TTL),
+			}).Error; err != nil {
+			log.Printf("Failed to record failure of export %s: %v", job.ID, err)
+		}
+	}
+}
+
+// export writes the ZIP of a running job to a temporary file, so it is
+// never held in memory, and moves it to file storage.
+func (s *ExportJobService) export(ctx context.Context, job *models.ExportJob) error {
+	var propertyIDs []uint
+	if err := json.Unmarshal(job.PropertyIDs, &propertyIDs); err != nil {
+		return err
+	}
+	properties, err := s.exports.propertyService.ListPropertiesByIDs(propertyIDs, job.Language)
+	if err != nil {
+		return err
+	}
+	if len(properties) == 0 {
+		return ErrNothingToExport
+	}
+	if err := s.touch(job.ID, map[string]interface{}{"total": len(properties)}); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Keeps the job fresh while a single large file is copied
+	heartbeat := time.NewTicker(exportHeartbeat)
+	defer heartbeat.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if err := s.touch(job.ID, nil); errors.Is(err, errExportCanceled) {
+					s.mu.Lock()
+					if cancel, ok := s.cancels[job.ID]; ok {
+						cancel()
+					}
+					s.mu.Unlock()
+				}
+			}
+		}
+	}()
+
+	// Files and history that cannot be read are left out of the ZIP and
+	// reported on the job
+	skipped := 0
+	warnings := []string{}
+	err = s.exports.WriteExport(ctx, tmp, properties, job.Language, func(done int, skips []string) error {
+		updates := map[string]interface{}{"processed": done}
+		if len(skips) > 0 {
+			skipped += len(skips)
+			for _, warning := range skips {
+				if len(warnings) < exportWarnings {
+					warnings = append(warnings, warning)
+				}
+			}
+			encoded, err := json.Marshal(warnings)
+			if err != nil {
+				return err
+			}
+			updates["skipped"] = skipped
+			updates["warnings"] = json.RawMessage(encoded)
+		}
+		return s.touch(job.ID, updates)
+	})
+	if err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key, err := s.files.SaveExport(job.UserID, job.ID, tmp, size)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", job.ID, models.ExportRunning).
+		Updates(map[string]interface{}{
+			"status":      models.ExportCompleted,
+			"result_key":  key,
+			"result_size": size,
+			"finished_at": now,
+			"expires_at":  now.Add(s.config.TTL),
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		// Canceled while the result was being stored
+		result.Error = errExportCanceled
+	}
+	if result.Error != nil {
+		if err := s.files.DeleteFile(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete result of export %s: %v", job.ID, err)
+		}
+		return result.Error
+	}
+	return nil
+}
+
+// touch updates a running job and its updated_at. It returns
+// errExportCanceled if the job is no longer running.
+func (s *ExportJobService) touch(id string, updates map[string]interface{}) error {
+	if updates == nil {
+		updates = make(map[string]interface{})
+	}
+	updates["updated_at"] = time.Now()
+	result := s.db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", id, models.ExportRunning).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errExportCanceled
+	}
+	return nil
+}
+
+// Run runs queued jobs in exportWorkers workers and cleans up every
+// interval until ctx is done, starting right away: running jobs whose
+// server stopped are failed, and expired jobs are removed with their
+// results. Jobs still running when ctx is done are queued again; Run
+// returns once they are.
+func (s *ExportJobService) Run(ctx context.Context, interval time.Duration) {
+	var workers sync.WaitGroup
+	for i := 0; i < exportWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.work(ctx)
+		}()
+	}
+	defer workers.Wait()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Cleanup(time.Now()); err != nil {
+			log.Printf("Failed to clean up exports: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup fails stale running jobs and removes the jobs that expired before
+// now. A result that cannot be deleted keeps its job for the next run.
+// Queued jobs wait for a worker however long it takes.
+func (s *ExportJobService) Cleanup(now time.Time) error {
+	var errs []error
+
+	if err := s.db.Model(&models.ExportJob{}).
+		Where("status = ? AND updated_at < ?", models.ExportRunning, now.Add(-exportStale)).
+		Updates(map[string]interface{}{
+			"status":      models.ExportFailed,
+			"error":       "the export was interrupted",
+			"finished_at": now,
+			"expires_at":  now.Add(s.config.TTL),
+		}).Error; err != nil {
+		errs = append(errs, err)
+	}
+
+	var expired []models.ExportJob
+	if err := s.db.Where("expires_at < ?", now).Find(&expired).Error; err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, job := range expired {
+		if job.ResultKey != "" {
+			if err := s.files.DeleteFile(job.ResultKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				errs = append(errs, fmt.Errorf("export %s: %w", job.ID, err))
+				continue
+			}
+		}
+		if err := s.db.Delete(&models.ExportJob{}, "id = ?", job.ID).Error; err != nil {
+			errs = append(errs, fmt.Errorf("export %s: %w", job.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
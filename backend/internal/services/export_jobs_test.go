@@ -0,0 +1,209 @@
+// backend/internal/services/export_jobs_test.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
+)
+
+func TestExportJobConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		ttl, perUser string
+		want         ExportJobConfig
+		wantErr      bool
+	}{
+		{"", "", ExportJobConfig{TTL: DefaultExportTTL, PerUser: DefaultExportJobsPerUser}, false},
+		{"6", "5", ExportJobConfig{TTL: 6 * time.Hour, PerUser: 5}, false},
+		{"0", "", ExportJobConfig{}, true},
+		{"", "-1", ExportJobConfig{}, true},
+		{"1.5", "", ExportJobConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("EXPORT_RESULT_TTL_HOURS", tt.ttl)
+		t.Setenv("EXPORT_JOBS_PER_USER", tt.perUser)
+		got, err := ExportJobConfigFromEnv()
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("ExportJobConfigFromEnv() with %q, %q = %+v, %v", tt.ttl, tt.perUser, got, err)
+		}
+	}
+}
+
+func TestExportJobValidation(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewExportJobService(db, nil, nil, ExportJobConfig{TTL: time.Hour, PerUser: 1})
+
+	if _, err := s.CreateJob(Actor{UserID: 1, Role: RoleViewer}, []uint{1}, "sr"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer: error = %v, want ErrForbidden", err)
+	}
+	if _, err := s.CreateJob(Actor{UserID: 1, Role: RoleAgent}, nil, "sr"); !errors.Is(err, ErrNothingToExport) {
+		t.Errorf("no properties: error = %v, want ErrNothingToExport", err)
+	}
+	if _, err := s.GetJob("1; DROP TABLE export_jobs", Actor{UserID: 1}); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("GetJob with an invalid ID: error = %v, want ErrExportJobNotFound", err)
+	}
+	if _, _, _, err := s.OpenResult("../exports"); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("OpenResult with an invalid ID: error = %v, want ErrExportJobNotFound", err)
+	}
+	if len(*statements) > 0 {
+		t.Errorf("invalid requests reached the database: %q", *statements)
+	}
+}
+
+// createTestExportJob adds a job of userID that no worker runs.
+func createTestExportJob(t *testing.T, s *ExportJobService, userID uint, status models.ExportJobStatus,
+	updatedAt time.Time, expiresAt *time.Time, resultKey string) *models.ExportJob {
+	t.Helper()
+	job := models.ExportJob{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Status:      status,
+		Language:    "sr",
+		PropertyIDs: []byte("[1]"),
+		Total:       1,
+		ResultKey:   resultKey,
+		UpdatedAt:   updatedAt,
+		ExpiresAt:   expiresAt,
+	}
+	if err := s.db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestExportJobs(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &failingStorage{Storage: local}
+	s := NewExportJobService(db, nil, NewFileService(store, "secret"), ExportJobConfig{TTL: time.Hour, PerUser: 1})
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	otherUser := createTestUser(t, auth, "other@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	other := Actor{UserID: otherUser.ID, Role: RoleAgent}
+	now := time.Now()
+
+	t.Run("per-user limit", func(t *testing.T) {
+		queued := createTestExportJob(t, s, user.ID, models.ExportQueued, now, nil, "")
+		if _, err := s.CreateJob(agent, []uint{1}, "sr"); !errors.Is(err, ErrTooManyExports) {
+			t.Errorf("second export: error = %v, want ErrTooManyExports", err)
+		}
+		if _, err := s.CancelJob(queued.ID, agent); err != nil {
+			t.Fatal(err)
+		}
+
+		// Jobs stay queued until a worker claims them
+		job, err := s.CreateJob(agent, []uint{1}, "sr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed, err := s.claim()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed == nil || claimed.ID != job.ID || claimed.Status != models.ExportRunning {
+			t.Fatalf("claimed %+v, want job %s running", claimed, job.ID)
+		}
+		if claimed, err := s.claim(); err != nil || claimed != nil {
+			t.Errorf("second claim = %+v, %v, want nothing", claimed, err)
+		}
+		if _, err := s.CancelJob(job.ID, agent); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		running := createTestExportJob(t, s, user.ID, models.ExportRunning, now, nil, "")
+		if _, err := s.CancelJob(running.ID, other); !errors.Is(err, ErrExportJobNotFound) {
+			t.Errorf("canceling another user's export: error = %v, want ErrExportJobNotFound", err)
+		}
+		job, err := s.CancelJob(running.ID, agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.ExportCanceled || job.ExpiresAt == nil {
+			t.Errorf("canceled job = %+v", job)
+		}
+		if _, err := s.CancelJob(running.ID, agent); !errors.Is(err, ErrExportJobFinished) {
+			t.Errorf("canceling twice: error = %v, want ErrExportJobFinished", err)
+		}
+		if _, _, _, err := s.OpenResult(running.ID); !errors.Is(err, ErrExportNotReady) {
+			t.Errorf("OpenResult of a canceled export: error = %v, want ErrExportNotReady", err)
+		}
+	})
+
+	t.Run("open result", func(t *testing.T) {
+		key := "exports/result.zip"
+		if err := local.Put(context.Background(), key, strings.NewReader("PK"), 2, "application/zip"); err != nil {
+			t.Fatal(err)
+		}
+		later, earlier := now.Add(time.Hour), now.Add(-time.Minute)
+		completed := createTestExportJob(t, s, user.ID, models.ExportCompleted, now, &later, key)
+		expired := createTestExportJob(t, s, user.ID, models.ExportCompleted, now, &earlier, key)
+
+		_, file, info, err := s.OpenResult(completed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if info.Size != 2 {
+			t.Errorf("result size = %d, want 2", info.Size)
+		}
+		if _, _, _, err := s.OpenResult(expired.ID); !errors.Is(err, ErrExportJobNotFound) {
+			t.Errorf("OpenResult of an expired export: error = %v, want ErrExportJobNotFound", err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		key := "exports/old.zip"
+		if err := local.Put(context.Background(), key, strings.NewReader("PK"), 2, "application/zip"); err != nil {
+			t.Fatal(err)
+		}
+		earlier := now.Add(-time.Minute)
+		stale := createTestExportJob(t, s, otherUser.ID, models.ExportRunning, now.Add(-2*exportStale), nil, "")
+		fresh := createTestExportJob(t, s, otherUser.ID, models.ExportRunning, now, nil, "")
+		expired := createTestExportJob(t, s, otherUser.ID, models.ExportCompleted, now, &earlier, key)
+
+		// A result that can't be deleted keeps its job
+		store.fail = true
+		if err := s.Cleanup(now); err == nil {
+			t.Error("cleanup succeeded while the storage failed")
+		}
+		if _, err := s.GetJob(expired.ID, other); err != nil {
+			t.Errorf("expired job with its result left: %v", err)
+		}
+
+		store.fail = false
+		if err := s.Cleanup(now); err != nil {
+			t.Fatal(err)
+		}
+		job, err := s.GetJob(stale.ID, other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.ExportFailed || job.Error == "" {
+			t.Errorf("stale job = %+v, want failed", job)
+		}
+		if job, err := s.GetJob(fresh.ID, other); err != nil || job.Status != models.ExportRunning {
+			t.Errorf("fresh job = %+v, %v, want running", job, err)
+		}
+		if _, err := s.GetJob(expired.ID, other); !errors.Is(err, ErrExportJobNotFound) {
+			t.Errorf("expired job: error = %v, want ErrExportJobNotFound", err)
+		}
+		if _, err := local.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expired result: error = %v, want ErrNotFound", err)
+		}
+	})
+}
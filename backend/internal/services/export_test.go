@@ -0,0 +1,50 @@
+// backend/internal/services/export_test.go
+
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
+)
+
+func TestWriteExportWarnings(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService(local, "secret")
+	properties := NewPropertyService(db, NewGeocodingService(db))
+	exports := NewExportService(properties, files)
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	property := createTestProperty(t, db, models.Sale, user.ID)
+	if err := db.Create(&models.Document{PropertyID: property.ID, FileType: "document", FilePath: "document/1/missing.pdf"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	list, err := properties.ListPropertiesByIDs([]uint{property.ID}, "sr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing file is left out with a warning; the export goes on
+	var calls int
+	var warnings []string
+	err = exports.WriteExport(context.Background(), io.Discard, list, "sr", func(done int, w []string) error {
+		calls++
+		warnings = append(warnings, w...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || len(warnings) != 1 || !strings.Contains(warnings[0], "file missing.pdf not exported") {
+		t.Errorf("progress called %d times with warnings %q", calls, warnings)
+	}
+}
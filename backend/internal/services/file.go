@@ -3,28 +3,44 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
 )
 
-type FileService struct {
-	uploadDir string
-}
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signed url expired")
+)
+
+// SignedURLTTL is how long a download link for a private document stays valid.
+const SignedURLTTL = 15 * time.Minute
 
-func (s *FileService) GetUploadDir() string {
-	return s.uploadDir
+type FileService struct {
+	storage    storage.Storage
+	signingKey []byte
 }
 
-func NewFileService(uploadDir string) *FileService {
+func NewFileService(store storage.Storage, signingKey string) *FileService {
 	return &FileService{
-		uploadDir: uploadDir,
+		storage:    store,
+		signingKey: []byte(signingKey),
 	}
 }
 
@@ -36,23 +52,19 @@ const (
 	FileTypeDocument FileType = "document"
 )
 
-func (s *FileService) SaveFile(file *multipart.FileHeader, fileType FileType, propertyID uint) (string, error) {
-	// Create year/month-based directory structure
+// SaveFile stores an upload that passed ValidateUpload. The extension and
+// content type are taken from the validation result, not from the client.
+func (s *FileService) SaveFile(file *multipart.FileHeader, upload *ValidatedUpload, propertyID uint) (string, error) {
+	fileType := upload.FileType
+
+	// Create year/month-based key structure
 	now := time.Now()
-	relativePath := filepath.Join(
+	key := path.Join(
 		string(fileType),
 		fmt.Sprintf("%d", propertyID),
 		fmt.Sprintf("%d/%02d", now.Year(), now.Month()),
+		fmt.Sprintf("%s%s", uuid.New().String(), upload.Extension),
 	)
-	fullPath := filepath.Join(s.uploadDir, relativePath)
-
-	if err := os.MkdirAll(fullPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 
 	// Open source file
 	src, err := file.Open()
@@ -61,30 +73,146 @@ func (s *FileService) SaveFile(file *multipart.FileHeader, fileType FileType, pr
 	}
 	defer src.Close()
 
-	// Create destination file
-	fullFilePath := filepath.Join(fullPath, filename)
-	dst, err := os.Create(fullFilePath)
+	var body io.Reader = src
+	size := file.Size
+
+	// Strip EXIF (GPS position, camera serial numbers) before anything is stored
+	if fileType == FileTypeImage {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return "", fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		if data, err = StripImageMetadata(data); err != nil {
+			return "", fmt.Errorf("failed to strip image metadata: %w", err)
+		}
+		body = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+
+	if err := s.storage.Put(context.Background(), key, body, size, upload.ContentType); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	// Return key for storage in database
+	return key, nil
+}
+
+// SaveImageVariants generates resized copies of a stored image and stores
+// them next to the original as <name>_<variant>.jpg and <name>_<variant>.webp.
+func (s *FileService) SaveImageVariants(filePath string) ([]models.DocumentVariant, error) {
+	src, _, err := s.OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := GenerateImageVariants(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %w", err)
+		return nil, err
 	}
-	defer dst.Close()
 
-	// Copy contents
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("failed to copy file contents: %w", err)
+	base := strings.TrimSuffix(filepath.ToSlash(filePath), path.Ext(filePath))
+	variants := make([]models.DocumentVariant, 0, len(images))
+	for _, img := range images {
+		extension, contentType := ".jpg", "image/jpeg"
+		if img.Format == ImageFormatWebP {
+			extension, contentType = ".webp", "image/webp"
+		}
+		key := fmt.Sprintf("%s_%s%s", base, img.Name, extension)
+		if err := s.storage.Put(context.Background(), key, bytes.NewReader(img.Data), int64(len(img.Data)), contentType); err != nil {
+			for _, v := range variants {
+				_ = s.DeleteFile(v.FilePath)
+			}
+			return nil, fmt.Errorf("failed to store %s %s variant: %w", img.Name, img.Format, err)
+		}
+
+		variants = append(variants, models.DocumentVariant{
+			Variant:  img.Name,
+			Format:   img.Format,
+			FilePath: key,
+			Width:    img.Width,
+			Height:   img.Height,
+			Size:     int64(len(img.Data)),
+		})
 	}
 
-	// Return relative path for storage in database
-	return filepath.Join(relativePath, filename), nil
+	return variants, nil
+}
+
+// SaveExport stores the ZIP of an export job and returns its key. Exports
+// are not documents, so they are never served under /uploads.
+func (s *FileService) SaveExport(userID uint, jobID string, r io.Reader, size int64) (string, error) {
+	key := path.Join("exports", fmt.Sprintf("%d", userID), jobID+".zip")
+	if err := s.storage.Put(context.Background(), key, r, size, "application/zip"); err != nil {
+		return "", fmt.Errorf("failed to store export: %w", err)
+	}
+	return key, nil
 }
 
 func (s *FileService) DeleteFile(filePath string) error {
-	fullPath := filepath.Join(s.uploadDir, filePath)
-	return os.Remove(fullPath)
+	return s.storage.Delete(context.Background(), filepath.ToSlash(filePath))
+}
+
+// DeleteDocumentFiles removes a document's file and all of its variants.
+func (s *FileService) DeleteDocumentFiles(document *models.Document) error {
+	for _, variant := range document.Variants {
+		if err := s.DeleteFile(variant.FilePath); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return s.DeleteFile(document.FilePath)
+}
+
+// OpenFile opens a stored file for reading. The caller must close it.
+func (s *FileService) OpenFile(filePath string) (io.ReadSeekCloser, *storage.Object, error) {
+	return s.storage.Get(context.Background(), filepath.ToSlash(filePath))
+}
+
+// SignDocumentURL returns the expiry timestamp and HMAC signature that
+// authorise downloading the given document until that moment.
+func (s *FileService) SignDocumentURL(documentID uint, ttl time.Duration) (int64, string) {
+	expires := time.Now().Add(ttl).Unix()
+	return expires, s.sign(strconv.FormatUint(uint64(documentID), 10), expires)
+}
+
+// VerifyDocumentSignature checks a signature produced by SignDocumentURL.
+func (s *FileService) VerifyDocumentSignature(documentID uint, expires int64, signature string) error {
+	return s.verify(strconv.FormatUint(uint64(documentID), 10), expires, signature)
+}
+
+// SignExportURL is SignDocumentURL for the result of an export job.
+func (s *FileService) SignExportURL(jobID string, ttl time.Duration) (int64, string) {
+	expires := time.Now().Add(ttl).Unix()
+	return expires, s.sign("export/"+jobID, expires)
+}
+
+// VerifyExportSignature checks a signature produced by SignExportURL.
+func (s *FileService) VerifyExportSignature(jobID string, expires int64, signature string) error {
+	return s.verify("export/"+jobID, expires, signature)
+}
+
+func (s *FileService) verify(subject string, expires int64, signature string) error {
+	expected := s.sign(subject, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
 }
 
-func (s *FileService) GetFilePath(relativePath string) string {
-	return filepath.Join(s.uploadDir, relativePath)
+// sign signs a subject, a document ID or "export/<job ID>", until expires.
+func (s *FileService) sign(subject string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(subject))
+	mac.Write([]byte{':'})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func (s *FileService) ValidateFileType(filename string, allowedTypes []string) bool {
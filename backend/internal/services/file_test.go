@@ -0,0 +1,65 @@
+// backend/internal/services/file_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDocumentSignature(t *testing.T) {
+	files := NewFileService(nil, "secret")
+	expires, signature := files.SignDocumentURL(42, SignedURLTTL)
+	past := time.Now().Add(-time.Minute).Unix()
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name       string
+		service    *FileService
+		documentID uint
+		expires    int64
+		signature  string
+		want       error
+	}{
+		{"valid", files, 42, expires, signature, nil},
+		{"other document", files, 43, expires, signature, ErrInvalidSignature},
+		{"extended expiry", files, 42, expires + 3600, signature, ErrInvalidSignature},
+		{"tampered signature", files, 42, expires, string(tampered), ErrInvalidSignature},
+		{"empty signature", files, 42, expires, "", ErrInvalidSignature},
+		{"other key", NewFileService(nil, "other"), 42, expires, signature, ErrInvalidSignature},
+		{"expired", files, 42, past, files.sign("42", past), ErrSignatureExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.service.VerifyDocumentSignature(tt.documentID, tt.expires, tt.signature)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyDocumentSignature() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportSignature(t *testing.T) {
+	files := NewFileService(nil, "secret")
+	const jobID = "0b5c2c4e-7f0d-4c57-9a57-1f0c7c1d2e3f"
+	expires, signature := files.SignExportURL(jobID, SignedURLTTL)
+
+	if err := files.VerifyExportSignature(jobID, expires, signature); err != nil {
+		t.Errorf("valid export signature: %v", err)
+	}
+	if err := files.VerifyExportSignature("1", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("other export: %v, want %v", err, ErrInvalidSignature)
+	}
+
+	// A document link must not open an export and the other way round
+	documentExpires, documentSignature := files.SignDocumentURL(1, SignedURLTTL)
+	if err := files.VerifyExportSignature("1", documentExpires, documentSignature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("document signature for export: %v, want %v", err, ErrInvalidSignature)
+	}
+	exportExpires, exportSignature := files.SignExportURL("1", SignedURLTTL)
+	if err := files.VerifyDocumentSignature(1, exportExpires, exportSignature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("export signature for document: %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,155 @@
+// backend/internal/services/geo.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kuckuc/internal/models"
+)
+
+var ErrInvalidGeoFilter = errors.New("invalid geo filter")
+
+// MaxSearchRadius limits radius searches to 100 km.
+const MaxSearchRadius = 100000
+
+// BBox is a bounding box in GeoJSON order: west, south, east, north.
+type BBox struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// ParseBBox parses "minLng,minLat,maxLng,maxLat".
+func ParseBBox(value string) (*BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%w: bbox must be minLng,minLat,maxLng,maxLat", ErrInvalidGeoFilter)
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bbox: %v", ErrInvalidGeoFilter, err)
+		}
+		coords[i] = v
+	}
+
+	box := &BBox{MinLng: coords[0], MinLat: coords[1], MaxLng: coords[2], MaxLat: coords[3]}
+	if !validLngLat(box.MinLng, box.MinLat) || !validLngLat(box.MaxLng, box.MaxLat) ||
+		box.MinLng > box.MaxLng || box.MinLat > box.MaxLat {
+		return nil, fmt.Errorf("%w: bbox out of range", ErrInvalidGeoFilter)
+	}
+	return box, nil
+}
+
+// GeoPolygon is the outer ring of a GeoJSON polygon as [lng, lat] pairs.
+type GeoPolygon [][2]float64
+
+// ParseGeoPolygon accepts a GeoJSON Polygon geometry, or a Feature wrapping
+// one. Holes are ignored.
+func ParseGeoPolygon(value string) (GeoPolygon, error) {
+	var geometry struct {
+		Type        string           `json:"type"`
+		Coordinates [][][2]float64   `json:"coordinates"`
+		Geometry    *json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal([]byte(value), &geometry); err != nil {
+		return nil, fmt.Errorf("%w: polygon: %v", ErrInvalidGeoFilter, err)
+	}
+	if geometry.Type == "Feature" && geometry.Geometry != nil {
+		return ParseGeoPolygon(string(*geometry.Geometry))
+	}
+	if geometry.Type != "Polygon" || len(geometry.Coordinates) == 0 {
+		return nil, fmt.Errorf("%w: polygon must be a GeoJSON Polygon", ErrInvalidGeoFilter)
+	}
+
+	ring := geometry.Coordinates[0]
+	// GeoJSON rings repeat the first position at the end
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 || len(ring) > 1000 {
+		return nil, fmt.Errorf("%w: polygon must have between 3 and 1000 points", ErrInvalidGeoFilter)
+	}
+	for _, p := range ring {
+		if !validLngLat(p[0], p[1]) {
+			return nil, fmt.Errorf("%w: polygon out of range", ErrInvalidGeoFilter)
+		}
+	}
+	return GeoPolygon(ring), nil
+}
+
+// Bounds returns the bounding box of the polygon, used to narrow the search
+// with the (latitude, longitude) index before the exact containment test.
+func (p GeoPolygon) Bounds() BBox {
+	box := BBox{MinLng: p[0][0], MinLat: p[0][1], MaxLng: p[0][0], MaxLat: p[0][1]}
+	for _, point := range p[1:] {
+		box.MinLng = min(box.MinLng, point[0])
+		box.MaxLng = max(box.MaxLng, point[0])
+		box.MinLat = min(box.MinLat, point[1])
+		box.MaxLat = max(box.MaxLat, point[1])
+	}
+	return box
+}
+
+// SQL formats the polygon as a postgres polygon literal with x = longitude.
+func (p GeoPolygon) SQL() string {
+	points := make([]string, len(p))
+	for i, point := range p {
+		points[i] = fmt.Sprintf("(%s,%s)",
+			strconv.FormatFloat(point[0], 'f', -1, 64),
+			strconv.FormatFloat(point[1], 'f', -1, 64))
+	}
+	return "(" + strings.Join(points, ",") + ")"
+}
+
+func validLngLat(lng, lat float64) bool {
+	return lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90
+}
+
+// GeoJSON output for the property list
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         uint            `json:"id"`
+	Geometry   GeoJSONPoint    `json:"geometry"`
+	Properties models.Property `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// PropertiesToGeoJSON converts properties to point features. Properties
+// without coordinates are skipped.
+func PropertiesToGeoJSON(properties []models.Property) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]GeoJSONFeature, 0, len(properties)),
+	}
+	for _, p := range properties {
+		if p.Latitude == nil || p.Longitude == nil {
+			continue
+		}
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type: "Feature",
+			ID:   p.ID,
+			Geometry: GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{*p.Longitude, *p.Latitude},
+			},
+			Properties: p,
+		})
+	}
+	return collection
+}
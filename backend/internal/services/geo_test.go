@@ -0,0 +1,141 @@
+// backend/internal/services/geo_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"kuckuc/internal/models"
+)
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *BBox
+		wantErr bool
+	}{
+		{"20.3,44.7,20.6,44.9", &BBox{20.3, 44.7, 20.6, 44.9}, false},
+		{" 20.3, 44.7 ,20.6,44.9 ", &BBox{20.3, 44.7, 20.6, 44.9}, false},
+		{"-180,-90,180,90", &BBox{-180, -90, 180, 90}, false},
+		{"20.3,44.7,20.6", nil, true},
+		{"20.3,44.7,20.6,44.9,1", nil, true},
+		{"a,44.7,20.6,44.9", nil, true},
+		{"20.6,44.7,20.3,44.9", nil, true},
+		{"20.3,44.9,20.6,44.7", nil, true},
+		{"20.3,44.7,181,44.9", nil, true},
+		{"20.3,-91,20.6,44.9", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		box, err := ParseBBox(tt.value)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidGeoFilter) {
+				t.Errorf("ParseBBox(%q) error = %v, want ErrInvalidGeoFilter", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseBBox(%q) error = %v", tt.value, err)
+			continue
+		}
+		if *box != *tt.want {
+			t.Errorf("ParseBBox(%q) = %+v, want %+v", tt.value, *box, *tt.want)
+		}
+	}
+}
+
+func TestParseGeoPolygon(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    GeoPolygon
+		wantErr bool
+	}{
+		{"closed ring", `{"type":"Polygon","coordinates":[[[20.4,44.8],[20.5,44.8],[20.5,44.9],[20.4,44.8]]]}`,
+			GeoPolygon{{20.4, 44.8}, {20.5, 44.8}, {20.5, 44.9}}, false},
+		{"open ring", `{"type":"Polygon","coordinates":[[[20.4,44.8],[20.5,44.8],[20.5,44.9]]]}`,
+			GeoPolygon{{20.4, 44.8}, {20.5, 44.8}, {20.5, 44.9}}, false},
+		{"hole ignored", `{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`,
+			GeoPolygon{{0, 0}, {4, 0}, {4, 4}}, false},
+		{"feature", `{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`,
+			GeoPolygon{{0, 0}, {1, 0}, {1, 1}}, false},
+		{"two points", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`, nil, true},
+		{"point", `{"type":"Point","coordinates":[20.4,44.8]}`, nil, true},
+		{"no coordinates", `{"type":"Polygon","coordinates":[]}`, nil, true},
+		{"out of range", `{"type":"Polygon","coordinates":[[[0,0],[200,0],[1,1]]]}`, nil, true},
+		{"feature without polygon", `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]}}`, nil, true},
+		{"not json", `20.4,44.8`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polygon, err := ParseGeoPolygon(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGeoFilter) {
+					t.Fatalf("error = %v, want ErrInvalidGeoFilter", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(polygon) != len(tt.want) {
+				t.Fatalf("polygon = %v, want %v", polygon, tt.want)
+			}
+			for i := range polygon {
+				if polygon[i] != tt.want[i] {
+					t.Fatalf("polygon = %v, want %v", polygon, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGeoPolygonPointLimit(t *testing.T) {
+	ring := "[0,0]"
+	for i := 1; i <= 1000; i++ {
+		ring += ",[0.001,0.001]"
+	}
+	if _, err := ParseGeoPolygon(`{"type":"Polygon","coordinates":[[` + ring + `]]}`); !errors.Is(err, ErrInvalidGeoFilter) {
+		t.Errorf("error = %v, want ErrInvalidGeoFilter for 1001 points", err)
+	}
+}
+
+func TestGeoPolygonBoundsAndSQL(t *testing.T) {
+	polygon := GeoPolygon{{20.45, 44.8}, {20.5, 44.75}, {20.4, 44.85}, {20.425, 44.78}}
+
+	want := BBox{MinLng: 20.4, MinLat: 44.75, MaxLng: 20.5, MaxLat: 44.85}
+	if got := polygon.Bounds(); got != want {
+		t.Errorf("Bounds() = %+v, want %+v", got, want)
+	}
+
+	wantSQL := "((20.45,44.8),(20.5,44.75),(20.4,44.85),(20.425,44.78))"
+	if got := polygon.SQL(); got != wantSQL {
+		t.Errorf("SQL() = %s, want %s", got, wantSQL)
+	}
+}
+
+func TestPropertiesToGeoJSON(t *testing.T) {
+	lat, lng := 44.8125, 20.4612
+	properties := []models.Property{
+		{ID: 1, Latitude: &lat, Longitude: &lng},
+		{ID: 2},
+		{ID: 3, Latitude: &lat},
+	}
+
+	collection := PropertiesToGeoJSON(properties)
+	if collection.Type != "FeatureCollection" {
+		t.Errorf("type = %q", collection.Type)
+	}
+	if len(collection.Features) != 1 {
+		t.Fatalf("got %d features, want 1", len(collection.Features))
+	}
+	feature := collection.Features[0]
+	if feature.ID != 1 || feature.Geometry.Type != "Point" || feature.Geometry.Coordinates != [2]float64{lng, lat} {
+		t.Errorf("feature = %+v", feature)
+	}
+
+	if empty := PropertiesToGeoJSON(nil); empty.Features == nil {
+		t.Error("features of an empty collection are nil, want an empty array")
+	}
+}
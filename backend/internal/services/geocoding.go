@@ -0,0 +1,309 @@
+// backend/internal/services/geocoding.go
+
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+// GeocodingService resolves the free-text city, district and address of a
+// property against the local gazetteer (see cmd/tools/import_gazetteer). It
+// never calls external services.
+type GeocodingService struct {
+	db *gorm.DB
+}
+
+func NewGeocodingService(db *gorm.DB) *GeocodingService {
+	return &GeocodingService{db: db}
+}
+
+// GeocodeResult holds the most specific gazetteer places matched for an
+// address. Street is nil when only the settlement or district was found.
+type GeocodeResult struct {
+	Latitude   float64
+	Longitude  float64
+	Settlement *models.GazetteerPlace
+	District   *models.GazetteerPlace
+	Street     *models.GazetteerPlace
+	// HouseNumber is the part of the address after the street name
+	HouseNumber string
+}
+
+// Geocode looks up a settlement, then a district and street inside it. It
+// returns nil if not even the settlement is in the gazetteer.
+func (s *GeocodingService) Geocode(tx *gorm.DB, city, district, address string) (*GeocodeResult, error) {
+	if tx == nil {
+		tx = s.db
+	}
+
+	settlement, err := s.findPlace(tx, models.GazetteerSettlement, NormalizePlaceName(city), nil)
+	if err != nil || settlement == nil {
+		return nil, err
+	}
+	result := &GeocodeResult{
+		Latitude:   settlement.Latitude,
+		Longitude:  settlement.Longitude,
+		Settlement: settlement,
+	}
+
+	if district != "" {
+		if result.District, err = s.findPlace(tx, models.GazetteerDistrict, NormalizePlaceName(district), &settlement.ID); err != nil {
+			return nil, err
+		}
+		if result.District != nil {
+			result.Latitude, result.Longitude = result.District.Latitude, result.District.Longitude
+		}
+	}
+
+	street, number := SplitHouseNumber(address)
+	if street != "" {
+		if result.Street, err = s.findPlace(tx, models.GazetteerStreet, NormalizePlaceName(street), &settlement.ID); err != nil {
+			return nil, err
+		}
+		if result.Street != nil {
+			result.Latitude, result.Longitude = result.Street.Latitude, result.Street.Longitude
+			result.HouseNumber = number
+		}
+	}
+
+	return result, nil
+}
+
+// findPlace returns a place of the given kind with a matching name. Streets
+// and districts are searched inside the settlement, directly or through a
+// district. Of several settlements with the same name the largest wins; a
+// street split into several segments is placed at their average.
+func (s *GeocodingService) findPlace(tx *gorm.DB, kind, normalized string, settlementID *uint) (*models.GazetteerPlace, error) {
+	if normalized == "" {
+		return nil, nil
+	}
+
+	query := tx.Model(&models.GazetteerPlace{}).
+		Where("kind = ?", kind).
+		Where("id IN (?)", tx.Model(&models.GazetteerName{}).Select("place_id").Where("normalized = ?", normalized))
+	if settlementID != nil {
+		query = query.Where("parent_id = ? OR parent_id IN (?)", *settlementID,
+			tx.Model(&models.GazetteerPlace{}).Select("id").Where("parent_id = ? AND kind = ?", *settlementID, models.GazetteerDistrict))
+	}
+
+	var places []models.GazetteerPlace
+	if err := query.Preload("Names", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("rank DESC, id").Find(&places).Error; err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, nil
+	}
+
+	place := places[0]
+	if kind != models.GazetteerStreet {
+		return &place, nil
+	}
+	for _, p := range places[1:] {
+		place.Latitude += p.Latitude
+		place.Longitude += p.Longitude
+	}
+	place.Latitude /= float64(len(places))
+	place.Longitude /= float64(len(places))
+	return &place, nil
+}
+
+// ApplyToProperty normalises the city, district and street spelling of every
+// language of the property to the gazetteer names and fills the coordinates,
+// unless they were entered manually. Properties whose address is not in the
+// gazetteer are left unchanged.
+func (s *GeocodingService) ApplyToProperty(tx *gorm.DB, property *models.Property) error {
+	if property.Latitude != nil && property.LocationSource == "" {
+		property.LocationSource = models.LocationSourceManual
+	}
+
+	var result *GeocodeResult
+	for _, detail := range property.Details {
+		r, err := s.Geocode(tx, detail.City, detail.District, detail.Address)
+		if err != nil {
+			return err
+		}
+		if r != nil && (result == nil || r.precision() > result.precision()) {
+			result = r
+		}
+	}
+	if result == nil {
+		return nil
+	}
+
+	for i := range property.Details {
+		detail := &property.Details[i]
+		detail.City = placeName(result.Settlement, detail.Language, detail.City)
+		if result.District != nil {
+			detail.District = placeName(result.District, detail.Language, detail.District)
+		}
+		if result.Street != nil {
+			street := placeName(result.Street, detail.Language, "")
+			if street != "" {
+				detail.Address = strings.TrimSpace(street + " " + result.HouseNumber)
+			}
+		}
+	}
+
+	if property.LocationSource != models.LocationSourceManual {
+		property.Latitude = &result.Latitude
+		property.Longitude = &result.Longitude
+		property.LocationSource = models.LocationSourceGazetteer
+	}
+	return nil
+}
+
+// ImportPlaces stores a batch of gazetteer places with their names. Names
+// are normalised here; duplicates of the same spelling are dropped.
+func (s *GeocodingService) ImportPlaces(tx *gorm.DB, places []models.GazetteerPlace) error {
+	for i := range places {
+		seen := make(map[string]bool)
+		names := places[i].Names[:0]
+		for _, name := range places[i].Names {
+			name.Name = strings.TrimSpace(name.Name)
+			name.Normalized = NormalizePlaceName(name.Name)
+			key := name.Language + "\x00" + name.Name
+			if name.Normalized == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, name)
+		}
+		places[i].Names = names
+	}
+
+	return tx.CreateInBatches(places, 500).Error
+}
+
+// DeleteSource removes every place imported from source.
+func (s *GeocodingService) DeleteSource(tx *gorm.DB, source string) error {
+	return tx.Where("source = ?", source).Delete(&models.GazetteerPlace{}).Error
+}
+
+// ResolveParents links the places of source to their parents: first by
+// parent_source_id, then districts and streets without one to the nearest
+// settlement within 30 km. Larger settlements pull harder, so a street in
+// Belgrade is not assigned to a village node that happens to be closer.
+func (s *GeocodingService) ResolveParents(tx *gorm.DB, source string) error {
+	if err := tx.Exec(`
+		UPDATE gazetteer_places child SET parent_id = parent.id
+		FROM gazetteer_places parent
+		WHERE child.source = ? AND parent.source = child.source
+			AND parent.source_id = child.parent_source_id`, source).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		UPDATE gazetteer_places child SET parent_id = (
+			SELECT parent.id FROM gazetteer_places parent
+			WHERE parent.kind = ?
+				AND earth_box(ll_to_earth(child.latitude, child.longitude), 30000) @> ll_to_earth(parent.latitude, parent.longitude)
+			ORDER BY earth_distance(ll_to_earth(child.latitude, child.longitude), ll_to_earth(parent.latitude, parent.longitude)) / power(4, parent.rank)
+			LIMIT 1
+		)
+		WHERE child.source = ? AND child.parent_id IS NULL AND child.kind <> ?`,
+		models.GazetteerSettlement, source, models.GazetteerSettlement).Error
+}
+
+func (r *GeocodeResult) precision() int {
+	switch {
+	case r.Street != nil:
+		return 3
+	case r.District != nil:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// gazetteerLanguages lists the name languages tried for each property
+// language, in order. Serbian listings are written in Latin script.
+var gazetteerLanguages = map[string][]string{
+	"sr": {"sr-Latn", "sr", ""},
+	"en": {"en", "sr-Latn", ""},
+	"ru": {"ru", "sr", ""},
+}
+
+// placeName returns the spelling of place for a property language, or
+// fallback if the gazetteer has none.
+func placeName(place *models.GazetteerPlace, language, fallback string) string {
+	for _, lang := range gazetteerLanguages[language] {
+		for _, name := range place.Names {
+			if name.Language == lang {
+				return name.Name
+			}
+		}
+	}
+	return fallback
+}
+
+// streetTypes are dropped when normalising, so "Bulevar kralja Aleksandra",
+// "bul. kralja Aleksandra" and "kralja Aleksandra" all match.
+var streetTypes = map[string]bool{
+	"ulica": true, "ul": true, "bulevar": true, "bul": true, "blvd": true,
+	"street": true, "st": true, "boulevard": true, "trg": true, "square": true,
+	"bulvar": true, "plosad": true, "pl": true, "prospekt": true,
+}
+
+// NormalizePlaceName reduces a place name in Serbian (Cyrillic or Latin),
+// English or Russian to a lowercase ASCII key: Cyrillic is transliterated,
+// diacritics, punctuation and street types are removed.
+func NormalizePlaceName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if t, ok := transliteration[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	kept := words[:0]
+	for _, w := range words {
+		if !streetTypes[w] {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// SplitHouseNumber splits "Knez Mihailova 12a" into the street and "12a".
+func SplitHouseNumber(address string) (string, string) {
+	address = strings.TrimSpace(address)
+	fields := strings.Fields(address)
+	for i := len(fields) - 1; i > 0; i-- {
+		if strings.IndexFunc(fields[i], unicode.IsDigit) < 0 {
+			return strings.TrimRight(strings.Join(fields[:i+1], " "), ","), strings.Join(fields[i+1:], " ")
+		}
+	}
+	if len(fields) > 0 && strings.IndexFunc(fields[0], unicode.IsDigit) >= 0 {
+		return "", address
+	}
+	return address, ""
+}
+
+// transliteration maps Serbian and Russian Cyrillic and Serbian Latin
+// diacritics to ASCII. Where the two languages differ the Serbian form wins,
+// since that is how the gazetteer spells most names.
+var transliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'ђ': "dj", 'е': "e",
+	'ж': "z", 'з': "z", 'и': "i", 'ј': "j", 'к': "k", 'л': "l", 'љ': "lj",
+	'м': "m", 'н': "n", 'њ': "nj", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'ћ': "c", 'у': "u", 'ф': "f", 'х': "h", 'ц': "c", 'ч': "c",
+	'џ': "dz", 'ш': "s",
+	// Russian only
+	'ё': "e", 'й': "j", 'щ': "s", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+	'ю': "ju", 'я': "ja",
+	// Serbian Latin
+	'č': "c", 'ć': "c", 'đ': "dj", 'š': "s", 'ž': "z",
+}
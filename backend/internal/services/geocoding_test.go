@@ -0,0 +1,78 @@
+// backend/internal/services/geocoding_test.go
+
+package services
+
+import (
+	"testing"
+
+	"kuckuc/internal/models"
+)
+
+func TestNormalizePlaceName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Bulevar kralja Aleksandra", "kralja aleksandra"},
+		{"bul. kralja Aleksandra", "kralja aleksandra"},
+		{"Булевар краља Александра", "kralja aleksandra"},
+		{"Čukarica", "cukarica"},
+		{"Ђорђа Станојевића", "djordja stanojevica"},
+		{"Đorđa Stanojevića", "djordja stanojevica"},
+		{"Площадь Республики", "respubliki"},
+		{"Trg Republike", "republike"},
+		{"  Knez-Mihailova,  ", "knez mihailova"},
+		{"Ulica 27. marta", "27 marta"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizePlaceName(tt.name); got != tt.want {
+			t.Errorf("NormalizePlaceName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitHouseNumber(t *testing.T) {
+	tests := []struct {
+		address    string
+		wantStreet string
+		wantNumber string
+	}{
+		{"Knez Mihailova 12a", "Knez Mihailova", "12a"},
+		{"Knez Mihailova, 12", "Knez Mihailova", "12"},
+		{"Bulevar oslobođenja 12/4", "Bulevar oslobođenja", "12/4"},
+		{"Ulica 27. marta 5", "Ulica 27. marta", "5"},
+		{"Knez Mihailova", "Knez Mihailova", ""},
+		{"12", "", "12"},
+		{"  ", "", ""},
+	}
+	for _, tt := range tests {
+		street, number := SplitHouseNumber(tt.address)
+		if street != tt.wantStreet || number != tt.wantNumber {
+			t.Errorf("SplitHouseNumber(%q) = %q, %q, want %q, %q",
+				tt.address, street, number, tt.wantStreet, tt.wantNumber)
+		}
+	}
+}
+
+func TestPlaceName(t *testing.T) {
+	place := &models.GazetteerPlace{Names: []models.GazetteerName{
+		{Language: "sr", Name: "Врачар"},
+		{Language: "sr-Latn", Name: "Vračar"},
+	}}
+
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"sr", "Vračar"},
+		{"en", "Vračar"},
+		{"ru", "Врачар"},
+		{"de", "fallback"},
+	}
+	for _, tt := range tests {
+		if got := placeName(place, tt.language, "fallback"); got != tt.want {
+			t.Errorf("placeName(%s) = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
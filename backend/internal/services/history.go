@@ -0,0 +1,253 @@
+// backend/internal/services/history.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+// ErrInvalidHistoryFilter is wrapped with the reason
+var ErrInvalidHistoryFilter = errors.New("invalid history filter")
+
+// History.ActionType values
+const (
+	HistoryCreate      = "create"
+	HistoryUpdate      = "update"
+	HistoryAssign      = "assign"
+	HistoryStateChange = "state_change"
+	// HistoryStatusUpdate is only found in entries written before the
+	// lifecycle states
+	HistoryStatusUpdate       = "status_update"
+	HistoryDocumentAdd        = "document_add"
+	HistoryDocumentDelete     = "document_delete"
+	HistoryDocumentVisibility = "document_visibility"
+	HistoryRestore            = "restore"
+	HistoryDelete             = "delete"
+	HistoryUndelete           = "undelete"
+	HistoryDocumentRestore    = "document_restore"
+)
+
+var historyActions = map[string]bool{
+	HistoryCreate: true, HistoryUpdate: true, HistoryAssign: true, HistoryStateChange: true,
+	HistoryStatusUpdate: true, HistoryDocumentAdd: true, HistoryDocumentDelete: true,
+	HistoryDocumentVisibility: true, HistoryRestore: true, HistoryDelete: true, HistoryUndelete: true,
+	HistoryDocumentRestore: true,
+}
+
+// FieldChange is one changed field of a history entry. Language is set for
+// the fields of PropertyDetails; a whole language added or removed has the
+// field "details" and the details as Before or After.
+type FieldChange struct {
+	Field    string      `json:"field"`
+	Language string      `json:"language,omitempty"`
+	Before   interface{} `json:"before"`
+	After    interface{} `json:"after"`
+}
+
+// historyDetails is stored in History.Details.
+type historyDetails struct {
+	Action       string                `json:"action"`
+	From         models.LifecycleState `json:"from,omitempty"`
+	To           models.LifecycleState `json:"to,omitempty"`
+	Reason       string                `json:"reason,omitempty"`
+	DocumentID   uint                  `json:"document_id,omitempty"`
+	RestoredFrom uint                  `json:"restored_from,omitempty"`
+	Changes      []FieldChange         `json:"changes,omitempty"`
+}
+
+// recordHistory adds an entry to the property's history, with a snapshot
+// of the property after it.
+func recordHistory(tx *gorm.DB, propertyID uint, actionType string, actor Actor, details historyDetails) error {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	history := models.History{
+		PropertyID: propertyID,
+		ActionType: actionType,
+		ActionDate: time.Now(),
+		AgentID:    actor.UserID,
+		Details:    raw,
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+	return snapshotProperty(tx, propertyID, history.ID)
+}
+
+// diffDocuments returns the changes between two patch documents of a
+// property (see propertyDocument), top-level fields first, then details by
+// language.
+func diffDocuments(before, after map[string]interface{}) []FieldChange {
+	fields := make([]string, 0, len(patchableFields))
+	for field := range patchableFields {
+		if field != "details" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []FieldChange
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, FieldChange{Field: field, Before: before[field], After: after[field]})
+		}
+	}
+
+	beforeDetails, _ := before["details"].(map[string]interface{})
+	afterDetails, _ := after["details"].(map[string]interface{})
+	for _, language := range PropertyLanguages {
+		old, hadOld := beforeDetails[language].(map[string]interface{})
+		updated, hasNew := afterDetails[language].(map[string]interface{})
+		switch {
+		case !hadOld && !hasNew:
+			continue
+		case !hadOld || !hasNew:
+			change := FieldChange{Field: "details", Language: language}
+			if hadOld {
+				change.Before = old
+			} else {
+				change.After = updated
+			}
+			changes = append(changes, change)
+			continue
+		}
+
+		keys := make([]string, 0, len(old))
+		for key := range mergeKeys(old, updated) {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !reflect.DeepEqual(old[key], updated[key]) {
+				changes = append(changes, FieldChange{Field: key, Language: language, Before: old[key], After: updated[key]})
+			}
+		}
+	}
+	return changes
+}
+
+// HistoryFilter narrows a property's history. From and To are RFC 3339
+// times or dates; a date in To includes the whole day.
+type HistoryFilter struct {
+	AgentID uint `form:"agent_id"`
+	// Action is a comma-separated list of action types
+	Action string `form:"action"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+}
+
+// GetPropertyHistory returns the property's history matching filter, newest
+// first, with the email of each entry's agent. The owner.* changes are left
+// out unless actor may see the owner contract, see canSeeOwner.
+func (s *PropertyService) GetPropertyHistory(propertyID uint, filter HistoryFilter, actor Actor) ([]models.History, error) {
+	history, err := s.propertyHistory(propertyID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !canSeeOwner(actor) {
+		if err := redactOwnerChanges(history); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// propertyHistory is GetPropertyHistory with the owner changes.
+func (s *PropertyService) propertyHistory(propertyID uint, filter HistoryFilter) ([]models.History, error) {
+	query := s.db.Model(&models.History{}).
+		Select("property_history.*, users.email AS agent_email").
+		Joins("LEFT JOIN users ON users.id = property_history.agent_id").
+		Where("property_history.property_id = ?", propertyID)
+
+	if filter.AgentID != 0 {
+		query = query.Where("property_history.agent_id = ?", filter.AgentID)
+	}
+	if filter.Action != "" {
+		var actions []string
+		for _, action := range strings.Split(filter.Action, ",") {
+			action = strings.TrimSpace(action)
+			if !historyActions[action] {
+				return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidHistoryFilter, action)
+			}
+			actions = append(actions, action)
+		}
+		query = query.Where("property_history.action_type IN ?", actions)
+	}
+	if filter.From != "" {
+		from, _, err := parseHistoryTime(filter.From)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("property_history.action_date >= ?", from)
+	}
+	if filter.To != "" {
+		to, isDate, err := parseHistoryTime(filter.To)
+		if err != nil {
+			return nil, err
+		}
+		if isDate {
+			query = query.Where("property_history.action_date < ?", to.AddDate(0, 0, 1))
+		} else {
+			query = query.Where("property_history.action_date <= ?", to)
+		}
+	}
+
+	var history []models.History
+	if err := query.Order("property_history.action_date DESC, property_history.id DESC").
+		Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// redactOwnerChanges removes the owner.* changes from the details of
+// history entries.
+func redactOwnerChanges(history []models.History) error {
+	for i := range history {
+		var details map[string]json.RawMessage
+		if err := json.Unmarshal(history[i].Details, &details); err != nil || details["changes"] == nil {
+			continue
+		}
+		var changes []FieldChange
+		if err := json.Unmarshal(details["changes"], &changes); err != nil {
+			return err
+		}
+		kept := withoutOwnerChanges(changes)
+		if len(kept) == len(changes) {
+			continue
+		}
+
+		raw, err := json.Marshal(kept)
+		if err != nil {
+			return err
+		}
+		details["changes"] = raw
+		if history[i].Details, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseHistoryTime parses an RFC 3339 time or a date, and reports whether
+// it was a date.
+func parseHistoryTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%w: %q is not a date or RFC 3339 time", ErrInvalidHistoryFilter, value)
+}
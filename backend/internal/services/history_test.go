@@ -0,0 +1,139 @@
+// backend/internal/services/history_test.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	before := `{
+		"deal_type": "sale", "status": "ready", "latitude": 44.8,
+		"details": {
+			"sr": {"city": "Beograd", "price": 120000, "equipment": ["lift"]},
+			"en": {"city": "Belgrade", "price": 120000, "equipment": ["lift"]}
+		}
+	}`
+
+	tests := []struct {
+		name  string
+		after string
+		want  string
+	}{
+		{
+			"unchanged",
+			before,
+			`null`,
+		},
+		{
+			"top-level fields sorted",
+			`{
+				"status": "new", "deal_type": "rent", "latitude": 44.8,
+				"details": {
+					"sr": {"city": "Beograd", "price": 120000, "equipment": ["lift"]},
+					"en": {"city": "Belgrade", "price": 120000, "equipment": ["lift"]}
+				}
+			}`,
+			`[{"field":"deal_type","before":"sale","after":"rent"},
+			  {"field":"status","before":"ready","after":"new"}]`,
+		},
+		{
+			"field removed",
+			`{
+				"deal_type": "sale", "status": "ready",
+				"details": {
+					"sr": {"city": "Beograd", "price": 120000, "equipment": ["lift"]},
+					"en": {"city": "Belgrade", "price": 120000, "equipment": ["lift"]}
+				}
+			}`,
+			`[{"field":"latitude","before":44.8,"after":null}]`,
+		},
+		{
+			"detail fields in language order",
+			`{
+				"deal_type": "sale", "status": "ready", "latitude": 44.8,
+				"details": {
+					"sr": {"city": "Beograd", "price": 110000, "equipment": ["lift", "klima"]},
+					"en": {"city": "Belgrade", "price": 110000, "equipment": ["lift"]}
+				}
+			}`,
+			`[{"field":"equipment","language":"sr","before":["lift"],"after":["lift","klima"]},
+			  {"field":"price","language":"sr","before":120000,"after":110000},
+			  {"field":"price","language":"en","before":120000,"after":110000}]`,
+		},
+		{
+			"language added and removed",
+			`{
+				"deal_type": "sale", "status": "ready", "latitude": 44.8,
+				"details": {
+					"sr": {"city": "Beograd", "price": 120000, "equipment": ["lift"]},
+					"ru": {"city": "Белград"}
+				}
+			}`,
+			`[{"field":"details","language":"en","before":{"city":"Belgrade","price":120000,"equipment":["lift"]},"after":null},
+			  {"field":"details","language":"ru","before":null,"after":{"city":"Белград"}}]`,
+		},
+		{
+			"no details",
+			`{"deal_type": "sale", "status": "ready", "latitude": 44.8}`,
+			`[{"field":"details","language":"sr","before":{"city":"Beograd","price":120000,"equipment":["lift"]},"after":null},
+			  {"field":"details","language":"en","before":{"city":"Belgrade","price":120000,"equipment":["lift"]},"after":null}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffDocuments(
+				decodeJSON(t, before).(map[string]interface{}),
+				decodeJSON(t, tt.after).(map[string]interface{}),
+			)
+			got, err := json.Marshal(changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := json.Marshal(decodeJSON(t, tt.want))
+			// Compare through the same encoding so field order matches
+			var normalized interface{}
+			if err := json.Unmarshal(got, &normalized); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := json.Marshal(normalized); string(got) != string(want) {
+				t.Errorf("diffDocuments = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		want     time.Time
+		wantDate bool
+		wantErr  bool
+	}{
+		{"2026-03-01T10:30:00Z", time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC), false, false},
+		{"2026-03-01T10:30:00+01:00", time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC), false, false},
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), true, false},
+		{"01.03.2026", time.Time{}, false, true},
+		{"2026-02-30", time.Time{}, false, true},
+		{"", time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		got, isDate, err := parseHistoryTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHistoryTime(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidHistoryFilter) {
+				t.Errorf("parseHistoryTime(%q) error %v is not ErrInvalidHistoryFilter", tt.value, err)
+			}
+			continue
+		}
+		if !got.Equal(tt.want) || isDate != tt.wantDate {
+			t.Errorf("parseHistoryTime(%q) = %s, %v, want %s, %v", tt.value, got, isDate, tt.want, tt.wantDate)
+		}
+	}
+}
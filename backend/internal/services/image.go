@@ -0,0 +1,407 @@
+// backend/internal/services/image.go
+
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/gen2brain/webp"
+	"golang.org/x/image/draw"
+)
+
+// ImageVariantSpec describes a resized copy generated for every uploaded
+// image, once as JPEG and once as WebP.
+type ImageVariantSpec struct {
+	Name    string
+	MaxSize int
+	Quality int
+}
+
+var ImageVariants = []ImageVariantSpec{
+	{Name: "thumbnail", MaxSize: 320, Quality: 75},
+	{Name: "medium", MaxSize: 1024, Quality: 80},
+	{Name: "large", MaxSize: 1920, Quality: 85},
+}
+
+const ThumbnailVariant = "thumbnail"
+
+// Formats of image variants. WebP is smaller; JPEG is shown where WebP is
+// not supported.
+const (
+	ImageFormatJPEG = "jpeg"
+	ImageFormatWebP = "webp"
+)
+
+type ImageVariant struct {
+	Name   string
+	Format string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// StripImageMetadata removes EXIF, XMP, IPTC and comment data from JPEG, PNG
+// and GIF files without re-encoding them. JPEG orientation is preserved
+// through a minimal EXIF block so photos taken on phones are still displayed
+// upright. Other formats are returned unchanged.
+func StripImageMetadata(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEGMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNGMetadata(data)
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return stripGIFMetadata(data)
+	default:
+		return data, nil
+	}
+}
+
+// GenerateImageVariants decodes an image and returns JPEG and WebP copies
+// resized to every ImageVariants size. Re-encoding drops all metadata.
+func GenerateImageVariants(data []byte) ([]ImageVariant, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	orientation := jpegOrientation(data)
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	// Orientations 5-8 swap width and height
+	rotated := orientation >= 5 && orientation <= 8
+
+	variants := make([]ImageVariant, 0, 2*len(ImageVariants))
+	for _, spec := range ImageVariants {
+		// Scale first and rotate the small result, which is much cheaper
+		// than rotating the full-size original
+		scaledWidth, scaledHeight := fitWithin(srcWidth, srcHeight, spec.MaxSize)
+		width, height := scaledWidth, scaledHeight
+		if rotated {
+			width, height = scaledHeight, scaledWidth
+		}
+
+		scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+		// JPEG has no alpha channel, so flatten transparent images onto white
+		draw.Draw(scaled, scaled.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+		oriented := applyOrientation(scaled, orientation)
+
+		var jpegData bytes.Buffer
+		if err := jpeg.Encode(&jpegData, oriented, &jpeg.Options{Quality: spec.Quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", spec.Name, err)
+		}
+		var webpData bytes.Buffer
+		if err := webp.Encode(&webpData, oriented, webp.Options{Quality: spec.Quality, Method: webp.DefaultMethod}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s webp variant: %w", spec.Name, err)
+		}
+
+		variants = append(variants,
+			ImageVariant{Name: spec.Name, Format: ImageFormatJPEG, Width: width, Height: height, Data: jpegData.Bytes()},
+			ImageVariant{Name: spec.Name, Format: ImageFormatWebP, Width: width, Height: height, Data: webpData.Bytes()},
+		)
+	}
+
+	return variants, nil
+}
+
+// fitWithin scales width and height down so neither exceeds maxSize.
+// Images are never upscaled.
+func fitWithin(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+var errInvalidJPEG = errors.New("invalid jpeg")
+
+// stripJPEGMetadata drops APPn (except JFIF, ICC and Adobe) and COM segments.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	orientation := jpegOrientation(data)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	// The orientation block goes right after the JFIF APP0 segment, if any
+	exifPending := orientation > 1
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, errInvalidJPEG
+		}
+		marker := data[pos+1]
+		if exifPending && marker != 0xE0 {
+			out.Write(orientationEXIF(orientation))
+			exifPending = false
+		}
+
+		// Start of scan: the rest is entropy-coded data followed by EOI
+		if marker == 0xDA {
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errInvalidJPEG
+		}
+
+		keep := true
+		switch {
+		case marker == 0xFE: // COM
+			keep = false
+		case marker >= 0xE1 && marker <= 0xEF: // APP1..APP15
+			keep = marker == 0xE2 || marker == 0xEE // ICC profile, Adobe
+		}
+		if keep {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	return nil, errInvalidJPEG
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 if absent.
+func jpegOrientation(data []byte) int {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF && data[pos+1] != 0xDA {
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : end]
+		if data[pos+1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos = end
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientationEXIF builds an APP1 segment holding only the orientation tag.
+func orientationEXIF(orientation int) []byte {
+	tiff := []byte{
+		'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, // header, IFD at offset 8
+		0x01, 0x00, // one entry
+		0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, byte(orientation), 0x00, 0x00, 0x00, // Orientation, SHORT, 1
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// applyOrientation rotates and flips img according to an EXIF orientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 CW
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 270 CW
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// stripPNGMetadata drops textual, EXIF and timestamp chunks.
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("invalid png")
+		}
+
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// GIF blocks and extension labels
+const (
+	gifExtension        = 0x21
+	gifImageDescriptor  = 0x2C
+	gifTrailer          = 0x3B
+	gifCommentLabel     = 0xFE
+	gifApplicationLabel = 0xFF
+)
+
+var errInvalidGIF = errors.New("invalid gif")
+
+// stripGIFMetadata drops comment extensions and application extensions
+// other than the looping ones animations need, such as XMP. Anything after
+// the trailer is dropped too.
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return nil, errInvalidGIF
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, errInvalidGIF
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:pos])
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case gifTrailer:
+			out.WriteByte(gifTrailer)
+			return out.Bytes(), nil
+		case gifExtension:
+			if pos+2 > len(data) {
+				return nil, errInvalidGIF
+			}
+			label := data[pos+1]
+			end, err := skipGIFSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			pos = end
+			if label == gifCommentLabel || label == gifApplicationLabel && !gifLoopExtension(data[start+2:end]) {
+				continue
+			}
+		case gifImageDescriptor:
+			if pos+10 > len(data) {
+				return nil, errInvalidGIF
+			}
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data
+			end, err := skipGIFSubBlocks(data, pos+1)
+			if err != nil {
+				return nil, err
+			}
+			pos = end
+		default:
+			return nil, errInvalidGIF
+		}
+		out.Write(data[start:pos])
+	}
+	return nil, errInvalidGIF
+}
+
+// skipGIFSubBlocks returns the position after the data sub-blocks starting
+// at pos and their terminator.
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+	return 0, errInvalidGIF
+}
+
+// gifLoopExtension reports whether the sub-blocks of an application
+// extension set the loop count of an animation.
+func gifLoopExtension(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+	identifier := string(blocks[1:12])
+	return identifier == "NETSCAPE2.0" || identifier == "ANIMEXTS1.0"
+}
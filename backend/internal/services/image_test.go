@@ -0,0 +1,228 @@
+// backend/internal/services/image_test.go
+
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withSegments inserts JPEG segments right after the SOI marker.
+func withSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func segment(marker byte, payload string) []byte {
+	length := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	bigEndian := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x02,
+		0x01, 0x0F, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 'A', 'c', 'm', 0x00, // Make
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x03, 0x00, 0x00, // Orientation 3
+	}
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian", orientationEXIF(6)[10:], 6},
+		{"big endian, second entry", bigEndian, 3},
+		{"out of range", orientationEXIF(9)[10:], 1},
+		{"no orientation tag", []byte{'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}, 1},
+		{"unknown byte order", []byte{'X', 'X', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}, 1},
+		{"IFD past the end", []byte{'I', 'I', 0x2A, 0x00, 0xFF, 0x00, 0x00, 0x00}, 1},
+		{"entry cut off", orientationEXIF(6)[10:20], 1},
+		{"too short", []byte("II"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	plain := testJPEG(t, 16, 8)
+	jfif := segment(0xE0, "JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	gps := segment(0xE1, "Exif\x00\x00GPS 44.8125N 20.4612E")
+	xmp := segment(0xE1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>")
+	comment := segment(0xFE, "Agent phone")
+	icc := segment(0xE2, "ICC_PROFILE\x00")
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantOrientation int
+		removed         [][]byte
+		kept            [][]byte
+	}{
+		{"exif, xmp and comment", withSegments(plain, gps, xmp, comment), 1, [][]byte{gps, xmp, comment}, nil},
+		{"icc profile kept", withSegments(plain, icc, gps), 1, [][]byte{gps}, [][]byte{icc}},
+		{"orientation kept", withSegments(plain, orientationEXIF(6), comment), 6, [][]byte{comment}, nil},
+		{"orientation after jfif", withSegments(plain, jfif, orientationEXIF(8)), 8, nil, [][]byte{jfif}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := stripJPEGMetadata(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.removed {
+				if bytes.Contains(stripped, s) {
+					t.Errorf("segment %q was not removed", s[4:])
+				}
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(stripped, s) {
+					t.Errorf("segment %q was removed", s[4:])
+				}
+			}
+			if got := jpegOrientation(stripped); got != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", got, tt.wantOrientation)
+			}
+			if tt.wantOrientation > 1 && bytes.HasPrefix(tt.data[2:], jfif) && !bytes.HasPrefix(stripped[2:], jfif) {
+				t.Error("JFIF segment is no longer first")
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("stripped image does not decode: %v", err)
+			}
+		})
+	}
+
+	if _, err := stripJPEGMetadata([]byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}); err == nil {
+		t.Error("expected an error for a corrupt JPEG")
+	}
+}
+
+// gifExtensionBlock returns a GIF extension with payload in one sub-block.
+func gifExtensionBlock(label byte, payload string) []byte {
+	block := append([]byte{0x21, label, byte(len(payload))}, payload...)
+	return append(block, 0x00)
+}
+
+func TestStripGIFMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.White, color.Black})
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	plain := buf.Bytes()
+
+	comment := gifExtensionBlock(0xFE, "Agent phone")
+	xmp := gifExtensionBlock(0xFF, "XMP DataXMP<x:xmpmeta/>")
+	loop := gifExtensionBlock(0xFF, "NETSCAPE2.0")
+	// The encoder writes no global color table, so blocks start after the
+	// 13 byte header
+	header := 13
+	data := append(append(append(append([]byte{}, plain[:header]...), comment...), xmp...), plain[header:]...)
+	data = append(data, "trailing"...)
+
+	stripped, err := StripImageMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, removed := range [][]byte{comment, xmp, []byte("trailing")} {
+		if bytes.Contains(stripped, removed) {
+			t.Errorf("%q was not removed", removed)
+		}
+	}
+	if !bytes.Contains(stripped, loop[:len(loop)-1]) {
+		t.Error("loop extension was removed")
+	}
+	if !bytes.Equal(stripped, plain) {
+		t.Errorf("stripped GIF differs from the original:\n%x\n%x", stripped, plain)
+	}
+	if decoded, err := gif.DecodeAll(bytes.NewReader(stripped)); err != nil || len(decoded.Image) != 2 {
+		t.Errorf("stripped image does not decode: %v", err)
+	}
+
+	if _, err := StripImageMetadata(plain[:len(plain)-5]); err == nil {
+		t.Error("expected an error for a truncated GIF")
+	}
+}
+
+func TestGenerateImageVariants(t *testing.T) {
+	data := withSegments(testJPEG(t, 400, 200), orientationEXIF(6))
+
+	variants, err := GenerateImageVariants(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 2*len(ImageVariants) {
+		t.Fatalf("got %d variants, want %d", len(variants), 2*len(ImageVariants))
+	}
+
+	for _, variant := range variants {
+		switch variant.Format {
+		case ImageFormatJPEG:
+			if !bytes.HasPrefix(variant.Data, []byte{0xFF, 0xD8, 0xFF}) {
+				t.Errorf("%s jpeg variant is not a JPEG", variant.Name)
+			}
+		case ImageFormatWebP:
+			if len(variant.Data) < 12 || string(variant.Data[:4]) != "RIFF" || string(variant.Data[8:12]) != "WEBP" {
+				t.Errorf("%s webp variant is not a WebP", variant.Name)
+			}
+		default:
+			t.Errorf("unexpected format %q", variant.Format)
+		}
+
+		// Rotated 90 degrees, and the thumbnail is scaled down to 320
+		wantWidth, wantHeight := 200, 400
+		if variant.Name == ThumbnailVariant {
+			wantWidth, wantHeight = 160, 320
+		}
+		if variant.Width != wantWidth || variant.Height != wantHeight {
+			t.Errorf("%s %s variant is %dx%d, want %dx%d", variant.Name, variant.Format,
+				variant.Width, variant.Height, wantWidth, wantHeight)
+		}
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		width, height, maxSize int
+		wantWidth, wantHeight  int
+	}{
+		{100, 50, 320, 100, 50},
+		{640, 480, 320, 320, 240},
+		{480, 640, 320, 240, 320},
+		{5000, 1, 320, 320, 1},
+	}
+	for _, tt := range tests {
+		width, height := fitWithin(tt.width, tt.height, tt.maxSize)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("fitWithin(%d, %d, %d) = %d, %d, want %d, %d",
+				tt.width, tt.height, tt.maxSize, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
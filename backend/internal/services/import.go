@@ -0,0 +1,746 @@
+// backend/internal/services/import.go
+
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+// ErrInvalidImport is wrapped with the reason when the file cannot be read
+// at all. Problems with single rows are reported in ImportReport.Errors.
+var ErrInvalidImport = errors.New("invalid import file")
+
+// MaxImportRows is the most rows one import may have, header rows excluded
+const MaxImportRows = 5000
+
+type ImportFormat string
+
+const (
+	ImportXLSX ImportFormat = "xlsx"
+	ImportCSV  ImportFormat = "csv"
+)
+
+// ImportOptions control ImportProperties.
+type ImportOptions struct {
+	Format ImportFormat
+	// Language of the details in rows without a Language column
+	Language string
+	// DryRun validates the file and reports what would change, without
+	// saving anything
+	DryRun bool
+}
+
+// ImportError is a problem with one row of an import. Row is the row
+// number in the spreadsheet; 1 is the header.
+type ImportError struct {
+	Sheet   string `json:"sheet,omitempty"`
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportedProperty.Action values
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+// ImportedProperty is what an import did, or would do, with one property
+// code. Changes are only listed for updates.
+type ImportedProperty struct {
+	PropertyCode string        `json:"property_code"`
+	ID           uint          `json:"id,omitempty"`
+	Action       string        `json:"action"`
+	Sheet        string        `json:"sheet,omitempty"`
+	Rows         []int         `json:"rows"`
+	Languages    []string      `json:"languages"`
+	Changes      []FieldChange `json:"changes,omitempty"`
+}
+
+// ImportReport is the result of ImportProperties. If there are any errors
+// nothing is saved.
+type ImportReport struct {
+	DryRun    bool `json:"dry_run"`
+	Rows      int  `json:"rows"`
+	Created   int  `json:"created"`
+	Updated   int  `json:"updated"`
+	Unchanged int  `json:"unchanged"`
+	// IgnoredColumns are header cells that are not import columns
+	IgnoredColumns []string           `json:"ignored_columns,omitempty"`
+	Properties     []ImportedProperty `json:"properties"`
+	Errors         []ImportError      `json:"errors"`
+}
+
+// propertySheets are the sheets of the export, one per property type. An
+// import takes the property type of a row from its sheet, unless the sheet
+// has a Property Type column.
+var propertySheets = map[models.PropertyType]string{
+	models.House:     "Houses",
+	models.Apartment: "Apartments",
+	models.Office:    "Offices",
+}
+
+// importLevel tells what an import column belongs to.
+type importLevel int
+
+const (
+	importProperty importLevel = iota
+	importDetail
+	importOwner
+)
+
+// importTarget is what an import row is applied to.
+type importTarget struct {
+	property *models.Property
+	detail   *models.PropertyDetails
+	owner    *models.PropertyOwner
+}
+
+// importColumn is a column an import reads. The header is matched ignoring
+// case, spaces and underscores.
+type importColumn struct {
+	header string
+	level  importLevel
+	set    func(t importTarget, value string) error
+}
+
+var importColumns = []importColumn{
+	{"Property Type", importProperty, func(t importTarget, v string) error {
+		switch models.PropertyType(strings.ToLower(v)) {
+		case models.House, models.Apartment, models.Office:
+			t.property.PropertyType = models.PropertyType(strings.ToLower(v))
+			return nil
+		}
+		return fmt.Errorf("must be house, apartment or office")
+	}},
+	{"Deal Type", importProperty, func(t importTarget, v string) error {
+		switch models.DealType(strings.ToLower(v)) {
+		case models.Sale, models.Rent:
+			t.property.DealType = models.DealType(strings.ToLower(v))
+			return nil
+		}
+		return fmt.Errorf("must be sale or rent")
+	}},
+	{"Status", importProperty, func(t importTarget, v string) error {
+		switch models.PropertyStatus(strings.ToLower(v)) {
+		case models.Ready, models.New, models.Shared:
+			t.property.Status = models.PropertyStatus(strings.ToLower(v))
+			return nil
+		}
+		return fmt.Errorf("must be ready, new or shared")
+	}},
+	{"City", importDetail, func(t importTarget, v string) error { t.detail.City = v; return nil }},
+	{"District", importDetail, func(t importTarget, v string) error { t.detail.District = v; return nil }},
+	{"Address", importDetail, func(t importTarget, v string) error { t.detail.Address = v; return nil }},
+	{"Floor", importDetail, func(t importTarget, v string) error { return parseImportInt(v, true, &t.detail.FloorNumber) }},
+	{"Total Floors", importDetail, func(t importTarget, v string) error { return parseImportInt(v, false, &t.detail.TotalFloors) }},
+	{"Living Area", importDetail, func(t importTarget, v string) error { return parseImportFloat(v, &t.detail.LivingArea) }},
+	{"Rooms", importDetail, func(t importTarget, v string) error { return parseImportInt(v, false, &t.detail.Rooms) }},
+	{"Bedrooms", importDetail, func(t importTarget, v string) error { return parseImportInt(v, false, &t.detail.Bedrooms) }},
+	{"Bathrooms", importDetail, func(t importTarget, v string) error { return parseImportInt(v, false, &t.detail.Bathrooms) }},
+	{"Plot Size", importDetail, func(t importTarget, v string) error { return parseImportFloat(v, &t.detail.PlotSize) }},
+	{"Registered", importDetail, func(t importTarget, v string) error { return parseImportBool(v, &t.detail.Registered) }},
+	{"Heating Type", importDetail, func(t importTarget, v string) error { t.detail.HeatingType = v; return nil }},
+	{"Water Supply", importDetail, func(t importTarget, v string) error { return parseImportBool(v, &t.detail.WaterSupply) }},
+	{"Sewage", importDetail, func(t importTarget, v string) error { return parseImportBool(v, &t.detail.Sewage) }},
+	{"Price", importDetail, func(t importTarget, v string) error { return parseImportFloat(v, &t.detail.Price) }},
+	{"Description", importDetail, func(t importTarget, v string) error { t.detail.Description = v; return nil }},
+	{"Owner Properties Count", importOwner, func(t importTarget, v string) error {
+		return parseImportInt(v, false, &t.owner.PropertiesCount)
+	}},
+	{"Contract Status", importOwner, func(t importTarget, v string) error { t.owner.ContractStatus = v; return nil }},
+	{"Contract Number", importOwner, func(t importTarget, v string) error {
+		if len(v) > 50 {
+			return fmt.Errorf("is longer than 50 characters")
+		}
+		t.owner.ContractNumber = v
+		return nil
+	}},
+	{"Contract End Date", importOwner, func(t importTarget, v string) error { return parseImportDate(v, &t.owner.ContractEndDate) }},
+}
+
+// Columns with their own handling, and export columns that are set by the
+// server and skipped on import
+const (
+	importCodeColumn     = "propertycode"
+	importLanguageColumn = "language"
+)
+
+var skippedExportColumns = map[string]bool{
+	"agentcode": true, "lifecyclestate": true, "creationdate": true, "lastupdate": true, "documentscount": true,
+}
+
+var importColumnsByKey = func() map[string]importColumn {
+	columns := make(map[string]importColumn, len(importColumns))
+	for _, column := range importColumns {
+		columns[importColumnKey(column.header)] = column
+	}
+	return columns
+}()
+
+func importColumnKey(header string) string {
+	return strings.NewReplacer(" ", "", "_", "", "\t", "").Replace(strings.ToLower(strings.TrimSpace(header)))
+}
+
+// importCell is a non-empty cell of an import row.
+type importCell struct {
+	header string
+	column importColumn
+	value  string
+}
+
+// importRow is a row of an import file.
+type importRow struct {
+	sheet        string
+	row          int
+	code         string
+	language     string
+	propertyType models.PropertyType
+	cells        []importCell
+}
+
+// importSheet is a sheet of an import file. CSV files have one, without a
+// property type.
+type importSheet struct {
+	name         string
+	propertyType models.PropertyType
+	rows         [][]string
+}
+
+// ImportProperties creates and updates properties from a spreadsheet in the
+// layout of the export: one sheet per property type with a header row, one
+// row per property and language. A CSV file is one such sheet with a
+// Property Type column. Rows are keyed by Property Code: a known code
+// updates that property, an unknown one creates a draft with that code. A
+// Language column lets one file hold the details of a property in several
+// languages; rows without one are in options.Language.
+//
+// Only the columns in the file are changed, and empty cells leave the value
+// as it is. Every row is validated and all of them are saved in one
+// transaction, so a file with any error saves nothing. Each property
+// gets a history entry with its changes.
+func (s *PropertyService) ImportProperties(r io.Reader, options ImportOptions, actor Actor) (*ImportReport, error) {
+	if !actor.Can(PermPropertiesCreate) && !actor.Can(PermPropertiesEditOwn) && !actor.Can(PermPropertiesEditAny) {
+		return nil, ErrForbidden
+	}
+	if !validLanguage(options.Language) {
+		return nil, fmt.Errorf("%w: unknown language %q", ErrInvalidImport, options.Language)
+	}
+
+	sheets, err := readImportFile(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: options.DryRun, Properties: []ImportedProperty{}, Errors: []ImportError{}}
+	rows := parseImportSheets(sheets, options.Language, report)
+	if report.Rows > MaxImportRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, MaxImportRows)
+	}
+
+	// Rows are grouped by property code, in the order the codes first appear
+	var codes []string
+	groups := make(map[string][]importRow)
+	for _, row := range rows {
+		if _, ok := groups[row.code]; !ok {
+			codes = append(codes, row.code)
+		}
+		groups[row.code] = append(groups[row.code], row)
+	}
+
+	rollback := errors.New("rollback")
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, code := range codes {
+			group := groups[code]
+			if !checkImportGroup(group, report) {
+				continue
+			}
+			imported, err := s.importProperty(tx, group, actor, report)
+			if err != nil {
+				return err
+			}
+			if imported == nil {
+				continue
+			}
+			switch imported.Action {
+			case ImportCreate:
+				report.Created++
+			case ImportUpdate:
+				report.Updated++
+			default:
+				report.Unchanged++
+			}
+			if options.DryRun && imported.Action == ImportCreate {
+				imported.ID = 0
+			}
+			report.Properties = append(report.Properties, *imported)
+		}
+
+		if options.DryRun || len(report.Errors) > 0 {
+			return rollback
+		}
+		return nil
+	})
+	if err != nil && err != rollback {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importProperty creates or updates the property of one group of rows. It
+// returns nil if the rows had errors; they are added to report.
+func (s *PropertyService) importProperty(tx *gorm.DB, group []importRow, actor Actor, report *ImportReport) (*ImportedProperty, error) {
+	first := group[0]
+	imported := &ImportedProperty{PropertyCode: first.code, Sheet: first.sheet}
+	for _, row := range group {
+		imported.Rows = append(imported.Rows, row.row)
+		imported.Languages = append(imported.Languages, row.language)
+	}
+	rowError := func(row importRow, column, message string) {
+		report.Errors = append(report.Errors, ImportError{Sheet: row.sheet, Row: row.row, Column: column, Message: message})
+	}
+
+	var stored models.Property
+	if err := tx.Unscoped().Where("property_code = ?", first.code).Limit(1).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	if stored.DeletedAt.Valid {
+		rowError(first, "Property Code", "the property is deleted; restore it before importing")
+		return nil, nil
+	}
+
+	if stored.ID == 0 {
+		if !actor.Can(PermPropertiesCreate) {
+			rowError(first, "Property Code", "no property has this code, and you may not create properties")
+			return nil, nil
+		}
+
+		property := models.Property{PropertyCode: first.code}
+		var owner models.PropertyOwner
+		hasOwner, ok := applyImportRows(group, &property, &owner, rowError)
+		if !ok {
+			return nil, nil
+		}
+		for column, value := range map[string]string{
+			"Property Type": string(property.PropertyType),
+			"Deal Type":     string(property.DealType),
+			"Status":        string(property.Status),
+		} {
+			if value == "" {
+				rowError(first, column, "is required for new properties")
+				ok = false
+			}
+		}
+		if !ok {
+			return nil, nil
+		}
+		if hasOwner {
+			property.Owner = owner
+		}
+
+		if err := s.createProperty(tx, &property, actor, "property_imported"); err != nil {
+			return nil, err
+		}
+		imported.ID = property.ID
+		imported.Action = ImportCreate
+		return imported, nil
+	}
+
+	existing, err := s.lockForEdit(tx, actor, stored.ID, 0)
+	if errors.Is(err, ErrForbidden) {
+		rowError(first, "Property Code", "you may not edit this property")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("property_id = ?", existing.ID).Find(&existing.Details).Error; err != nil {
+		return nil, err
+	}
+	var currentOwner models.PropertyOwner
+	if err := tx.Where("property_id = ?", existing.ID).Order("id").Limit(1).Find(&currentOwner).Error; err != nil {
+		return nil, err
+	}
+
+	updated := *existing
+	updated.Details = append([]models.PropertyDetails(nil), existing.Details...)
+	owner := currentOwner
+	hasOwner, ok := applyImportRows(group, &updated, &owner, rowError)
+	if !ok {
+		return nil, nil
+	}
+
+	before, err := propertyDocument(existing)
+	if err != nil {
+		return nil, err
+	}
+	after, err := propertyDocument(&updated)
+	if err != nil {
+		return nil, err
+	}
+	ownerChanged := false
+	if hasOwner {
+		ownerChanges, err := diffOwners(currentOwner, owner)
+		if err != nil {
+			return nil, err
+		}
+		ownerChanged = currentOwner.ID == 0 || len(ownerChanges) > 0
+	}
+	imported.ID = existing.ID
+	if len(diffDocuments(before, after)) == 0 && !ownerChanged {
+		imported.Action = ImportUnchanged
+		return imported, nil
+	}
+
+	updated.Owner = models.PropertyOwner{}
+	if err := s.saveProperty(tx, &updated, existing); err != nil {
+		return nil, err
+	}
+	if ownerChanged {
+		owner.PropertyID = existing.ID
+		if err := tx.Save(&owner).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	saved, err := s.loadDocument(tx, &updated)
+	if err != nil {
+		return nil, err
+	}
+	imported.Changes = diffDocuments(before, saved)
+	if ownerChanged {
+		ownerChanges, err := diffOwners(currentOwner, owner)
+		if err != nil {
+			return nil, err
+		}
+		imported.Changes = append(imported.Changes, ownerChanges...)
+	}
+	if err := recordHistory(tx, existing.ID, HistoryUpdate, actor, historyDetails{
+		Action:  "property_imported",
+		Changes: imported.Changes,
+	}); err != nil {
+		return nil, err
+	}
+	imported.Action = ImportUpdate
+	return imported, nil
+}
+
+// applyImportRows applies the cells of a group of rows to property, its
+// details in each row's language and owner. It reports whether any owner
+// column had a value, see zeroOwnerCell, and whether all cells were valid.
+func applyImportRows(group []importRow, property *models.Property, owner *models.PropertyOwner,
+	rowError func(row importRow, column, message string)) (hasOwner, ok bool) {
+	ok = true
+	for _, row := range group {
+		if row.propertyType != "" {
+			property.PropertyType = row.propertyType
+		}
+
+		index := -1
+		for i := range property.Details {
+			if property.Details[i].Language == row.language {
+				index = i
+			}
+		}
+		if index < 0 {
+			property.Details = append(property.Details, models.PropertyDetails{
+				PropertyID: property.ID,
+				Language:   row.language,
+			})
+			index = len(property.Details) - 1
+		}
+
+		target := importTarget{property: property, detail: &property.Details[index], owner: owner}
+		for _, cell := range row.cells {
+			if err := cell.column.set(target, cell.value); err != nil {
+				rowError(row, cell.header, fmt.Sprintf("%q %v", cell.value, err))
+				ok = false
+			}
+			if cell.column.level == importOwner && !zeroOwnerCell(cell) {
+				hasOwner = true
+			}
+		}
+	}
+	return hasOwner, ok
+}
+
+// zeroOwnerCell reports whether an owner cell holds what the export writes
+// for a property without an owner: a count of 0 or the zero date. Such
+// cells alone do not add an owner.
+func zeroOwnerCell(cell importCell) bool {
+	switch cell.column.header {
+	case "Owner Properties Count":
+		var count int
+		return parseImportInt(cell.value, false, &count) == nil && count == 0
+	case "Contract End Date":
+		var date time.Time
+		return parseImportDate(cell.value, &date) == nil && date.IsZero()
+	}
+	return false
+}
+
+// checkImportGroup checks that the rows of one property code agree on the
+// property and owner columns and have one row per language.
+func checkImportGroup(group []importRow, report *ImportReport) bool {
+	ok := true
+	type firstValue struct {
+		value string
+		row   importRow
+	}
+	values := make(map[string]firstValue)
+	languages := make(map[string]importRow)
+	for _, row := range group {
+		if other, seen := languages[row.language]; seen {
+			report.Errors = append(report.Errors, ImportError{Sheet: row.sheet, Row: row.row, Column: "Language",
+				Message: fmt.Sprintf("%s has details in %s in row %d already", row.code, row.language, other.row)})
+			ok = false
+		}
+		languages[row.language] = row
+
+		cells := row.cells
+		if row.propertyType != "" {
+			cells = append([]importCell{{header: "Property Type", value: string(row.propertyType)}}, cells...)
+		}
+		for _, cell := range cells {
+			if cell.column.level == importDetail {
+				continue
+			}
+			key := importColumnKey(cell.header)
+			first, seen := values[key]
+			if !seen {
+				values[key] = firstValue{cell.value, row}
+				continue
+			}
+			if !strings.EqualFold(first.value, cell.value) {
+				report.Errors = append(report.Errors, ImportError{Sheet: row.sheet, Row: row.row, Column: cell.header,
+					Message: fmt.Sprintf("%q differs from %q in row %d of %s", cell.value, first.value, first.row.row, row.code)})
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+// parseImportSheets turns the sheets into rows, adding header and row
+// errors and ignored columns to report.
+func parseImportSheets(sheets []importSheet, language string, report *ImportReport) []importRow {
+	var rows []importRow
+	ignored := make(map[string]bool)
+	for _, sheet := range sheets {
+		if len(sheet.rows) == 0 {
+			continue
+		}
+		sheetError := func(row int, column, message string) {
+			report.Errors = append(report.Errors, ImportError{Sheet: sheet.name, Row: row, Column: column, Message: message})
+		}
+
+		header := sheet.rows[0]
+		codeIndex, languageIndex := -1, -1
+		columns := make(map[int]importColumn)
+		seen := make(map[string]bool)
+		for i, cell := range header {
+			key := importColumnKey(cell)
+			if key == "" {
+				continue
+			}
+			if seen[key] {
+				sheetError(1, cell, "the column appears twice")
+				continue
+			}
+			seen[key] = true
+
+			switch column, ok := importColumnsByKey[key]; {
+			case key == importCodeColumn:
+				codeIndex = i
+			case key == importLanguageColumn:
+				languageIndex = i
+			case ok:
+				columns[i] = column
+			case !skippedExportColumns[key] && !ignored[cell]:
+				ignored[cell] = true
+				report.IgnoredColumns = append(report.IgnoredColumns, cell)
+			}
+		}
+		if codeIndex < 0 {
+			sheetError(1, "Property Code", "the column is missing")
+			continue
+		}
+
+		for i, cells := range sheet.rows[1:] {
+			rowNumber := i + 2
+			value := func(index int) string {
+				if index < 0 || index >= len(cells) {
+					return ""
+				}
+				return strings.TrimSpace(cells[index])
+			}
+			empty := true
+			for index := range cells {
+				if value(index) != "" {
+					empty = false
+				}
+			}
+			if empty {
+				continue
+			}
+			report.Rows++
+
+			row := importRow{
+				sheet:        sheet.name,
+				row:          rowNumber,
+				code:         value(codeIndex),
+				language:     strings.ToLower(value(languageIndex)),
+				propertyType: sheet.propertyType,
+			}
+			if row.language == "" {
+				row.language = language
+			}
+			if row.code == "" {
+				sheetError(rowNumber, "Property Code", "is required")
+				continue
+			}
+			if len(row.code) > 50 {
+				sheetError(rowNumber, "Property Code", "is longer than 50 characters")
+				continue
+			}
+			if !validLanguage(row.language) {
+				sheetError(rowNumber, "Language", fmt.Sprintf("%q must be one of %s", row.language, strings.Join(PropertyLanguages, ", ")))
+				continue
+			}
+
+			indexes := make([]int, 0, len(columns))
+			for index := range columns {
+				indexes = append(indexes, index)
+			}
+			sort.Ints(indexes)
+			for _, index := range indexes {
+				if cell := value(index); cell != "" {
+					row.cells = append(row.cells, importCell{header: header[index], column: columns[index], value: cell})
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// readImportFile reads the sheets of an XLSX file, or the one sheet of a
+// CSV file. CSV files may be separated by commas or semicolons.
+func readImportFile(r io.Reader, format ImportFormat) ([]importSheet, error) {
+	switch format {
+	case ImportXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		defer f.Close()
+
+		types := make(map[string]models.PropertyType, len(propertySheets))
+		for propertyType, name := range propertySheets {
+			types[strings.ToLower(name)] = propertyType
+		}
+
+		var sheets []importSheet
+		for _, name := range f.GetSheetList() {
+			rows, err := f.GetRows(name, excelize.Options{RawCellValue: true})
+			if err != nil {
+				return nil, fmt.Errorf("%w: sheet %s: %v", ErrInvalidImport, name, err)
+			}
+			sheets = append(sheets, importSheet{name: name, propertyType: types[strings.ToLower(name)], rows: rows})
+		}
+		return sheets, nil
+
+	case ImportCSV:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+			reader.Comma = ';'
+		}
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		return []importSheet{{rows: rows}}, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, format)
+}
+
+func parseImportInt(value string, allowNegative bool, target *int) error {
+	number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil || number != math.Trunc(number) || math.Abs(number) > math.MaxInt32 {
+		return fmt.Errorf("is not a whole number")
+	}
+	if number < 0 && !allowNegative {
+		return fmt.Errorf("must not be negative")
+	}
+	*target = int(number)
+	return nil
+}
+
+// parseImportFloat accepts a decimal comma as well as a point.
+func parseImportFloat(value string, target *float64) error {
+	if !strings.Contains(value, ".") {
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return fmt.Errorf("is not a number")
+	}
+	if number < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	*target = number
+	return nil
+}
+
+func parseImportBool(value string, target *bool) error {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "da", "да":
+		*target = true
+	case "false", "0", "no", "ne", "нет":
+		*target = false
+	default:
+		return fmt.Errorf("must be true or false")
+	}
+	return nil
+}
+
+// parseImportDate accepts YYYY-MM-DD, DD.MM.YYYY and Excel date serials.
+// The zero date of the export is the zero time.
+func parseImportDate(value string, target *time.Time) error {
+	for _, layout := range []string{"2006-01-02", "02.01.2006", "2.1.2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			*target = date
+			return nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		if date, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			*target = date.Truncate(24 * time.Hour)
+			return nil
+		}
+	}
+	return fmt.Errorf("is not a date (YYYY-MM-DD)")
+}
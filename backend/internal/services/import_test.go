@@ -0,0 +1,357 @@
+// backend/internal/services/import_test.go
+
+package services
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"kuckuc/internal/models"
+)
+
+func TestParseImportValues(t *testing.T) {
+	intTests := []struct {
+		value         string
+		allowNegative bool
+		want          int
+		wantErr       bool
+	}{
+		{"3", false, 3, false},
+		{"3.0", false, 3, false},
+		{"3,0", false, 3, false},
+		{"-1", true, -1, false},
+		{"-1", false, 0, true},
+		{"2.5", false, 0, true},
+		{"three", false, 0, true},
+		{"1e12", false, 0, true},
+	}
+	for _, tt := range intTests {
+		var got int
+		err := parseImportInt(tt.value, tt.allowNegative, &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseImportInt(%q, %v) = %d, %v", tt.value, tt.allowNegative, got, err)
+		}
+	}
+
+	floatTests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"75.5", 75.5, false},
+		{"75,5", 75.5, false},
+		{"150000", 150000, false},
+		{"NaN", 0, true},
+		{"-2", 0, true},
+		{"1,000.5", 0, true},
+	}
+	for _, tt := range floatTests {
+		var got float64
+		err := parseImportFloat(tt.value, &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseImportFloat(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+
+	boolTests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"TRUE", true, false},
+		{"da", true, false},
+		{"да", true, false},
+		{"0", false, false},
+		{"no", false, false},
+		{"maybe", false, true},
+	}
+	for _, tt := range boolTests {
+		var got bool
+		err := parseImportBool(tt.value, &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseImportBool(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+
+	march := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+	dateTests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2023-03-15", march, false},
+		{"15.03.2023", march, false},
+		{"15.3.2023", march, false},
+		{"45000", march, false},
+		{"0001-01-01", time.Time{}, false},
+		{"03/15/2023", time.Time{}, true},
+	}
+	for _, tt := range dateTests {
+		var got time.Time
+		err := parseImportDate(tt.value, &got)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseImportDate(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestReadImportFileCSV(t *testing.T) {
+	want := [][]string{{"Property Code", "City"}, {"KU-1", "Beograd, Zemun"}}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"commas", "Property Code,City\nKU-1,\"Beograd, Zemun\"\n"},
+		{"semicolons", "Property Code;City\nKU-1;Beograd, Zemun\n"},
+		{"byte order mark", "\xef\xbb\xbfProperty Code;City\r\nKU-1;Beograd, Zemun\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheets, err := readImportFile(strings.NewReader(tt.data), ImportCSV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sheets) != 1 || !reflect.DeepEqual(sheets[0].rows, want) {
+				t.Errorf("readImportFile() = %q, want one sheet with %q", sheets, want)
+			}
+		})
+	}
+
+	if _, err := readImportFile(strings.NewReader("a,\"b\n"), ImportCSV); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("broken quote: error = %v, want ErrInvalidImport", err)
+	}
+	if _, err := readImportFile(strings.NewReader(""), "ods"); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("unknown format: error = %v, want ErrInvalidImport", err)
+	}
+}
+
+func TestParseImportSheets(t *testing.T) {
+	sheets := []importSheet{
+		{name: "Apartments", propertyType: models.Apartment, rows: [][]string{
+			{"property_code", "Language", "City", "Agent Code", "Balcony", "Price", "CITY"},
+			{"KU-1", "", "Beograd", "SALE1", "yes", "100000"},
+			{"KU-1", "EN", "Belgrade"},
+			{"", "", ""},
+			{"", "sr", "Novi Sad"},
+			{"KU-2", "de", "Berlin"},
+			{strings.Repeat("K", 51), "sr"},
+		}},
+		{name: "Notes", rows: [][]string{{"Note"}, {"call the owner"}}},
+	}
+	report := &ImportReport{}
+	rows := parseImportSheets(sheets, "sr", report)
+
+	if report.Rows != 5 {
+		t.Errorf("counted %d rows, want 5", report.Rows)
+	}
+	if want := []string{"Balcony", "Note"}; !reflect.DeepEqual(report.IgnoredColumns, want) {
+		t.Errorf("ignored columns = %q, want %q", report.IgnoredColumns, want)
+	}
+	wantErrors := []ImportError{
+		{Sheet: "Apartments", Row: 1, Column: "CITY", Message: "the column appears twice"},
+		{Sheet: "Apartments", Row: 5, Column: "Property Code", Message: "is required"},
+		{Sheet: "Apartments", Row: 6, Column: "Language", Message: `"de" must be one of sr, en, ru`},
+		{Sheet: "Apartments", Row: 7, Column: "Property Code", Message: "is longer than 50 characters"},
+		{Sheet: "Notes", Row: 1, Column: "Property Code", Message: "the column is missing"},
+	}
+	if !reflect.DeepEqual(report.Errors, wantErrors) {
+		t.Errorf("errors =\n%+v\nwant\n%+v", report.Errors, wantErrors)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	first, second := rows[0], rows[1]
+	if first.code != "KU-1" || first.row != 2 || first.language != "sr" || first.propertyType != models.Apartment {
+		t.Errorf("first row = %+v", first)
+	}
+	var headers, values []string
+	for _, cell := range first.cells {
+		headers = append(headers, cell.header)
+		values = append(values, cell.value)
+	}
+	if !reflect.DeepEqual(headers, []string{"City", "Price"}) || !reflect.DeepEqual(values, []string{"Beograd", "100000"}) {
+		t.Errorf("first row cells = %q: %q", headers, values)
+	}
+	if second.language != "en" || len(second.cells) != 1 || second.cells[0].value != "Belgrade" {
+		t.Errorf("second row = %+v", second)
+	}
+}
+
+func TestCheckImportGroup(t *testing.T) {
+	row := func(number int, language string, cells ...string) importRow {
+		r := importRow{sheet: "Houses", row: number, code: "KU-1", language: language, propertyType: models.House}
+		for i := 0; i < len(cells); i += 2 {
+			column := importColumnsByKey[importColumnKey(cells[i])]
+			r.cells = append(r.cells, importCell{header: cells[i], column: column, value: cells[i+1]})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name  string
+		group []importRow
+		want  []ImportError
+	}{
+		{"one row per language", []importRow{
+			row(2, "sr", "Deal Type", "sale", "City", "Beograd"),
+			row(3, "en", "Deal Type", "SALE", "City", "Belgrade"),
+		}, nil},
+		{"duplicate language", []importRow{
+			row(2, "sr", "City", "Beograd"),
+			row(3, "sr", "City", "Novi Sad"),
+		}, []ImportError{{Sheet: "Houses", Row: 3, Column: "Language", Message: "KU-1 has details in sr in row 2 already"}}},
+		{"conflicting values", []importRow{
+			row(2, "sr", "Deal Type", "sale", "Contract Number", "C-1"),
+			row(3, "en", "Deal Type", "rent", "Contract Number", "C-1"),
+		}, []ImportError{{Sheet: "Houses", Row: 3, Column: "Deal Type", Message: `"rent" differs from "sale" in row 2 of KU-1`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &ImportReport{}
+			ok := checkImportGroup(tt.group, report)
+			if ok != (tt.want == nil) || !reflect.DeepEqual(report.Errors, tt.want) {
+				t.Errorf("checkImportGroup() = %v, errors %+v, want %+v", ok, report.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportPropertiesValidation(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewPropertyService(db, nil)
+	agent := Actor{UserID: 1, Role: RoleAgent}
+	file := "Property Code,City\nKU-1,Beograd\n"
+
+	if _, err := s.ImportProperties(strings.NewReader(file), ImportOptions{Format: ImportCSV, Language: "sr"},
+		Actor{UserID: 1, Role: RoleViewer}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer: error = %v, want ErrForbidden", err)
+	}
+	if _, err := s.ImportProperties(strings.NewReader(file), ImportOptions{Format: ImportCSV, Language: "de"},
+		agent); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("unknown language: error = %v, want ErrInvalidImport", err)
+	}
+	tooMany := "Property Code\n" + strings.Repeat("KU-1\n", MaxImportRows+1)
+	if _, err := s.ImportProperties(strings.NewReader(tooMany), ImportOptions{Format: ImportCSV, Language: "sr"},
+		agent); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("too many rows: error = %v, want ErrInvalidImport", err)
+	}
+	if len(*statements) > 0 {
+		t.Errorf("invalid imports reached the database: %q", *statements)
+	}
+}
+
+func TestImportExportedRows(t *testing.T) {
+	contractEnd := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		owner models.PropertyOwner
+	}{
+		{"without owner", models.PropertyOwner{}},
+		{"with owner", models.PropertyOwner{ID: 4, PropertyID: 1, PropertiesCount: 2, ContractStatus: "active",
+			ContractNumber: "C-17", ContractEndDate: contractEnd}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exported := models.Property{
+				ID: 1, AgentCode: "SALE1", PropertyCode: "KU-1", PropertyType: models.House,
+				DealType: models.Sale, Status: models.Ready, LifecycleState: models.StatePublished,
+				Details: []models.PropertyDetails{{
+					PropertyID: 1, Language: "sr", City: "Beograd", Address: "Knez Mihailova",
+					FloorNumber: -1, TotalFloors: 4, LivingArea: 75.5, Rooms: 3, Price: 150000, Registered: true,
+				}},
+				Owner: tt.owner,
+			}
+			data, err := (&ExportService{}).createExcelFile([]models.Property{exported}, "sr")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sheets, err := readImportFile(bytes.NewReader(data), ImportXLSX)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := &ImportReport{}
+			rows := parseImportSheets(sheets, "sr", report)
+			if len(rows) != 1 || len(report.Errors) > 0 || len(report.IgnoredColumns) > 0 {
+				t.Fatalf("rows %+v, report %+v", rows, report)
+			}
+
+			// Applied to the exported property, the row changes nothing
+			imported := exported
+			imported.Details = append([]models.PropertyDetails(nil), exported.Details...)
+			owner := tt.owner
+			hasOwner, ok := applyImportRows(rows, &imported, &owner, func(row importRow, column, message string) {
+				t.Errorf("row %d, %s: %s", row.row, column, message)
+			})
+			if !ok {
+				t.FailNow()
+			}
+			if hasOwner != (tt.owner.ID != 0) {
+				t.Errorf("hasOwner = %v", hasOwner)
+			}
+			before, err := propertyDocument(&exported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := propertyDocument(&imported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := diffDocuments(before, after)
+			ownerChanges, err := diffOwners(tt.owner, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) > 0 || len(ownerChanges) > 0 {
+				t.Errorf("changes %+v, owner changes %+v", changes, ownerChanges)
+			}
+		})
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+	exports := NewExportService(s, nil)
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	property := &models.Property{
+		PropertyType: models.Apartment,
+		DealType:     models.Sale,
+		Status:       models.Ready,
+		Details: []models.PropertyDetails{{
+			Language: "sr", City: "Beograd", District: "Zemun", LivingArea: 75.5, Rooms: 3, Price: 150000,
+		}},
+	}
+	if err := s.CreateProperty(property, agent); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListPropertiesByIDs([]uint{property.ID}, "sr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := exports.createExcelFile(list, "sr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.ImportProperties(bytes.NewReader(data), ImportOptions{Format: ImportXLSX, Language: "sr"}, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) > 0 || report.Unchanged != 1 || report.Updated != 0 || report.Created != 0 {
+		t.Errorf("re-importing an unmodified export: %+v", report)
+	}
+
+	var owners int64
+	db.Model(&models.PropertyOwner{}).Where("property_id = ?", property.ID).Count(&owners)
+	if owners != 0 {
+		t.Errorf("import added %d owners", owners)
+	}
+}
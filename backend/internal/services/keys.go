@@ -0,0 +1,297 @@
+// backend/internal/services/keys.go
+
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("no active JWT signing key")
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// keyDateLayout is the optional activation date at the start of a key file
+// name, e.g. 2026-11-01.pem or 2026-11-01-ed25519.pem.
+const keyDateLayout = "2006-01-02"
+
+// SigningKey is one private key of the KeySet. Its kid is the file name
+// without the .pem extension.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	ActivatesAt time.Time
+	private     crypto.Signer
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeySet holds the JWT signing keys loaded from a directory of PEM files.
+//
+// Rotation is scheduled by file name: a key named after a date becomes the
+// signing key on that day (UTC), and a key without a date is active from
+// the start. The newest active key signs; the key before it keeps verifying
+// tokens for retireGrace after being replaced, so tokens signed just before
+// a rotation stay valid. Keys are published in the JWKS as soon as they are
+// in the directory, so verifiers can fetch them before they sign anything.
+type KeySet struct {
+	dir string
+	// retireGrace must cover the longest token lifetime
+	retireGrace time.Duration
+
+	mu   sync.RWMutex
+	keys []*SigningKey // sorted by ActivatesAt
+}
+
+// LoadKeySet loads the keys in dir. It fails if dir is not set or there is
+// no key that can sign now, so the API never runs without key material.
+func LoadKeySet(dir string) (*KeySet, error) {
+	if dir == "" {
+		return nil, errors.New("JWT_KEYS_DIR must be set")
+	}
+	ks := &KeySet{dir: dir, retireGrace: AccessTokenTTL}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	if _, err := ks.SigningKey(time.Now()); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, dir)
+	}
+	return ks, nil
+}
+
+// Reload reads the key directory again. The current keys are kept if it
+// fails.
+func (ks *KeySet) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the keys every interval until ctx is done, so new keys can
+// be added without a restart.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				log.Printf("Failed to reload JWT keys: %v", err)
+			}
+		}
+	}
+}
+
+// SigningKey returns the newest key that is active at now.
+func (ks *KeySet) SigningKey(now time.Time) (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		if !ks.keys[i].ActivatesAt.After(now) {
+			return ks.keys[i], nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// publishedKeys returns the keys that may verify tokens at now: all keys
+// except those replaced more than retireGrace ago.
+func (ks *KeySet) publishedKeys(now time.Time) []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var published []*SigningKey
+	for i, key := range ks.keys {
+		if i+1 < len(ks.keys) {
+			// Keys activating at the same moment never retire each other
+			next := ks.keys[i+1].ActivatesAt
+			if next.After(key.ActivatesAt) && now.After(next.Add(ks.retireGrace)) {
+				continue
+			}
+		}
+		published = append(published, key)
+	}
+	return published
+}
+
+// verificationKey looks up the public key for a token's kid header.
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range ks.publishedKeys(time.Now()) {
+		if key.ID != kid {
+			continue
+		}
+		if key.signingMethod().Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.private.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// sign signs claims with the current signing key.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	key, err := ks.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verifiers should accept.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.publishedKeys(time.Now()) {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch pub := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func loadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+	key := &SigningKey{ID: kid}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must have at least 2048 bits")
+		}
+		key.Algorithm, key.private = AlgRS256, private
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private = AlgEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	if len(kid) >= len(keyDateLayout) {
+		if date, err := time.Parse(keyDateLayout, kid[:len(keyDateLayout)]); err == nil {
+			key.ActivatesAt = date
+		}
+	}
+	return key, nil
+}
+
+// GenerateSigningKey returns a new PKCS #8 PEM private key for AlgRS256 or
+// AlgEdDSA.
+func GenerateSigningKey(algorithm string) ([]byte, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
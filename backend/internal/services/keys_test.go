@@ -0,0 +1,191 @@
+// backend/internal/services/keys_test.go
+
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeySet returns a key set with one Ed25519 key active from the start.
+func testKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestKey(t, dir, "default", AlgEdDSA)
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func writeTestKey(t *testing.T, dir, kid, algorithm string) {
+	t.Helper()
+	key, err := GenerateSigningKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), key, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func day(date string) time.Time {
+	t, err := time.Parse(keyDateLayout, date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func keyIDs(keys []*SigningKey) string {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "initial", AlgEdDSA)
+	writeTestKey(t, dir, "2026-01-01", AlgEdDSA)
+	writeTestKey(t, dir, "2026-06-01-ed25519", AlgEdDSA)
+	keys := &KeySet{dir: dir, retireGrace: time.Hour}
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		wantSigning   string
+		wantPublished string
+	}{
+		{"before any rotation", day("2025-12-31"), "initial", "initial,2026-01-01,2026-06-01-ed25519"},
+		{"on rotation day", day("2026-01-01"), "2026-01-01", "initial,2026-01-01,2026-06-01-ed25519"},
+		{"within grace", day("2026-01-01").Add(59 * time.Minute), "2026-01-01", "initial,2026-01-01,2026-06-01-ed25519"},
+		{"after grace", day("2026-01-01").Add(61 * time.Minute), "2026-01-01", "2026-01-01,2026-06-01-ed25519"},
+		{"second rotation", day("2026-07-01"), "2026-06-01-ed25519", "2026-06-01-ed25519"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := keys.SigningKey(tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.ID != tt.wantSigning {
+				t.Errorf("signing key = %s, want %s", key.ID, tt.wantSigning)
+			}
+			if got := keyIDs(keys.publishedKeys(tt.now)); got != tt.wantPublished {
+				t.Errorf("published = %s, want %s", got, tt.wantPublished)
+			}
+		})
+	}
+}
+
+func TestKeySetSameActivation(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01-01-a", AlgEdDSA)
+	writeTestKey(t, dir, "2026-01-01-b", AlgEdDSA)
+	keys := &KeySet{dir: dir, retireGrace: time.Hour}
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := day("2027-01-01")
+	if key, err := keys.SigningKey(now); err != nil || key.ID != "2026-01-01-b" {
+		t.Errorf("signing key = %v, %v, want 2026-01-01-b", key, err)
+	}
+	// Keys activating together never retire each other
+	if got := keyIDs(keys.publishedKeys(now)); got != "2026-01-01-a,2026-01-01-b" {
+		t.Errorf("published = %s", got)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	if _, err := LoadKeySet(""); err == nil {
+		t.Error("expected an error without a directory")
+	}
+
+	empty := t.TempDir()
+	if _, err := LoadKeySet(empty); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("empty directory: %v, want %v", err, ErrNoSigningKey)
+	}
+
+	// A key that only activates later cannot sign yet
+	future := t.TempDir()
+	writeTestKey(t, future, time.Now().AddDate(0, 0, 7).Format(keyDateLayout), AlgEdDSA)
+	if _, err := LoadKeySet(future); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("future key only: %v, want %v", err, ErrNoSigningKey)
+	}
+
+	invalid := t.TempDir()
+	if err := os.WriteFile(filepath.Join(invalid, "broken.pem"), []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeySet(invalid); err == nil {
+		t.Error("expected an error for a broken key file")
+	}
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestKey(t, dir, "current", algorithm)
+			keys, err := LoadKeySet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := keys.sign(jwt.RegisteredClaims{Subject: "1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := jwt.Parse(token, keys.verificationKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != "current" || parsed.Method.Alg() != algorithm {
+				t.Errorf("header = %v", parsed.Header)
+			}
+
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "current" || jwks.Keys[0].Algorithm != algorithm {
+				t.Errorf("JWKS = %+v", jwks)
+			}
+
+			// Once the key file is gone the token no longer verifies
+			if err := os.Remove(filepath.Join(dir, "current.pem")); err != nil {
+				t.Fatal(err)
+			}
+			if err := keys.Reload(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := jwt.Parse(token, keys.verificationKey); err == nil {
+				t.Error("token verified with a removed key")
+			}
+		})
+	}
+}
+
+func TestKeySetRejectsAlgorithmSwitch(t *testing.T) {
+	keys := testKeySet(t)
+	// An HS256 token naming the Ed25519 key must not be accepted
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "1"})
+	token.Header["kid"] = "default"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, keys.verificationKey); err == nil {
+		t.Error("token with another algorithm accepted")
+	}
+}
@@ -0,0 +1,169 @@
+// backend/internal/services/lifecycle.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrInvalidLifecycleState = errors.New("invalid lifecycle state")
+	// ErrInvalidTransition is wrapped with the reason
+	ErrInvalidTransition        = errors.New("transition not allowed")
+	ErrTransitionReasonRequired = errors.New("a reason is required for this transition")
+)
+
+// MaxTransitionReason is the longest reason kept in the history, in runes
+const MaxTransitionReason = 500
+
+// transitionRule describes one allowed move between lifecycle states.
+type transitionRule struct {
+	// permission is needed in addition to edit rights on the property
+	permission Permission
+	// reasonRequired is set for moves that undo an earlier step
+	reasonRequired bool
+}
+
+// lifecycleTransitions lists the allowed moves by current and next state.
+// Agents run their listings through the whole workflow; taking back a
+// closed deal or reopening an archived listing is left to senior agents.
+var lifecycleTransitions = map[models.LifecycleState]map[models.LifecycleState]transitionRule{
+	models.StateDraft: {
+		models.StatePublished: {},
+		models.StateArchived:  {},
+	},
+	models.StatePublished: {
+		models.StateDraft:    {},
+		models.StateReserved: {},
+		models.StateSold:     {},
+		models.StateRented:   {},
+		models.StateArchived: {},
+	},
+	models.StateReserved: {
+		models.StatePublished: {reasonRequired: true},
+		models.StateSold:      {},
+		models.StateRented:    {},
+		models.StateArchived:  {},
+	},
+	models.StateSold: {
+		models.StatePublished: {permission: PermPropertiesEditAny, reasonRequired: true},
+		models.StateArchived:  {},
+	},
+	models.StateRented: {
+		models.StatePublished: {permission: PermPropertiesEditAny, reasonRequired: true},
+		models.StateArchived:  {},
+	},
+	models.StateArchived: {
+		models.StateDraft:     {permission: PermPropertiesEditAny, reasonRequired: true},
+		models.StatePublished: {permission: PermPropertiesEditAny, reasonRequired: true},
+	},
+}
+
+// ParseLifecycleStates parses a comma-separated list of states, as used by
+// the lifecycle_state filter.
+func ParseLifecycleStates(value string) ([]models.LifecycleState, error) {
+	var states []models.LifecycleState
+	for _, part := range strings.Split(value, ",") {
+		state := models.LifecycleState(strings.TrimSpace(part))
+		if !models.ValidLifecycleState(state) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLifecycleState, part)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// TransitionProperty moves a property to the lifecycle state to and records
+// the move in its history. The move must be listed in lifecycleTransitions
+// and actor must be allowed to edit the property. Properties are only sold
+// if they are for sale and only rented if they are for rent.
+func (s *PropertyService) TransitionProperty(id uint, to models.LifecycleState, reason string, actor Actor) (*models.Property, error) {
+	if !models.ValidLifecycleState(to) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidLifecycleState, to)
+	}
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > MaxTransitionReason {
+		return nil, fmt.Errorf("%w: the reason is longer than %d characters", ErrInvalidTransition, MaxTransitionReason)
+	}
+
+	var property models.Property
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.authorizeEdit(tx, actor, id)
+		if err != nil {
+			return err
+		}
+
+		from := existing.LifecycleState
+		rule, ok := lifecycleTransitions[from][to]
+		if !ok {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+		}
+		if (to == models.StateSold && existing.DealType != models.Sale) ||
+			(to == models.StateRented && existing.DealType != models.Rent) {
+			return fmt.Errorf("%w: a property for %s cannot be %s", ErrInvalidTransition, existing.DealType, to)
+		}
+		if rule.permission != "" && !actor.Can(rule.permission) {
+			return ErrForbidden
+		}
+		if rule.reasonRequired && reason == "" {
+			return ErrTransitionReasonRequired
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"lifecycle_state":  to,
+			"is_active":        to == models.StatePublished || to == models.StateReserved,
+			"state_changed_at": now,
+			"version":          gorm.Expr("version + 1"),
+		}
+		switch to {
+		case models.StatePublished:
+			updates["published_at"] = now
+		case models.StateReserved:
+			updates["reserved_at"] = now
+		case models.StateSold, models.StateRented:
+			updates["closed_at"] = now
+		case models.StateArchived:
+			updates["archived_at"] = now
+		}
+
+		// The state is checked again in the update, so two concurrent
+		// transitions from the same state cannot both succeed
+		result := tx.Model(&models.Property{}).
+			Where("id = ? AND lifecycle_state = ?", id, from).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: the property is no longer %s", ErrInvalidTransition, from)
+		}
+
+		changes := []FieldChange{{Field: "lifecycle_state", Before: from, After: to}}
+		if isActive := updates["is_active"].(bool); isActive != existing.IsActive {
+			changes = append(changes, FieldChange{Field: "is_active", Before: existing.IsActive, After: isActive})
+		}
+		if err := recordHistory(tx, id, HistoryStateChange, actor, historyDetails{
+			Action:  "state_changed",
+			From:    from,
+			To:      to,
+			Reason:  reason,
+			Changes: changes,
+		}); err != nil {
+			return err
+		}
+
+		return tx.Preload("Details").First(&property, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &property, nil
+}
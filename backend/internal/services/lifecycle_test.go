@@ -0,0 +1,165 @@
+// backend/internal/services/lifecycle_test.go
+
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"kuckuc/internal/models"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	type rule struct {
+		seniorOnly     bool
+		reasonRequired bool
+	}
+	allowed := map[[2]models.LifecycleState]rule{
+		{models.StateDraft, models.StatePublished}:    {},
+		{models.StateDraft, models.StateArchived}:     {},
+		{models.StatePublished, models.StateDraft}:    {},
+		{models.StatePublished, models.StateReserved}: {},
+		{models.StatePublished, models.StateSold}:     {},
+		{models.StatePublished, models.StateRented}:   {},
+		{models.StatePublished, models.StateArchived}: {},
+		{models.StateReserved, models.StatePublished}: {reasonRequired: true},
+		{models.StateReserved, models.StateSold}:      {},
+		{models.StateReserved, models.StateRented}:    {},
+		{models.StateReserved, models.StateArchived}:  {},
+		{models.StateSold, models.StatePublished}:     {seniorOnly: true, reasonRequired: true},
+		{models.StateSold, models.StateArchived}:      {},
+		{models.StateRented, models.StatePublished}:   {seniorOnly: true, reasonRequired: true},
+		{models.StateRented, models.StateArchived}:    {},
+		{models.StateArchived, models.StateDraft}:     {seniorOnly: true, reasonRequired: true},
+		{models.StateArchived, models.StatePublished}: {seniorOnly: true, reasonRequired: true},
+	}
+	agent := Actor{UserID: 1, Role: RoleAgent}
+	senior := Actor{UserID: 2, Role: RoleSeniorAgent}
+
+	for _, from := range models.LifecycleStates {
+		for _, to := range models.LifecycleStates {
+			want, ok := allowed[[2]models.LifecycleState{from, to}]
+			got, gotOK := lifecycleTransitions[from][to]
+			if gotOK != ok {
+				t.Errorf("%s to %s allowed = %v, want %v", from, to, gotOK, ok)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if got.reasonRequired != want.reasonRequired {
+				t.Errorf("%s to %s needs a reason = %v, want %v", from, to, got.reasonRequired, want.reasonRequired)
+			}
+			agentCan := got.permission == "" || agent.Can(got.permission)
+			seniorCan := got.permission == "" || senior.Can(got.permission)
+			if agentCan == want.seniorOnly || !seniorCan {
+				t.Errorf("%s to %s: agent allowed %v, senior agent allowed %v, want senior only %v",
+					from, to, agentCan, seniorCan, want.seniorOnly)
+			}
+		}
+	}
+	for from := range lifecycleTransitions {
+		if !models.ValidLifecycleState(from) {
+			t.Errorf("transitions from unknown state %q", from)
+		}
+	}
+}
+
+func TestParseLifecycleStates(t *testing.T) {
+	states, err := ParseLifecycleStates("published, reserved,sold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.LifecycleState{models.StatePublished, models.StateReserved, models.StateSold}
+	if len(states) != len(want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("states = %v, want %v", states, want)
+		}
+	}
+
+	for _, value := range []string{"", "published,", "Published", "published,deleted"} {
+		if _, err := ParseLifecycleStates(value); !errors.Is(err, ErrInvalidLifecycleState) {
+			t.Errorf("ParseLifecycleStates(%q) error = %v, want ErrInvalidLifecycleState", value, err)
+		}
+	}
+}
+
+func TestTransitionPropertyValidation(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewPropertyService(db, NewGeocodingService(db))
+	agent := Actor{UserID: 1, Role: RoleAgent}
+
+	if _, err := s.TransitionProperty(1, "deleted", "", agent); !errors.Is(err, ErrInvalidLifecycleState) {
+		t.Errorf("unknown state: error = %v, want ErrInvalidLifecycleState", err)
+	}
+	long := strings.Repeat("ш", MaxTransitionReason+1)
+	if _, err := s.TransitionProperty(1, models.StatePublished, long, agent); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("long reason: error = %v, want ErrInvalidTransition", err)
+	}
+	if len(*statements) > 0 {
+		t.Errorf("invalid transitions reached the database: %q", *statements)
+	}
+}
+
+func TestTransitionProperty(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	agentUser := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	otherUser := createTestUser(t, auth, "other@kuckuc.rs", RoleAgent)
+	seniorUser := createTestUser(t, auth, "senior@kuckuc.rs", RoleSeniorAgent)
+	agent := Actor{UserID: agentUser.ID, Role: RoleAgent}
+	other := Actor{UserID: otherUser.ID, Role: RoleAgent}
+	senior := Actor{UserID: seniorUser.ID, Role: RoleSeniorAgent}
+
+	property := createTestProperty(t, db, models.Sale, agentUser.ID)
+
+	steps := []struct {
+		to      models.LifecycleState
+		reason  string
+		actor   Actor
+		wantErr error
+	}{
+		{models.StateSold, "", agent, ErrInvalidTransition},
+		{models.StatePublished, "", other, ErrForbidden},
+		{models.StatePublished, "", agent, nil},
+		{models.StateRented, "", agent, ErrInvalidTransition},
+		{models.StateReserved, "", agent, nil},
+		{models.StatePublished, "", agent, ErrTransitionReasonRequired},
+		{models.StatePublished, "buyer withdrew", agent, nil},
+		{models.StateSold, "", agent, nil},
+		{models.StatePublished, "contract annulled", agent, ErrForbidden},
+		{models.StatePublished, "contract annulled", senior, nil},
+	}
+	for i, step := range steps {
+		updated, err := s.TransitionProperty(property.ID, step.to, step.reason, step.actor)
+		if step.wantErr != nil {
+			if !errors.Is(err, step.wantErr) {
+				t.Fatalf("step %d to %s: error = %v, want %v", i, step.to, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("step %d to %s: %v", i, step.to, err)
+		}
+		active := step.to == models.StatePublished || step.to == models.StateReserved
+		if updated.LifecycleState != step.to || updated.IsActive != active || updated.StateChangedAt == nil {
+			t.Fatalf("step %d: property = %+v", i, updated)
+		}
+	}
+
+	var moves int64
+	if err := db.Model(&models.History{}).
+		Where("property_id = ? AND action_type = ?", property.ID, "state_change").
+		Count(&moves).Error; err != nil {
+		t.Fatal(err)
+	}
+	if moves != 5 {
+		t.Errorf("%d state changes in the history, want 5", moves)
+	}
+}
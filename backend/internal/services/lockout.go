@@ -0,0 +1,366 @@
+// backend/internal/services/lockout.go
+
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+// LoginThrottledError is returned by Login while an account or IP address is
+// backing off or locked out.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginPolicy describes how failures of one key are throttled. The first
+// FreeAttempts failures are not delayed, each further failure doubles the
+// delay from BaseDelay up to MaxDelay, and LockoutAfter failures lock the
+// key for LockoutDuration. Counters reset after Window without failures.
+type LoginPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+var (
+	AccountLoginPolicy = LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 30 * time.Minute,
+		Window:          time.Hour,
+	}
+	// IPLoginPolicy is looser, since offices share one address.
+	IPLoginPolicy = LoginPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+)
+
+// delay returns how long the key is blocked after its n-th failure.
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// AttemptStore keeps the failure counters. The memory store is enough for a
+// single API instance; replicas must share the postgres store.
+type AttemptStore interface {
+	// Get returns nil if the key has no failures.
+	Get(key string) (*models.LoginThrottle, error)
+	// Update applies fn to the counters of key atomically, creating them
+	// if needed, and returns the result.
+	Update(key string, fn func(*models.LoginThrottle)) (*models.LoginThrottle, error)
+	Delete(key string) error
+	List() ([]models.LoginThrottle, error)
+	// Prune removes counters whose last failure is before t and that are
+	// not locked.
+	Prune(t time.Time) error
+}
+
+// NewAttemptStore returns the store for LOGIN_THROTTLE_STORE: memory
+// (default) or postgres.
+func NewAttemptStore(driver string, db *gorm.DB) (AttemptStore, error) {
+	switch driver {
+	case "", "memory":
+		return NewMemoryAttemptStore(), nil
+	case "postgres":
+		return NewPostgresAttemptStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown login throttle store %q", driver)
+	}
+}
+
+type MemoryAttemptStore struct {
+	mu        sync.Mutex
+	throttles map[string]models.LoginThrottle
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{throttles: make(map[string]models.LoginThrottle)}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (*models.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.throttles[key]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (s *MemoryAttemptStore) Update(key string, fn func(*models.LoginThrottle)) (*models.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.throttles[key]
+	if !ok {
+		t = models.LoginThrottle{Key: key}
+	}
+	fn(&t)
+	s.throttles[key] = t
+	return &t, nil
+}
+
+func (s *MemoryAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.throttles, key)
+	return nil
+}
+
+func (s *MemoryAttemptStore) List() ([]models.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]models.LoginThrottle, 0, len(s.throttles))
+	for _, t := range s.throttles {
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func (s *MemoryAttemptStore) Prune(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, t := range s.throttles {
+		if t.LastFailureAt.Before(before) && (t.LockedUntil == nil || t.LockedUntil.Before(now)) {
+			delete(s.throttles, key)
+		}
+	}
+	return nil
+}
+
+// PostgresAttemptStore keeps the counters in the login_throttles table.
+type PostgresAttemptStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresAttemptStore(db *gorm.DB) *PostgresAttemptStore {
+	return &PostgresAttemptStore{db: db}
+}
+
+func (s *PostgresAttemptStore) Get(key string) (*models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	if err := s.db.Where("key = ?", key).Limit(1).Find(&throttles).Error; err != nil {
+		return nil, err
+	}
+	if len(throttles) == 0 {
+		return nil, nil
+	}
+	return &throttles[0], nil
+}
+
+func (s *PostgresAttemptStore) Update(key string, fn func(*models.LoginThrottle)) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Create the row first so concurrent updates serialize on its lock
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, LastFailureAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&t).Error; err != nil {
+			return err
+		}
+		fn(&t)
+		return tx.Save(&t).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresAttemptStore) Delete(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+func (s *PostgresAttemptStore) List() ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	if err := s.db.Order("last_failure_at DESC").Find(&throttles).Error; err != nil {
+		return nil, err
+	}
+	return throttles, nil
+}
+
+func (s *PostgresAttemptStore) Prune(before time.Time) error {
+	return s.db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// Lockout is a throttled account or IP address as shown to admins.
+type Lockout struct {
+	models.LoginThrottle
+	Email     string `json:"email,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	// Locked is true for a full lockout rather than a backoff delay
+	Locked bool `json:"locked"`
+}
+
+// LoginLimiter throttles logins per account and per IP address.
+type LoginLimiter struct {
+	store     AttemptStore
+	account   LoginPolicy
+	ip        LoginPolicy
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewLoginLimiter(store AttemptStore) *LoginLimiter {
+	return &LoginLimiter{
+		store:   store,
+		account: AccountLoginPolicy,
+		ip:      IPLoginPolicy,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LoginThrottledError if the account or the IP address may
+// not try to log in yet. It must be called before the password is checked.
+func (l *LoginLimiter) Check(email, ip string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		t, err := l.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if t != nil && t.LockedUntil != nil && t.LockedUntil.After(now) {
+			if wait := t.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail counts a failed login for the account and the IP address.
+func (l *LoginLimiter) Fail(email, ip string) error {
+	now := time.Now()
+	l.prune(now)
+
+	for _, k := range []struct {
+		key    string
+		policy LoginPolicy
+	}{{accountKey(email), l.account}, {ipKey(ip), l.ip}} {
+		policy := k.policy
+		_, err := l.store.Update(k.key, func(t *models.LoginThrottle) {
+			if now.Sub(t.LastFailureAt) > policy.Window && (t.LockedUntil == nil || t.LockedUntil.Before(now)) {
+				t.Failures = 0
+			}
+			t.Failures++
+			t.LastFailureAt = now
+			if delay := policy.delay(t.Failures); delay > 0 {
+				until := now.Add(delay)
+				t.LockedUntil = &until
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed resets the account counters. The IP counters are kept, so one
+// valid account does not give an attacker more guesses on others.
+func (l *LoginLimiter) Succeed(email string) error {
+	return l.store.Delete(accountKey(email))
+}
+
+// Lockouts lists accounts and addresses with recent failures.
+func (l *LoginLimiter) Lockouts() ([]Lockout, error) {
+	throttles, err := l.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lockouts := make([]Lockout, 0, len(throttles))
+	for _, t := range throttles {
+		lockout := Lockout{LoginThrottle: t}
+		policy := l.ip
+		if email, ok := strings.CutPrefix(t.Key, "account:"); ok {
+			lockout.Email = email
+			policy = l.account
+		} else {
+			lockout.IPAddress = strings.TrimPrefix(t.Key, "ip:")
+		}
+		active := t.LockedUntil != nil && t.LockedUntil.After(now)
+		if !active && now.Sub(t.LastFailureAt) > policy.Window {
+			continue
+		}
+		lockout.Locked = active && t.Failures >= policy.LockoutAfter
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, nil
+}
+
+// ClearAccount removes the failures of an account, lifting its lockout.
+func (l *LoginLimiter) ClearAccount(email string) error {
+	return l.store.Delete(accountKey(email))
+}
+
+// ClearIP removes the failures of an IP address, lifting its lockout.
+func (l *LoginLimiter) ClearIP(ip string) error {
+	return l.store.Delete(ipKey(ip))
+}
+
+// prune drops stale counters at most once an hour.
+func (l *LoginLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastPrune) < time.Hour {
+		l.mu.Unlock()
+		return
+	}
+	l.lastPrune = now
+	l.mu.Unlock()
+
+	window := l.account.Window
+	if l.ip.Window > window {
+		window = l.ip.Window
+	}
+	l.store.Prune(now.Add(-window))
+}
@@ -0,0 +1,148 @@
+// backend/internal/services/lockout_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginPolicyDelay(t *testing.T) {
+	policy := LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 30 * time.Minute,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{9, 10 * time.Second},
+		{10, 30 * time.Minute},
+		{50, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+
+	// Doubling stops at MaxDelay, so a large count cannot overflow
+	unbounded := LoginPolicy{FreeAttempts: 0, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 1 << 30}
+	if got := unbounded.delay(1 << 20); got != time.Minute {
+		t.Errorf("delay of many failures = %s, want %s", got, time.Minute)
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	limiter := NewLoginLimiter(NewMemoryAttemptStore())
+	const email, ip = "Agent@Kuckuc.rs", "203.0.113.7"
+
+	for i := 0; i < AccountLoginPolicy.FreeAttempts; i++ {
+		if err := limiter.Check(email, ip); err != nil {
+			t.Fatalf("attempt %d throttled: %v", i+1, err)
+		}
+		if err := limiter.Fail(email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := limiter.Check(email, ip); err != nil {
+		t.Fatalf("free attempts throttled: %v", err)
+	}
+
+	if err := limiter.Fail(email, ip); err != nil {
+		t.Fatal(err)
+	}
+	var throttled *LoginThrottledError
+	if err := limiter.Check(" agent@kuckuc.rs", "198.51.100.1"); !errors.As(err, &throttled) {
+		t.Fatalf("account not throttled after %d failures: %v", AccountLoginPolicy.FreeAttempts+1, err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > AccountLoginPolicy.BaseDelay {
+		t.Errorf("RetryAfter = %s", throttled.RetryAfter)
+	}
+	if err := limiter.Check("other@kuckuc.rs", ip); err != nil {
+		t.Errorf("other account on the same address throttled: %v", err)
+	}
+
+	lockouts, err := limiter.Lockouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sawAccount, sawIP bool
+	for _, lockout := range lockouts {
+		sawAccount = sawAccount || lockout.Email == "agent@kuckuc.rs"
+		sawIP = sawIP || lockout.IPAddress == ip
+		if lockout.Locked {
+			t.Errorf("%s is locked out after a backoff", lockout.Key)
+		}
+	}
+	if !sawAccount || !sawIP {
+		t.Errorf("Lockouts() = %+v, want the account and the address", lockouts)
+	}
+
+	// A successful login resets the account but not the address
+	if err := limiter.Succeed(email); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Check(email, ip); err != nil {
+		t.Errorf("throttled after a successful login: %v", err)
+	}
+	ipThrottle, err := limiter.store.Get(ipKey(ip))
+	if err != nil || ipThrottle == nil || ipThrottle.Failures != AccountLoginPolicy.FreeAttempts+1 {
+		t.Errorf("address counter = %+v, %v", ipThrottle, err)
+	}
+	if err := limiter.ClearIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if ipThrottle, _ := limiter.store.Get(ipKey(ip)); ipThrottle != nil {
+		t.Errorf("address counter not cleared: %+v", ipThrottle)
+	}
+}
+
+func TestLoginLimiterLockout(t *testing.T) {
+	limiter := NewLoginLimiter(NewMemoryAttemptStore())
+	const email = "owner@kuckuc.rs"
+
+	for i := 0; i < AccountLoginPolicy.LockoutAfter; i++ {
+		if err := limiter.Fail(email, "203.0.113.8"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var throttled *LoginThrottledError
+	if err := limiter.Check(email, "198.51.100.2"); !errors.As(err, &throttled) {
+		t.Fatalf("account not locked out: %v", err)
+	}
+	if throttled.RetryAfter < AccountLoginPolicy.LockoutDuration-time.Minute {
+		t.Errorf("RetryAfter = %s, want about %s", throttled.RetryAfter, AccountLoginPolicy.LockoutDuration)
+	}
+
+	lockouts, err := limiter.Lockouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lockout := range lockouts {
+		if lockout.Email == email && !lockout.Locked {
+			t.Errorf("lockout of %s not reported as locked", email)
+		}
+	}
+
+	if err := limiter.ClearAccount(email); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Check(email, "198.51.100.2"); err != nil {
+		t.Errorf("still throttled after ClearAccount: %v", err)
+	}
+}
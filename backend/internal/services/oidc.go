@@ -0,0 +1,456 @@
+// backend/internal/services/oidc.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrOIDCDisabled     = errors.New("single sign-on is not configured")
+	ErrInvalidOIDCLogin = errors.New("invalid or expired single sign-on login")
+	ErrOIDCEmailMissing = errors.New("the identity provider did not share a verified email address")
+	ErrOIDCNoRole       = errors.New("your groups at the identity provider do not grant access")
+)
+
+const (
+	// oidcLoginTTL is how long the user has to log in at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcExchangeTTL is how long the frontend has to redeem the one-time code
+	oidcExchangeTTL = time.Minute
+)
+
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL. Single sign-on is off without it.
+	Issuer string
+	// DiscoveryURL overrides where the discovery document is fetched from,
+	// for providers the API reaches under another address than browsers.
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the API's callback, .../api/auth/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim names the claim listing the user's groups
+	GroupsClaim string
+	// RoleMapping maps provider groups to roles
+	RoleMapping map[string]string
+	// DefaultRole is given to new users none of whose groups is mapped. If
+	// empty, such users are refused.
+	DefaultRole string
+	// ProviderName is shown on the login button
+	ProviderName string
+}
+
+// OIDCConfigFromEnv reads the single sign-on configuration from the OIDC_*
+// variables. OIDC_ROLE_MAPPING is a list like "kuckuc-admins=admin,agents=agent".
+func OIDCConfigFromEnv() OIDCConfig {
+	cfg := OIDCConfig{
+		Issuer:       os.Getenv("OIDC_ISSUER_URL"),
+		DiscoveryURL: os.Getenv("OIDC_DISCOVERY_URL"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(envOr("OIDC_SCOPES", "openid email profile")),
+		GroupsClaim:  envOr("OIDC_GROUPS_CLAIM", "groups"),
+		RoleMapping:  make(map[string]string),
+		DefaultRole:  os.Getenv("OIDC_DEFAULT_ROLE"),
+		ProviderName: envOr("OIDC_PROVIDER_NAME", "SSO"),
+	}
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok {
+			cfg.RoleMapping[strings.TrimSpace(group)] = strings.TrimSpace(role)
+		}
+	}
+	return cfg
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// OIDCService logs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE.
+//
+// Users are matched by the provider's issuer and subject. On their first
+// login they are linked to the user with the same, verified email address,
+// or created with a role from their groups. Roles of linked users follow
+// their groups on every login; users without a mapped group keep their role.
+// Users with two-factor authentication enabled or required get the same
+// challenge as after a password login.
+type OIDCService struct {
+	db   *gorm.DB
+	auth *AuthService
+	cfg  OIDCConfig
+
+	// The provider is discovered on first use, so the API starts while the
+	// provider is unreachable
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCService(db *gorm.DB, auth *AuthService, cfg OIDCConfig) (*OIDCService, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL must be set")
+	}
+	for group, role := range cfg.RoleMapping {
+		if !ValidRole(role) {
+			return nil, fmt.Errorf("group %q is mapped to unknown role %q", group, role)
+		}
+	}
+	if cfg.DefaultRole != "" && !ValidRole(cfg.DefaultRole) {
+		return nil, fmt.Errorf("unknown default role %q", cfg.DefaultRole)
+	}
+	return &OIDCService{db: db, auth: auth, cfg: cfg}, nil
+}
+
+func (s *OIDCService) ProviderName() string {
+	return s.cfg.ProviderName
+}
+
+func (s *OIDCService) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	url := s.cfg.Issuer
+	if s.cfg.DiscoveryURL != "" {
+		ctx = oidc.InsecureIssuerURLContext(ctx, s.cfg.Issuer)
+		url = s.cfg.DiscoveryURL
+	}
+	provider, err := oidc.NewProvider(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	s.provider = provider
+	return provider, nil
+}
+
+func (s *OIDCService) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       s.cfg.Scopes,
+	}
+}
+
+// StartLogin returns the provider URL to send the browser to and the state
+// that the callback must bring back. The caller binds the state to the
+// browser, e.g. in a cookie.
+func (s *OIDCService) StartLogin(ctx context.Context) (authURL, state string, err error) {
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := time.Now()
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.OIDCLogin{}).Error; err != nil {
+		log.Printf("Failed to prune OIDC logins: %v", err)
+	}
+	err = s.db.Create(&models.OIDCLogin{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	}).Error
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL = s.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, state, nil
+}
+
+// oidcClaims are the ID token and userinfo claims the login needs.
+type oidcClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+}
+
+// oidcAccount is the provider account a login was completed for.
+type oidcAccount struct {
+	Issuer string
+	oidcClaims
+	Groups []string
+}
+
+// Callback completes the login at the provider. state must match
+// boundState, the state StartLogin gave this browser. It returns a one-time
+// code for Exchange.
+func (s *OIDCService) Callback(ctx context.Context, state, boundState, code string) (string, error) {
+	if state == "" || state != boundState {
+		return "", ErrInvalidOIDCLogin
+	}
+	var login models.OIDCLogin
+	err := s.db.Where("state_hash = ? AND code_hash IS NULL AND expires_at > ?", hashToken(state), time.Now()).
+		First(&login).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrInvalidOIDCLogin
+	}
+	if err != nil {
+		return "", err
+	}
+
+	account, err := s.redeemCode(ctx, code, &login)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.resolveUser(account)
+	if err != nil {
+		return "", err
+	}
+
+	exchangeCode, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	codeHash := hashToken(exchangeCode)
+	result := s.db.Model(&models.OIDCLogin{}).
+		Where("state_hash = ? AND code_hash IS NULL", login.StateHash).
+		Updates(map[string]interface{}{
+			"user_id":    user.ID,
+			"code_hash":  codeHash,
+			"expires_at": time.Now().Add(oidcExchangeTTL),
+		})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrInvalidOIDCLogin
+	}
+	return exchangeCode, nil
+}
+
+// redeemCode exchanges the authorization code and returns the verified
+// account it belongs to.
+func (s *OIDCService) redeemCode(ctx context.Context, code string, login *models.OIDCLogin) (*oidcAccount, error) {
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		return nil, ErrInvalidOIDCLogin
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("OIDC token response has no id_token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Printf("OIDC ID token rejected: %v", err)
+		return nil, ErrInvalidOIDCLogin
+	}
+	if idToken.Nonce != login.Nonce {
+		return nil, ErrInvalidOIDCLogin
+	}
+
+	var claims oidcClaims
+	var raw map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, err
+	}
+	// Some providers put the email or the groups in userinfo only
+	if _, ok := raw[s.cfg.GroupsClaim]; !ok || claims.Email == "" {
+		if info, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err != nil {
+			log.Printf("OIDC userinfo failed: %v", err)
+		} else if info.Subject == claims.Subject {
+			var extra map[string]interface{}
+			if err := info.Claims(&extra); err == nil {
+				for key, value := range extra {
+					if _, ok := raw[key]; !ok {
+						raw[key] = value
+					}
+				}
+			}
+			if claims.Email == "" {
+				claims.Email = info.Email
+				claims.EmailVerified = &info.EmailVerified
+			}
+		}
+	}
+
+	return &oidcAccount{
+		Issuer:     idToken.Issuer,
+		oidcClaims: claims,
+		Groups:     groupsClaim(raw[s.cfg.GroupsClaim]),
+	}, nil
+}
+
+// Exchange redeems the one-time code from Callback for a new session, or for
+// a two-factor challenge like Login.
+func (s *OIDCService) Exchange(code string, client ClientInfo) (*LoginResult, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var login models.OIDCLogin
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", hashToken(code)).
+			First(&login).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOIDCLogin
+		}
+		if err != nil {
+			return err
+		}
+		if login.UsedAt != nil || login.UserID == nil || time.Now().After(login.ExpiresAt) {
+			return ErrInvalidOIDCLogin
+		}
+		if err := tx.Model(&login).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&user, *login.UserID).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.DisabledAt != nil {
+			return ErrUserDisabled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.auth.completeLogin(&user, client)
+}
+
+// resolveUser finds, links or creates the user of a provider account and
+// applies the role of its groups.
+func (s *OIDCService) resolveUser(account *oidcAccount) (*models.User, error) {
+	email := strings.ToLower(strings.TrimSpace(account.Email))
+	verified := account.EmailVerified != nil && *account.EmailVerified
+	role := s.mapRole(account.Groups)
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", account.Issuer, account.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Linking by email is only safe if the provider vouches for it
+			if email == "" || !verified {
+				return ErrOIDCEmailMissing
+			}
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("LOWER(email) = ?", email).First(&user).Error
+			switch {
+			case err == nil:
+				log.Printf("Linking OIDC account %s to user %d", account.Subject, user.ID)
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if role == "" {
+					role = s.cfg.DefaultRole
+				}
+				if role == "" {
+					return ErrOIDCNoRole
+				}
+				// No password: the user can only log in through the provider
+				user = models.User{Email: email, Role: role, EmailVerifiedAt: &now}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				log.Printf("Created user %d (%s) from OIDC account %s", user.ID, role, account.Subject)
+			default:
+				return err
+			}
+			identity = models.UserIdentity{UserID: user.ID, Issuer: account.Issuer, Subject: account.Subject}
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+
+		default:
+			return err
+		}
+
+		if user.DisabledAt != nil {
+			return ErrUserDisabled
+		}
+		if role != "" && role != user.Role {
+			log.Printf("Changing role of user %d from %s to %s by OIDC groups", user.ID, user.Role, role)
+			user.Role = role
+			if err := tx.Model(&user).Update("role", role).Error; err != nil {
+				return err
+			}
+		}
+		if user.EmailVerifiedAt == nil && verified && email == strings.ToLower(user.Email) {
+			user.EmailVerifiedAt = &now
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&identity).Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// mapRole returns the role with the most permissions among the groups'
+// roles, or "" if no group is mapped.
+func (s *OIDCService) mapRole(groups []string) string {
+	best := ""
+	for _, group := range groups {
+		role, ok := s.cfg.RoleMapping[group]
+		if ok && (best == "" || len(rolePermissions[role]) > len(rolePermissions[best])) {
+			best = role
+		}
+	}
+	return best
+}
+
+// groupsClaim reads a groups claim given as a list or a single string.
+func groupsClaim(value interface{}) []string {
+	var groups []string
+	switch v := value.(type) {
+	case string:
+		groups = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case []interface{}:
+		for _, item := range v {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
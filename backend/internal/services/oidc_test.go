@@ -0,0 +1,258 @@
+// backend/internal/services/oidc_test.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/oidctest"
+)
+
+// testOIDCService returns a service for a mock provider running in httptest.
+func testOIDCService(t *testing.T) *OIDCService {
+	t.Helper()
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	provider, err := oidctest.New(server.URL, "", "kuckuc", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler = provider.Handler()
+
+	s, err := NewOIDCService(nil, nil, OIDCConfig{
+		Issuer:      server.URL,
+		ClientID:    "kuckuc",
+		RedirectURL: "http://api.test/api/auth/oidc/callback",
+		Scopes:      []string{oidc.ScopeOpenID, "email"},
+		GroupsClaim: "groups",
+		RoleMapping: map[string]string{"kuckuc-admins": RoleAdmin, "agents": RoleAgent},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// mockLogin starts a login like StartLogin and submits the mock provider's
+// login form. It returns the login and the authorization code.
+func mockLogin(t *testing.T, s *OIDCService, email, groups string) (*models.OIDCLogin, string) {
+	t.Helper()
+	provider, err := s.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := &models.OIDCLogin{Nonce: "test-nonce", CodeVerifier: oauth2.GenerateVerifier()}
+	authURL, err := url.Parse(s.oauth2Config(provider).AuthCodeURL("test-state",
+		oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.CodeVerifier)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := authURL.Query()
+	form.Set("action", "allow")
+	form.Set("email", email)
+	form.Set("groups", groups)
+	authURL.RawQuery = ""
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(authURL.String(), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("no redirect from the provider: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), s.cfg.RedirectURL) || location.Query().Get("state") != "test-state" {
+		t.Fatalf("redirected to %s", location)
+	}
+	return login, location.Query().Get("code")
+}
+
+func TestOIDCRedeemCode(t *testing.T) {
+	s := testOIDCService(t)
+	ctx := context.Background()
+
+	login, code := mockLogin(t, s, "Milica@Kuckuc.rs", "agents, kuckuc-admins")
+	account, err := s.redeemCode(ctx, code, login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Issuer != s.cfg.Issuer || account.Subject == "" || account.Email != "Milica@Kuckuc.rs" ||
+		account.EmailVerified == nil || !*account.EmailVerified {
+		t.Errorf("account = %+v", account)
+	}
+	if strings.Join(account.Groups, ",") != "agents,kuckuc-admins" {
+		t.Errorf("groups = %v", account.Groups)
+	}
+	if role := s.mapRole(account.Groups); role != RoleAdmin {
+		t.Errorf("role = %q, want %q", role, RoleAdmin)
+	}
+
+	// The same email is the same account at the provider
+	again, code := mockLogin(t, s, "milica@kuckuc.rs", "")
+	other, err := s.redeemCode(ctx, code, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Subject != account.Subject || len(other.Groups) != 0 {
+		t.Errorf("second login = %+v", other)
+	}
+
+	t.Run("code used twice", func(t *testing.T) {
+		login, code := mockLogin(t, s, "agent@kuckuc.rs", "agents")
+		if _, err := s.redeemCode(ctx, code, login); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.redeemCode(ctx, code, login); !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidOIDCLogin)
+		}
+	})
+	t.Run("wrong verifier", func(t *testing.T) {
+		login, code := mockLogin(t, s, "agent@kuckuc.rs", "agents")
+		login.CodeVerifier = oauth2.GenerateVerifier()
+		if _, err := s.redeemCode(ctx, code, login); !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidOIDCLogin)
+		}
+	})
+	t.Run("wrong nonce", func(t *testing.T) {
+		login, code := mockLogin(t, s, "agent@kuckuc.rs", "agents")
+		login.Nonce = "another-nonce"
+		if _, err := s.redeemCode(ctx, code, login); !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidOIDCLogin)
+		}
+	})
+	t.Run("other client", func(t *testing.T) {
+		login, code := mockLogin(t, s, "agent@kuckuc.rs", "agents")
+		cfg := s.cfg
+		cfg.ClientID = "someone-else"
+		other := &OIDCService{cfg: cfg, provider: s.provider}
+		if _, err := other.redeemCode(ctx, code, login); !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidOIDCLogin)
+		}
+	})
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	// Checked before the database is used
+	s := &OIDCService{}
+	for _, tt := range []struct{ state, bound string }{{"", ""}, {"a", ""}, {"a", "b"}} {
+		if _, err := s.Callback(context.Background(), tt.state, tt.bound, "code"); !errors.Is(err, ErrInvalidOIDCLogin) {
+			t.Errorf("Callback(%q, %q) = %v, want %v", tt.state, tt.bound, err, ErrInvalidOIDCLogin)
+		}
+	}
+}
+
+func TestOIDCMapRole(t *testing.T) {
+	s := &OIDCService{cfg: OIDCConfig{RoleMapping: map[string]string{
+		"kuckuc-admins": RoleAdmin,
+		"seniors":       RoleSeniorAgent,
+		"agents":        RoleAgent,
+		"readers":       RoleViewer,
+	}}}
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"unmapped"}, ""},
+		{[]string{"readers"}, RoleViewer},
+		{[]string{"readers", "agents"}, RoleAgent},
+		{[]string{"agents", "seniors", "unmapped"}, RoleSeniorAgent},
+		{[]string{"readers", "kuckuc-admins", "agents"}, RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := s.mapRole(tt.groups); got != tt.want {
+			t.Errorf("mapRole(%v) = %q, want %q", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestGroupsClaim(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{"agents", "agents"},
+		{"agents,readers", "agents|readers"},
+		{"agents readers", "agents|readers"},
+		{[]interface{}{"agents", 7, "readers"}, "agents|readers"},
+		{map[string]interface{}{"agents": true}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(groupsClaim(tt.value), "|"); got != tt.want {
+			t.Errorf("groupsClaim(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOIDCConfigFromEnv(t *testing.T) {
+	t.Setenv("OIDC_ISSUER_URL", "https://login.example.com")
+	t.Setenv("OIDC_ROLE_MAPPING", " kuckuc-admins = admin ,agents=agent,broken")
+	t.Setenv("OIDC_SCOPES", "")
+	cfg := OIDCConfigFromEnv()
+	if !cfg.Enabled() || cfg.GroupsClaim != "groups" || cfg.ProviderName != "SSO" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if strings.Join(cfg.Scopes, " ") != "openid email profile" {
+		t.Errorf("scopes = %v", cfg.Scopes)
+	}
+	if len(cfg.RoleMapping) != 2 || cfg.RoleMapping["kuckuc-admins"] != RoleAdmin || cfg.RoleMapping["agents"] != RoleAgent {
+		t.Errorf("role mapping = %v", cfg.RoleMapping)
+	}
+
+	cfg.ClientID, cfg.RedirectURL = "kuckuc", "http://api.test/api/auth/oidc/callback"
+	cfg.RoleMapping["owners"] = "owner"
+	if _, err := NewOIDCService(nil, nil, cfg); err == nil {
+		t.Error("expected an error for a group mapped to an unknown role")
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := &OIDCService{db: db, auth: auth}
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	if err := db.Model(user).Update("two_factor_required", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	codeHash := hashToken("test-code")
+	if err := db.Create(&models.OIDCLogin{
+		StateHash: hashToken("test-state"),
+		UserID:    &user.ID,
+		CodeHash:  &codeHash,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Single sign-on does not skip the second factor
+	result, err := s.Exchange("test-code", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TokenPair != nil || !result.EnrollmentRequired || result.ChallengeToken == "" {
+		t.Errorf("exchange = %+v, want an enrolment challenge", result)
+	}
+	if _, err := s.Exchange("test-code", ClientInfo{}); !errors.Is(err, ErrInvalidOIDCLogin) {
+		t.Errorf("code used twice: error = %v, want ErrInvalidOIDCLogin", err)
+	}
+}
@@ -0,0 +1,341 @@
+// backend/internal/services/patch.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+// ErrInvalidPatch is wrapped with the reason
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// PropertyLanguages are the languages property details are written in
+var PropertyLanguages = []string{"sr", "en", "ru"}
+
+// patchableFields are the property fields a merge patch may change. The
+// others are set by the server or have their own endpoints.
+var patchableFields = map[string]bool{
+	"property_type":   true,
+	"deal_type":       true,
+	"status":          true,
+	"latitude":        true,
+	"longitude":       true,
+	"location_source": true,
+	"details":         true,
+}
+
+// detailKeys are the PropertyDetails fields that are not part of the patch
+// document: details are keyed by language there.
+var detailKeys = map[string]bool{"id": true, "property_id": true, "language": true}
+
+// PatchProperty applies an RFC 7386 JSON merge patch to a property. The
+// patch document has the patchable fields of the property, and "details" as
+// an object keyed by language, so one language or one field of it can be
+// changed on its own:
+//
+//	{"deal_type": "rent", "details": {"en": {"price": 950}, "ru": null}}
+//
+// A null language removes the details in that language. The patch is
+// applied to the stored property, so it never overwrites concurrent edits
+// of other fields; version is only checked if it is not 0. The before and
+// after values of the changed fields are recorded in the history. A patch
+// that changes nothing saves nothing.
+func (s *PropertyService) PatchProperty(id uint, patch []byte, version int, actor Actor) (*models.Property, error) {
+	var patchObject map[string]interface{}
+	if err := json.Unmarshal(patch, &patchObject); err != nil || patchObject == nil {
+		return nil, fmt.Errorf("%w: the patch must be a JSON object", ErrInvalidPatch)
+	}
+	if err := checkPatch(patchObject); err != nil {
+		return nil, err
+	}
+
+	var property *models.Property
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.lockForEdit(tx, actor, id, version)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("property_id = ?", id).Find(&existing.Details).Error; err != nil {
+			return err
+		}
+
+		before, err := propertyDocument(existing)
+		if err != nil {
+			return err
+		}
+		after, err := propertyDocument(existing)
+		if err != nil {
+			return err
+		}
+		after = mergePatch(after, patchObject).(map[string]interface{})
+
+		if len(diffDocuments(before, after)) == 0 {
+			property = existing
+			return nil
+		}
+
+		property, err = propertyFromDocument(existing, after)
+		if err != nil {
+			return err
+		}
+		if err := validatePatchedProperty(property, patchObject); err != nil {
+			return err
+		}
+
+		if err := s.saveProperty(tx, property, existing); err != nil {
+			return err
+		}
+		removed := removedLanguages(before, after)
+		if len(removed) > 0 {
+			if err := tx.Where("property_id = ? AND language IN ?", id, removed).
+				Delete(&models.PropertyDetails{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// Compared with what was stored, so the history also shows what
+		// geocoding changed
+		saved, err := s.loadDocument(tx, property)
+		if err != nil {
+			return err
+		}
+		return recordHistory(tx, id, HistoryUpdate, actor, historyDetails{
+			Action:  "property_patched",
+			Changes: diffDocuments(before, saved),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return property, nil
+}
+
+// checkPatch rejects patches of fields that are not in the patch document.
+func checkPatch(patch map[string]interface{}) error {
+	for key, value := range patch {
+		if !patchableFields[key] {
+			return fmt.Errorf("%w: %s cannot be patched", ErrInvalidPatch, key)
+		}
+		if key != "details" {
+			continue
+		}
+		languages, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: details must be an object keyed by language", ErrInvalidPatch)
+		}
+		for language, detail := range languages {
+			if !validLanguage(language) {
+				return fmt.Errorf("%w: unknown language %q", ErrInvalidPatch, language)
+			}
+			if detail == nil {
+				continue
+			}
+			fields, ok := detail.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%w: details.%s must be an object", ErrInvalidPatch, language)
+			}
+			for field := range fields {
+				if detailKeys[field] || !hasJSONField(reflect.TypeOf(models.PropertyDetails{}), field) {
+					return fmt.Errorf("%w: details.%s.%s cannot be patched", ErrInvalidPatch, language, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mergePatch applies patch to target as described in RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// propertyDocument returns the patch document of property.
+func propertyDocument(property *models.Property) (map[string]interface{}, error) {
+	raw, err := json.Marshal(property)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	for key := range patchableFields {
+		if value, ok := all[key]; ok && value != nil && key != "details" {
+			document[key] = value
+		}
+	}
+
+	details := map[string]interface{}{}
+	for _, detail := range property.Details {
+		raw, err := json.Marshal(detail)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		for key := range detailKeys {
+			delete(fields, key)
+		}
+		details[detail.Language] = fields
+	}
+	document["details"] = details
+	return document, nil
+}
+
+// propertyFromDocument returns a copy of existing with the fields of the
+// patch document. Fields removed from the document are zeroed.
+func propertyFromDocument(existing *models.Property, document map[string]interface{}) (*models.Property, error) {
+	property := *existing
+	property.PropertyType, property.DealType, property.Status = "", "", ""
+	property.Latitude, property.Longitude, property.LocationSource = nil, nil, ""
+	property.Details = nil
+
+	fields := map[string]interface{}{}
+	for key, value := range document {
+		if key != "details" {
+			fields[key] = value
+		}
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &property); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	languages, _ := document["details"].(map[string]interface{})
+	names := make([]string, 0, len(languages))
+	for language := range languages {
+		names = append(names, language)
+	}
+	sort.Strings(names)
+	for _, language := range names {
+		raw, err := json.Marshal(languages[language])
+		if err != nil {
+			return nil, err
+		}
+		var detail models.PropertyDetails
+		if err := json.Unmarshal(raw, &detail); err != nil {
+			return nil, fmt.Errorf("%w: details.%s: %v", ErrInvalidPatch, language, err)
+		}
+		detail.Language = language
+		for _, stored := range existing.Details {
+			if stored.Language == language {
+				detail.ID = stored.ID
+			}
+		}
+		detail.PropertyID = existing.ID
+		property.Details = append(property.Details, detail)
+	}
+	return &property, nil
+}
+
+// validatePatchedProperty checks the fields the patch set.
+func validatePatchedProperty(property *models.Property, patch map[string]interface{}) error {
+	if _, ok := patch["property_type"]; ok {
+		switch property.PropertyType {
+		case models.House, models.Apartment, models.Office:
+		default:
+			return fmt.Errorf("%w: invalid property_type %q", ErrInvalidPatch, property.PropertyType)
+		}
+	}
+	if _, ok := patch["deal_type"]; ok {
+		switch property.DealType {
+		case models.Sale, models.Rent:
+		default:
+			return fmt.Errorf("%w: invalid deal_type %q", ErrInvalidPatch, property.DealType)
+		}
+	}
+	if _, ok := patch["status"]; ok {
+		switch property.Status {
+		case models.Ready, models.New, models.Shared:
+		default:
+			return fmt.Errorf("%w: invalid status %q", ErrInvalidPatch, property.Status)
+		}
+	}
+	if _, ok := patch["location_source"]; ok {
+		switch property.LocationSource {
+		case "", models.LocationSourceManual, models.LocationSourceGazetteer:
+		default:
+			return fmt.Errorf("%w: invalid location_source %q", ErrInvalidPatch, property.LocationSource)
+		}
+	}
+	if (property.Latitude == nil) != (property.Longitude == nil) {
+		return fmt.Errorf("%w: latitude and longitude must be set together", ErrInvalidPatch)
+	}
+	if property.Latitude != nil && !validLngLat(*property.Longitude, *property.Latitude) {
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidPatch)
+	}
+	return nil
+}
+
+func removedLanguages(before, after map[string]interface{}) []string {
+	beforeDetails, _ := before["details"].(map[string]interface{})
+	afterDetails, _ := after["details"].(map[string]interface{})
+	var removed []string
+	for language := range beforeDetails {
+		if _, ok := afterDetails[language]; !ok {
+			removed = append(removed, language)
+		}
+	}
+	return removed
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+func validLanguage(language string) bool {
+	for _, l := range PropertyLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJSONField reports whether the struct type t has a field encoded as name.
+func hasJSONField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if tag == name || (len(tag) > len(name) && tag[:len(name)+1] == name+",") {
+			return true
+		}
+	}
+	return false
+}
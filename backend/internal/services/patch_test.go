@@ -0,0 +1,196 @@
+// backend/internal/services/patch_test.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"kuckuc/internal/models"
+)
+
+func decodeJSON(t *testing.T, document string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatalf("%s: %v", document, err)
+	}
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7386 appendix A
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := json.Marshal(decodeJSON(t, tt.want))
+		if string(got) != string(want) {
+			t.Errorf("mergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, got, want)
+		}
+	}
+}
+
+func TestCheckPatch(t *testing.T) {
+	tests := []struct {
+		patch   string
+		wantErr bool
+	}{
+		{`{}`, false},
+		{`{"deal_type":"rent","latitude":44.8,"longitude":20.4}`, false},
+		{`{"details":{"en":{"price":950},"ru":null}}`, false},
+		{`{"details":{"sr":{"plot_facilities":["struja"]}}}`, false},
+		{`{"agent_code":"A-1"}`, true},
+		{`{"version":3}`, true},
+		{`{"lifecycle_state":"published"}`, true},
+		{`{"details":[{"language":"en"}]}`, true},
+		{`{"details":null}`, true},
+		{`{"details":{"de":{"price":1}}}`, true},
+		{`{"details":{"en":"text"}}`, true},
+		{`{"details":{"en":{"id":5}}}`, true},
+		{`{"details":{"en":{"property_id":5}}}`, true},
+		{`{"details":{"en":{"language":"sr"}}}`, true},
+		{`{"details":{"en":{"unknown":1}}}`, true},
+	}
+	for _, tt := range tests {
+		err := checkPatch(decodeJSON(t, tt.patch).(map[string]interface{}))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkPatch(%s) = %v, want error %v", tt.patch, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("checkPatch(%s) error %v is not ErrInvalidPatch", tt.patch, err)
+		}
+	}
+}
+
+func TestPatchDocument(t *testing.T) {
+	latitude, longitude := 44.8125, 20.4612
+	existing := &models.Property{
+		ID:             12,
+		AgentCode:      "A-12",
+		PropertyType:   models.Apartment,
+		DealType:       models.Sale,
+		Status:         models.Ready,
+		Version:        4,
+		Latitude:       &latitude,
+		Longitude:      &longitude,
+		LocationSource: models.LocationSourceManual,
+		Details: []models.PropertyDetails{
+			{ID: 30, PropertyID: 12, Language: "sr", City: "Beograd", Price: 120000, Equipment: json.RawMessage(`["lift"]`)},
+			{ID: 31, PropertyID: 12, Language: "en", City: "Belgrade", Price: 120000, Equipment: json.RawMessage(`["lift"]`)},
+			{ID: 32, PropertyID: 12, Language: "ru", City: "Белград", Price: 120000, Equipment: json.RawMessage(`["lift"]`)},
+		},
+	}
+
+	document, err := propertyDocument(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := document["agent_code"]; ok {
+		t.Error("agent_code is part of the patch document")
+	}
+	details := document["details"].(map[string]interface{})
+	if en, ok := details["en"].(map[string]interface{}); !ok || en["city"] != "Belgrade" || en["id"] != nil || en["language"] != nil {
+		t.Errorf("details.en = %v", details["en"])
+	}
+
+	patch := decodeJSON(t, `{"deal_type":"rent","latitude":null,"longitude":null,"details":{"en":{"price":950},"ru":null}}`)
+	before, _ := propertyDocument(existing)
+	after := mergePatch(document, patch).(map[string]interface{})
+	if removed := removedLanguages(before, after); len(removed) != 1 || removed[0] != "ru" {
+		t.Errorf("removedLanguages = %v, want [ru]", removed)
+	}
+
+	property, err := propertyFromDocument(existing, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if property.DealType != models.Rent || property.PropertyType != models.Apartment || property.Status != models.Ready {
+		t.Errorf("fields = %s %s %s", property.PropertyType, property.DealType, property.Status)
+	}
+	if property.Latitude != nil || property.Longitude != nil || property.LocationSource != models.LocationSourceManual {
+		t.Errorf("location = %v %v %q", property.Latitude, property.Longitude, property.LocationSource)
+	}
+	if property.ID != 12 || property.AgentCode != "A-12" || property.Version != 4 {
+		t.Errorf("server fields changed: %+v", property)
+	}
+	if len(property.Details) != 2 {
+		t.Fatalf("details = %+v, want en and sr", property.Details)
+	}
+	en, sr := property.Details[0], property.Details[1]
+	if en.Language != "en" || en.ID != 31 || en.PropertyID != 12 || en.Price != 950 || en.City != "Belgrade" ||
+		string(en.Equipment) != `["lift"]` {
+		t.Errorf("details.en = %+v", en)
+	}
+	if sr.Language != "sr" || sr.ID != 30 || sr.Price != 120000 {
+		t.Errorf("details.sr = %+v", sr)
+	}
+	if existing.DealType != models.Sale || existing.Latitude == nil || len(existing.Details) != 3 {
+		t.Error("propertyFromDocument changed the stored property")
+	}
+
+	// A language added by the patch has no ID yet
+	added, err := propertyFromDocument(&models.Property{ID: 12}, decodeJSON(t, `{"details":{"ru":{"city":"Нови Сад"}}}`).(map[string]interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added.Details) != 1 || added.Details[0].ID != 0 || added.Details[0].PropertyID != 12 || added.Details[0].Language != "ru" {
+		t.Errorf("added details = %+v", added.Details)
+	}
+
+	if _, err := propertyFromDocument(existing, decodeJSON(t, `{"details":{"en":{"price":"cheap"}}}`).(map[string]interface{})); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("mistyped field: %v, want %v", err, ErrInvalidPatch)
+	}
+}
+
+func TestValidatePatchedProperty(t *testing.T) {
+	latitude, longitude, outside := 44.8, 20.4, 95.0
+	tests := []struct {
+		name     string
+		property models.Property
+		patch    string
+		wantErr  bool
+	}{
+		{"valid", models.Property{PropertyType: models.House, DealType: models.Rent, Status: models.New}, `{"property_type":"house","deal_type":"rent","status":"new"}`, false},
+		{"unknown deal type", models.Property{DealType: "swap"}, `{"deal_type":"swap"}`, true},
+		{"unknown property type", models.Property{PropertyType: "castle"}, `{"property_type":"castle"}`, true},
+		{"unknown status", models.Property{Status: "sold"}, `{"status":"sold"}`, true},
+		{"unpatched field not checked", models.Property{Status: "legacy"}, `{}`, false},
+		{"unknown location source", models.Property{LocationSource: "gps"}, `{"location_source":"gps"}`, true},
+		{"coordinates", models.Property{Latitude: &latitude, Longitude: &longitude}, `{"latitude":44.8}`, false},
+		{"latitude only", models.Property{Latitude: &latitude}, `{"longitude":null}`, true},
+		{"out of range", models.Property{Latitude: &outside, Longitude: &longitude}, `{"latitude":95}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePatchedProperty(&tt.property, decodeJSON(t, tt.patch).(map[string]interface{}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePatchedProperty = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPatch) {
+				t.Errorf("error %v is not ErrInvalidPatch", err)
+			}
+		})
+	}
+}
@@ -2,15 +2,17 @@
 package services
 
 import (
-	"encoding/json"
+	"database/sql"
 	"errors"
 	"fmt"
 	"kuckuc/internal/models"
 	"log"
 	"math/rand"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Helper functions for generating unique codes
@@ -31,14 +33,16 @@ func generateAgentCode(dealType models.DealType) string {
 }
 
 type PropertyService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	geocoder *GeocodingService
 }
 
-func NewPropertyService(db *gorm.DB) *PropertyService {
-	return &PropertyService{db: db}
+func NewPropertyService(db *gorm.DB, geocoder *GeocodingService) *PropertyService {
+	return &PropertyService{db: db, geocoder: geocoder}
 }
 
 type PropertyFilter struct {
+	Query        string  `form:"q"`
 	PropertyType string  `form:"property_type"`
 	DealType     string  `form:"deal_type"`
 	City         string  `form:"city"`
@@ -49,28 +53,161 @@ type PropertyFilter struct {
 	AreaMin      float64 `form:"area_min"`
 	AreaMax      float64 `form:"area_max"`
 	IsActive     *bool   `form:"is_active"`
+	// LifecycleState is a comma-separated list of states, e.g.
+	// "published,reserved"
+	LifecycleState string `form:"lifecycle_state"`
+
+	// Radius search around Lat/Lng, in meters
+	Lat    *float64 `form:"lat"`
+	Lng    *float64 `form:"lng"`
+	Radius float64  `form:"radius"`
+	// BBox is "minLng,minLat,maxLng,maxLat"
+	BBox string `form:"bbox"`
+	// Polygon is a GeoJSON Polygon geometry
+	Polygon string `form:"polygon"`
+
+	// Sort is one of the keys of propertySortColumns, prefixed with "-" for
+	// descending order, e.g. "-price_per_m2". "distance" is available for
+	// radius searches.
+	Sort    string `form:"sort"`
+	Page    int    `form:"page"`
+	PerPage int    `form:"per_page"`
 }
 
-func (s *PropertyService) ListProperties(filter PropertyFilter, language string) ([]models.Property, error) {
-	var properties []models.Property
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+var (
+	ErrInvalidSort = errors.New("invalid sort field")
+	// ErrVersionConflict means the property was changed since the client
+	// read it
+	ErrVersionConflict = errors.New("the property was changed by someone else")
+)
+
+var propertySortColumns = map[string]struct {
+	column       string
+	needsDetails bool
+}{
+	"price":        {"property_details.price", true},
+	"area":         {"property_details.living_area", true},
+	"price_per_m2": {"property_details.price / NULLIF(property_details.living_area, 0)", true},
+	"created_at":   {"properties.created_at", false},
+	"updated_at":   {"properties.updated_at", false},
+}
+
+type PropertyPage struct {
+	Items      []models.Property `json:"items"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	PerPage    int               `json:"per_page"`
+	TotalPages int               `json:"total_pages"`
+}
+
+type searchHit struct {
+	ID         uint
+	SearchRank float64
+	Highlight  string
+	Distance   *float64
+}
+
+// earthDistance is the distance in meters from the radius search origin.
+const earthDistance = "earth_distance(ll_to_earth(@lat, @lng), ll_to_earth(properties.latitude, properties.longitude))"
 
-	query := s.db.Preload("Details", "language = ?", language)
+// PublicLifecycleStates are the lifecycle states of listings shown to
+// visitors. Users with PermPropertiesRead see all states.
+var PublicLifecycleStates = []models.LifecycleState{models.StatePublished, models.StateReserved}
+
+// visibleTo limits query to the properties actor may see.
+func visibleTo(query *gorm.DB, actor Actor) *gorm.DB {
+	if actor.Can(PermPropertiesRead) {
+		return query
+	}
+	return query.Where("properties.lifecycle_state IN ?", PublicLifecycleStates)
+}
+
+// ListProperties returns one page of properties matching the filter that
+// actor may see. When filter.Query is set, results are ranked by full-text
+// relevance over the details in the requested language, and an exact
+// property or agent code match always ranks first.
+func (s *PropertyService) ListProperties(filter PropertyFilter, language string, actor Actor) (*PropertyPage, error) {
+	page, perPage := filter.Page, filter.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+
+	nearby := filter.Lat != nil || filter.Lng != nil || filter.Radius != 0
+	if nearby && (filter.Lat == nil || filter.Lng == nil || filter.Radius <= 0 || filter.Radius > MaxSearchRadius ||
+		!validLngLat(*filter.Lng, *filter.Lat)) {
+		return nil, fmt.Errorf("%w: radius search needs lat, lng and a radius of up to %d m", ErrInvalidGeoFilter, MaxSearchRadius)
+	}
+
+	sortKey := strings.TrimPrefix(filter.Sort, "-")
+	sortColumn, ok := propertySortColumns[sortKey]
+	if sortKey == "distance" && nearby {
+		sortColumn.column, ok = "distance", true
+	}
+	if filter.Sort != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSort, filter.Sort)
+	}
+
+	searchQuery := strings.TrimSpace(filter.Query)
+	needsDetails := searchQuery != "" || sortColumn.needsDetails ||
+		filter.City != "" || filter.PriceMin > 0 || filter.PriceMax > 0 ||
+		filter.RoomsMin > 0 || filter.RoomsMax > 0 || filter.AreaMin > 0 || filter.AreaMax > 0
+
+	query := visibleTo(s.db.Model(&models.Property{}), actor)
 
 	if filter.PropertyType != "" {
-		query = query.Where("property_type = ?", filter.PropertyType)
+		query = query.Where("properties.property_type = ?", filter.PropertyType)
 	}
 	if filter.DealType != "" {
-		query = query.Where("deal_type = ?", filter.DealType)
+		query = query.Where("properties.deal_type = ?", filter.DealType)
 	}
 	if filter.IsActive != nil {
-		query = query.Where("is_active = ?", *filter.IsActive)
+		query = query.Where("properties.is_active = ?", *filter.IsActive)
+	}
+	if filter.LifecycleState != "" {
+		states, err := ParseLifecycleStates(filter.LifecycleState)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("properties.lifecycle_state IN ?", states)
 	}
 
-	if filter.City != "" || filter.PriceMin > 0 || filter.PriceMax > 0 ||
-		filter.RoomsMin > 0 || filter.RoomsMax > 0 || filter.AreaMin > 0 || filter.AreaMax > 0 {
+	var geoArgs []interface{}
+	if nearby {
+		geoArgs = []interface{}{sql.Named("lat", *filter.Lat), sql.Named("lng", *filter.Lng), sql.Named("radius", filter.Radius)}
+		// The earth_box test uses the GiST index, the exact distance check
+		// removes the corners of the box
+		query = query.Where("earth_box(ll_to_earth(@lat, @lng), @radius) @> ll_to_earth(properties.latitude, properties.longitude) AND "+
+			earthDistance+" <= @radius", geoArgs...)
+	}
+	if filter.BBox != "" {
+		box, err := ParseBBox(filter.BBox)
+		if err != nil {
+			return nil, err
+		}
+		query = withinBBox(query, *box)
+	}
+	if filter.Polygon != "" {
+		polygon, err := ParseGeoPolygon(filter.Polygon)
+		if err != nil {
+			return nil, err
+		}
+		query = withinBBox(query, polygon.Bounds()).
+			Where("?::polygon @> point(properties.longitude, properties.latitude)", polygon.SQL())
+	}
 
-		query = query.Joins("JOIN property_details ON properties.id = property_details.property_id")
-		query = query.Where("property_details.language = ?", language)
+	if needsDetails {
+		query = query.Joins("LEFT JOIN property_details ON properties.id = property_details.property_id AND property_details.language = ?", language)
 
 		if filter.City != "" {
 			query = query.Where("LOWER(property_details.city) LIKE LOWER(?)", "%"+filter.City+"%")
@@ -95,26 +232,132 @@ func (s *PropertyService) ListProperties(filter PropertyFilter, language string)
 		}
 	}
 
-	if err := query.Find(&properties).Error; err != nil {
+	tsQuery := "websearch_to_tsquery(property_search_config(@language), @q)"
+	searchArgs := []interface{}{
+		sql.Named("language", language),
+		sql.Named("q", searchQuery),
+		sql.Named("code", strings.ToUpper(searchQuery)),
+	}
+	if searchQuery != "" {
+		query = query.Where("property_details.search_vector @@ "+tsQuery+
+			" OR properties.property_code = @code OR properties.agent_code = @code", searchArgs...)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("error counting properties: %w", err)
+	}
+
+	result := &PropertyPage{
+		Items:      []models.Property{},
+		Total:      total,
+		Page:       page,
+		PerPage:    perPage,
+		TotalPages: int((total + int64(perPage) - 1) / int64(perPage)),
+	}
+	if total == 0 {
+		return result, nil
+	}
+
+	columns := []string{"properties.id AS id"}
+	var selectArgs []interface{}
+	if searchQuery != "" {
+		columns = append(columns,
+			"ts_rank(property_details.search_vector, "+tsQuery+") + "+
+				"CASE WHEN properties.property_code = @code OR properties.agent_code = @code THEN 10 ELSE 0 END AS search_rank",
+			"ts_headline(property_search_config(@language), "+
+				"concat_ws(' · ', property_details.city, property_details.district, property_details.address, property_details.description), "+
+				tsQuery+", 'MaxWords=30, MinWords=10, MaxFragments=2, StartSel=<mark>, StopSel=</mark>') AS highlight")
+		selectArgs = append(selectArgs, searchArgs...)
+	}
+	if nearby {
+		columns = append(columns, earthDistance+" AS distance")
+		selectArgs = append(selectArgs, geoArgs...)
+	}
+	query = query.Select(strings.Join(columns, ", "), selectArgs...)
+
+	switch {
+	case sortKey != "" && strings.HasPrefix(filter.Sort, "-"):
+		query = query.Order(sortColumn.column + " DESC NULLS LAST")
+	case sortKey != "":
+		query = query.Order(sortColumn.column + " ASC NULLS LAST")
+	case searchQuery != "":
+		query = query.Order("search_rank DESC")
+	case nearby:
+		query = query.Order("distance ASC")
+	default:
+		query = query.Order("properties.created_at DESC")
+	}
+	query = query.Order("properties.id DESC")
+
+	var hits []searchHit
+	if err := query.Offset((page - 1) * perPage).Limit(perPage).Scan(&hits).Error; err != nil {
 		return nil, fmt.Errorf("error fetching properties: %w", err)
 	}
+	if len(hits) == 0 {
+		return result, nil
+	}
 
-	for i := range properties {
-		var documents []models.Document
-		if err := s.db.Table("property_documents").
-			Where("property_id = ? AND is_public = ?", properties[i].ID, true).
-			Find(&documents).Error; err == nil {
-			properties[i].Documents = documents
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	// Details and public documents are preloaded with one query each instead
+	// of one documents query per property
+	var found []models.Property
+	if err := s.db.Preload("Details", "language = ?", language).
+		Preload("Documents", "is_public = ?", true).
+		Preload("Documents.Variants").
+		Where("id IN ?", ids).
+		Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("error fetching properties: %w", err)
+	}
+
+	byID := make(map[uint]models.Property, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	for _, hit := range hits {
+		p, ok := byID[hit.ID]
+		if !ok {
+			continue
 		}
+		p.SearchRank = hit.SearchRank
+		p.Highlight = hit.Highlight
+		p.Distance = hit.Distance
+		useThumbnails(p.Documents)
+		result.Items = append(result.Items, p)
 	}
 
-	return properties, nil
+	return result, nil
 }
 
-func (s *PropertyService) GetProperty(id uint, language string) (*models.Property, error) {
+func withinBBox(query *gorm.DB, box BBox) *gorm.DB {
+	return query.Where("properties.latitude BETWEEN ? AND ? AND properties.longitude BETWEEN ? AND ?",
+		box.MinLat, box.MaxLat, box.MinLng, box.MaxLng)
+}
+
+// useThumbnails points image documents at their JPEG thumbnail so listings
+// don't download full-size originals. The other variants, including WebP,
+// stay available in Variants.
+func useThumbnails(documents []models.Document) {
+	for i := range documents {
+		for _, variant := range documents[i].Variants {
+			if variant.Variant == ThumbnailVariant && variant.Format == ImageFormatJPEG {
+				documents[i].FilePath = variant.FilePath
+				break
+			}
+		}
+	}
+}
+
+// GetProperty returns a property if actor may see it, see ListProperties.
+func (s *PropertyService) GetProperty(id uint, language string, actor Actor) (*models.Property, error) {
 	var property models.Property
 	log.Printf("Attempting to fetch property ID: %d", id)
-	if err := s.db.Preload("Details").
+	if err := visibleTo(s.db.Preload("Details"), actor).
 		First(&property, id).Error; err != nil {
 		return nil, err
 	}
@@ -122,6 +365,7 @@ func (s *PropertyService) GetProperty(id uint, language string) (*models.Propert
 	// Загружаем документы отдельно, чтобы избежать ошибок с отсутствующей таблицей
 	var documents []models.Document
 	if err := s.db.Table("property_documents").
+		Preload("Variants").
 		Where("property_id = ? AND is_public = ?", id, true).
 		Find(&documents).Error; err == nil {
 		property.Documents = documents
@@ -130,130 +374,384 @@ func (s *PropertyService) GetProperty(id uint, language string) (*models.Propert
 	return &property, nil
 }
 
-func (s *PropertyService) CreateProperty(property *models.Property, agentID uint) error {
+func (s *PropertyService) CreateProperty(property *models.Property, actor Actor) error {
+	if !actor.Can(PermPropertiesCreate) {
+		return ErrForbidden
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Generate unique codes
-		property.AgentCode = generateAgentCode(property.DealType)
 		property.PropertyCode = generatePropertyCode()
+		return s.createProperty(tx, property, actor, "property_created")
+	})
+}
 
-		// Create the property
-		if err := tx.Create(property).Error; err != nil {
-			return err
-		}
+// createProperty stores a new draft property with its details and owner,
+// under property.PropertyCode and a new agent code. action is recorded in
+// the history.
+func (s *PropertyService) createProperty(tx *gorm.DB, property *models.Property, actor Actor, action string) error {
+	property.AgentCode = generateAgentCode(property.DealType)
 
-		// Create history record
-		history := models.History{
-			PropertyID: property.ID,
-			ActionType: "create",
-			ActionDate: time.Now(),
-			AgentID:    agentID,
-			Details:    json.RawMessage(`{"action": "property_created"}`),
-		}
+	// Only senior agents and admins hand properties to someone else
+	property.CreatedBy = &actor.UserID
+	if property.AssignedAgentID == nil || !actor.Can(PermPropertiesAssign) {
+		property.AssignedAgentID = &actor.UserID
+	}
 
-		return tx.Create(&history).Error
-	})
+	// New properties are drafts until published with TransitionProperty
+	now := time.Now()
+	property.Version = 1
+	property.LifecycleState = models.StateDraft
+	property.IsActive = false
+	property.StateChangedAt = &now
+	property.PublishedAt, property.ReservedAt, property.ClosedAt, property.ArchivedAt = nil, nil, nil, nil
+
+	if err := s.geocoder.ApplyToProperty(tx, property); err != nil {
+		return fmt.Errorf("geocoding failed: %w", err)
+	}
+
+	// Create the property
+	if err := tx.Create(property).Error; err != nil {
+		return err
+	}
+
+	return recordHistory(tx, property.ID, HistoryCreate, actor, historyDetails{Action: action})
 }
 
-func (s *PropertyService) UpdateProperty(property *models.Property, agentID uint) error {
+// UpdateProperty saves property and its details if the stored version is
+// still version, and increments the version. A version of 0 skips the check
+// and overwrites whatever is stored. On a mismatch nothing is saved and
+// ErrVersionConflict is returned.
+func (s *PropertyService) UpdateProperty(property *models.Property, version int, actor Actor) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Обновляем основную информацию о свойстве
-		if err := tx.Omit("Details").Save(property).Error; err != nil {
+		existing, err := s.lockForEdit(tx, actor, property.ID, version)
+		if err != nil {
 			return err
 		}
-
-		// Обновляем детали для каждого языка
-		for _, detail := range property.Details {
-			// Пытаемся найти существующую запись для этого языка
-			var existingDetail models.PropertyDetails
-			err := tx.Where("property_id = ? AND language = ?", property.ID, detail.Language).First(&existingDetail).Error
-
-			if err == gorm.ErrRecordNotFound {
-				// Если записи нет, создаем новую
-				detail.PropertyID = property.ID
-				if err := tx.Create(&detail).Error; err != nil {
-					return err
-				}
-			} else if err != nil {
-				return err
-			} else {
-				// Если запись существует, обновляем её
-				detail.ID = existingDetail.ID
-				detail.PropertyID = property.ID
-				if err := tx.Save(&detail).Error; err != nil {
-					return err
-				}
-			}
+		before, err := s.loadDocument(tx, existing)
+		if err != nil {
+			return err
+		}
+		if err := s.saveProperty(tx, property, existing); err != nil {
+			return err
+		}
+		after, err := s.loadDocument(tx, property)
+		if err != nil {
+			return err
 		}
 
 		// Создаем запись в истории
-		history := models.History{
-			PropertyID: property.ID,
-			ActionType: "update",
-			ActionDate: time.Now(),
-			AgentID:    agentID,
-			Details:    json.RawMessage(`{"action": "property_updated"}`),
+		changes := diffDocuments(before, after)
+		if len(changes) == 0 {
+			return nil
 		}
-
-		return tx.Create(&history).Error
+		return recordHistory(tx, property.ID, HistoryUpdate, actor, historyDetails{
+			Action:  "property_updated",
+			Changes: changes,
+		})
 	})
 }
 
-func (s *PropertyService) UpdatePropertyStatus(id uint, isActive bool, agentID uint) error {
+// UpdatePropertyStatus is the older on/off switch: it publishes or archives
+// the property. Other lifecycle states are set with TransitionProperty.
+func (s *PropertyService) UpdatePropertyStatus(id uint, isActive bool, actor Actor) error {
+	to := models.StateArchived
+	if isActive {
+		to = models.StatePublished
+	}
+	_, err := s.TransitionProperty(id, to, "", actor)
+	return err
+}
+
+// AssignProperty makes agentID the agent responsible for a property.
+func (s *PropertyService) AssignProperty(id uint, agentID uint, actor Actor) error {
+	if !actor.Can(PermPropertiesAssign) {
+		return ErrForbidden
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&models.Property{}).
-			Where("id = ?", id).
-			Update("is_active", isActive).Error; err != nil {
+		var agent models.User
+		if err := tx.First(&agent, agentID).Error; err != nil {
+			return fmt.Errorf("agent not found: %w", err)
+		}
+		if !HasPermission(agent.Role, PermPropertiesEditOwn) {
+			return fmt.Errorf("user %d cannot be assigned properties", agentID)
+		}
+
+		var property models.Property
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "assigned_agent_id").First(&property, id).Error; err != nil {
 			return err
 		}
 
-		// Create history record
-		history := models.History{
-			PropertyID: id,
-			ActionType: "status_update",
-			ActionDate: time.Now(),
-			AgentID:    agentID,
-			Details:    json.RawMessage(fmt.Sprintf(`{"action": "status_updated", "is_active": %t}`, isActive)),
+		if err := tx.Model(&models.Property{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"assigned_agent_id": agentID,
+			"version":           gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
 		}
 
-		return tx.Create(&history).Error
+		return recordHistory(tx, id, HistoryAssign, actor, historyDetails{
+			Action: "agent_assigned",
+			Changes: []FieldChange{{
+				Field:  "assigned_agent_id",
+				Before: property.AssignedAgentID,
+				After:  agentID,
+			}},
+		})
 	})
 }
 
+// loadDocument returns the patch document of property with its stored
+// details.
+func (s *PropertyService) loadDocument(tx *gorm.DB, property *models.Property) (map[string]interface{}, error) {
+	withDetails := *property
+	withDetails.Details = nil
+	if err := tx.Where("property_id = ?", property.ID).Find(&withDetails.Details).Error; err != nil {
+		return nil, err
+	}
+	return propertyDocument(&withDetails)
+}
+
+// lockForEdit returns the property after checking that actor may edit it
+// and that it is still at version (0 skips the check). The row stays locked
+// until tx ends, so the version cannot change before the update.
+func (s *PropertyService) lockForEdit(tx *gorm.DB, actor Actor, id uint, version int) (*models.Property, error) {
+	var existing models.Property
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeEdit(tx, actor, id); err != nil {
+		return nil, err
+	}
+	if version != 0 && existing.Version != version {
+		return nil, ErrVersionConflict
+	}
+	return &existing, nil
+}
+
+// propertyColumns are the properties columns saveProperty writes. Anything
+// else a client sends, such as deleted_at or a nested owner, is ignored.
+var propertyColumns = []string{
+	"property_type", "deal_type", "status", "version",
+	"latitude", "longitude", "location_source", "updated_at",
+}
+
+// saveProperty stores property and its details over existing, with the
+// next version. Languages missing from property.Details are kept.
+func (s *PropertyService) saveProperty(tx *gorm.DB, property, existing *models.Property) error {
+	property.Version = existing.Version + 1
+
+	// Codes and creation time never change. Ownership only changes through
+	// AssignProperty, the lifecycle state through TransitionProperty,
+	// deletion through DeleteProperty and the owner on its own
+	property.AgentCode = existing.AgentCode
+	property.PropertyCode = existing.PropertyCode
+	property.CreatedAt = existing.CreatedAt
+	property.CreatedBy = existing.CreatedBy
+	property.AssignedAgentID = existing.AssignedAgentID
+	property.LifecycleState = existing.LifecycleState
+	property.IsActive = existing.IsActive
+	property.StateChangedAt = existing.StateChangedAt
+	property.PublishedAt = existing.PublishedAt
+	property.ReservedAt = existing.ReservedAt
+	property.ClosedAt = existing.ClosedAt
+	property.ArchivedAt = existing.ArchivedAt
+	property.DeletedAt = existing.DeletedAt
+	property.DeletedBy = existing.DeletedBy
+	property.Owner = models.PropertyOwner{}
+
+	// Clients send back the location_source they loaded, so coordinates that
+	// were moved still say gazetteer and would be overwritten
+	if property.Latitude != nil && property.Longitude != nil &&
+		(!sameCoordinate(property.Latitude, existing.Latitude) || !sameCoordinate(property.Longitude, existing.Longitude)) {
+		property.LocationSource = models.LocationSourceManual
+	}
+
+	if err := s.geocoder.ApplyToProperty(tx, property); err != nil {
+		return fmt.Errorf("geocoding failed: %w", err)
+	}
+
+	// Обновляем основную информацию о свойстве
+	if err := tx.Model(property).Select(propertyColumns).Updates(property).Error; err != nil {
+		return err
+	}
+
+	// Обновляем детали для каждого языка
+	for i := range property.Details {
+		detail := &property.Details[i]
+		// Пытаемся найти существующую запись для этого языка
+		var existingDetail models.PropertyDetails
+		err := tx.Where("property_id = ? AND language = ?", property.ID, detail.Language).First(&existingDetail).Error
+
+		if err == gorm.ErrRecordNotFound {
+			// Если записи нет, создаем новую
+			detail.ID = 0
+			detail.PropertyID = property.ID
+			if err := tx.Create(detail).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			// Если запись существует, обновляем её
+			detail.ID = existingDetail.ID
+			detail.PropertyID = property.ID
+			if err := tx.Save(detail).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sameCoordinate(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// AuthorizeEdit checks that actor may modify the property and its documents.
+func (s *PropertyService) AuthorizeEdit(actor Actor, propertyID uint) error {
+	_, err := s.authorizeEdit(s.db, actor, propertyID)
+	return err
+}
+
+// authorizeEdit returns the property if actor may edit it: senior agents and
+// admins edit any property, agents only those they created or are assigned to.
+func (s *PropertyService) authorizeEdit(tx *gorm.DB, actor Actor, propertyID uint) (*models.Property, error) {
+	if !actor.Can(PermPropertiesEditOwn) && !actor.Can(PermPropertiesEditAny) {
+		return nil, ErrForbidden
+	}
+
+	var property models.Property
+	if err := tx.Select("id", "deal_type", "created_by", "assigned_agent_id", "lifecycle_state", "is_active",
+		"state_changed_at", "published_at", "reserved_at", "closed_at", "archived_at").First(&property, propertyID).Error; err != nil {
+		return nil, err
+	}
+
+	if actor.Can(PermPropertiesEditAny) {
+		return &property, nil
+	}
+	if (property.CreatedBy != nil && *property.CreatedBy == actor.UserID) ||
+		(property.AssignedAgentID != nil && *property.AssignedAgentID == actor.UserID) {
+		return &property, nil
+	}
+	return nil, ErrForbidden
+}
+
 func (s *PropertyService) ExportProperties(filter PropertyFilter) ([]byte, error) {
 	// Implementation of Excel export will be added later
 	return nil, errors.New("not implemented")
 }
 
-func (s *PropertyService) AddDocument(document *models.Document) error {
-	return s.db.Table("property_documents").Create(document).Error
+func (s *PropertyService) AddDocument(document *models.Document, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("property_documents").Omit("Variants").Create(document).Error; err != nil {
+			return err
+		}
+
+		for i := range document.Variants {
+			document.Variants[i].DocumentID = document.ID
+		}
+		if len(document.Variants) > 0 {
+			if err := tx.Create(&document.Variants).Error; err != nil {
+				return err
+			}
+		}
+
+		return recordHistory(tx, document.PropertyID, HistoryDocumentAdd, actor, historyDetails{
+			Action:     "document_added",
+			DocumentID: document.ID,
+			Changes:    []FieldChange{{Field: "document", After: documentSummary(document)}},
+		})
+	})
 }
 
+// GetDocument returns a document that is not deleted, of a property that is
+// not deleted.
 func (s *PropertyService) GetDocument(id uint) (*models.Document, error) {
 	var document models.Document
-	if err := s.db.Table("property_documents").First(&document, id).Error; err != nil {
+	if err := s.db.Table("property_documents").Preload("Variants").
+		Where("property_id IN (?)", s.db.Model(&models.Property{}).Select("id")).
+		First(&document, id).Error; err != nil {
 		return nil, err
 	}
 	return &document, nil
 }
 
-func (s *PropertyService) DeleteDocument(id uint) error {
-	return s.db.Table("property_documents").Delete(&models.Document{}, id).Error
+// IsPublicFile reports whether filePath is the original or a variant of a
+// public document.
+func (s *PropertyService) IsPublicFile(filePath string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Document{}).
+		Where("is_public = ?", true).
+		Where("property_id IN (?)", s.db.Model(&models.Property{}).Select("id")).
+		Where("file_path = ? OR id IN (?)", filePath,
+			s.db.Model(&models.DocumentVariant{}).Select("document_id").Where("file_path = ?", filePath)).
+		Count(&count).Error
+	return count > 0, err
 }
 
-func (s *PropertyService) UpdateDocumentVisibility(fileID uint, propertyID uint, isPublic bool) error {
-	result := s.db.Table("property_documents").
-		Where("id = ? AND property_id = ?", fileID, propertyID).
-		Update("is_public", isPublic)
+// DeleteDocument marks a document deleted. It is hidden, but it and its
+// files are kept until the retention job purges them, and it can be
+// restored until then.
+func (s *PropertyService) DeleteDocument(id uint, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var document models.Document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&document, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&document).Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"deleted_by": actor.UserID,
+		}).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
-		return result.Error
-	}
+		return recordHistory(tx, document.PropertyID, HistoryDocumentDelete, actor, historyDetails{
+			Action:     "document_deleted",
+			DocumentID: document.ID,
+			Changes:    []FieldChange{{Field: "document", Before: documentSummary(&document)}},
+		})
+	})
+}
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("document not found or does not belong to property")
-	}
+func (s *PropertyService) UpdateDocumentVisibility(fileID uint, propertyID uint, isPublic bool, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var document models.Document
+		err := tx.Table("property_documents").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND property_id = ?", fileID, propertyID).
+			First(&document).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("document not found or does not belong to property")
+		}
+		if err != nil {
+			return err
+		}
+		if document.IsPublic == isPublic {
+			return nil
+		}
 
-	return nil
+		if err := tx.Table("property_documents").
+			Where("id = ?", fileID).
+			Update("is_public", isPublic).Error; err != nil {
+			return err
+		}
+
+		return recordHistory(tx, propertyID, HistoryDocumentVisibility, actor, historyDetails{
+			Action:     "document_visibility_changed",
+			DocumentID: document.ID,
+			Changes:    []FieldChange{{Field: "is_public", Before: document.IsPublic, After: isPublic}},
+		})
+	})
+}
+
+// documentSummary is how a document appears in the history.
+func documentSummary(document *models.Document) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        document.ID,
+		"file_type": document.FileType,
+		"file_path": document.FilePath,
+		"is_public": document.IsPublic,
+	}
 }
 func (s *PropertyService) GetAllDocuments(propertyID uint) ([]models.Document, error) {
 	var documents []models.Document
@@ -265,15 +763,7 @@ func (s *PropertyService) GetAllDocuments(propertyID uint) ([]models.Document, e
 	return documents, nil
 }
 
-func (s *PropertyService) GetPropertyHistory(propertyID uint) ([]models.History, error) {
-	var history []models.History
-	if err := s.db.Where("property_id = ?", propertyID).
-		Order("action_date DESC").
-		Find(&history).Error; err != nil {
-		return nil, err
-	}
-	return history, nil
-}
+
 func (s *PropertyService) ListPropertiesByIDs(ids []uint, language string) ([]models.Property, error) {
 	var properties []models.Property
 	query := s.db.Preload("Details", "language = ?", language).
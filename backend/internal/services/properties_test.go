@@ -0,0 +1,407 @@
+// backend/internal/services/properties_test.go
+
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+
+	"kuckuc/internal/models"
+)
+
+// dryRunDB returns a database that builds statements without running them,
+// and the statements built so far with their arguments filled in.
+func dryRunDB(t *testing.T) (*gorm.DB, *[]string) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statements []string
+	record := func(db *gorm.DB) {
+		statements = append(statements, db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Row().After("gorm:row").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	return db, &statements
+}
+
+// createTestProperty adds a ready apartment without details, created by
+// and assigned to agentID.
+func createTestProperty(t *testing.T, db *gorm.DB, dealType models.DealType, agentID uint) *models.Property {
+	t.Helper()
+	property := &models.Property{
+		AgentCode:       generateAgentCode(dealType),
+		PropertyCode:    generatePropertyCode(),
+		PropertyType:    models.Apartment,
+		DealType:        dealType,
+		Status:          models.Ready,
+		CreatedBy:       &agentID,
+		AssignedAgentID: &agentID,
+	}
+	if err := db.Omit(clause.Associations).Create(property).Error; err != nil {
+		t.Fatal(err)
+	}
+	return property
+}
+
+func TestListPropertiesSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantSearch bool
+		wantCode   string
+	}{
+		{"words", "stan centar", true, "'STAN CENTAR'"},
+		{"code", " ku-12 ", true, "'KU-12'"},
+		{"blank", "   ", false, ""},
+		{"none", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, statements := dryRunDB(t)
+			s := NewPropertyService(db, NewGeocodingService(db))
+
+			if _, err := s.ListProperties(PropertyFilter{Query: tt.query, City: "Beograd"}, "sr", Actor{Role: RoleAgent}); err != nil {
+				t.Fatal(err)
+			}
+
+			var search string
+			for _, statement := range *statements {
+				if strings.Contains(statement, "websearch_to_tsquery") {
+					search = statement
+				}
+			}
+			if (search != "") != tt.wantSearch {
+				t.Fatalf("ran search = %v, want %v: %q", search != "", tt.wantSearch, *statements)
+			}
+			if !tt.wantSearch {
+				return
+			}
+			for _, want := range []string{
+				"property_details.search_vector @@",
+				"properties.property_code =",
+				"properties.agent_code =",
+				"property_details.language =",
+				"LOWER(property_details.city) LIKE",
+				"properties.property_code = " + tt.wantCode,
+			} {
+				if !strings.Contains(search, want) {
+					t.Errorf("search statement lacks %q: %s", want, search)
+				}
+			}
+		})
+	}
+}
+
+// withCount makes counts in a dry run database return n, so that listings go
+// on to select a page.
+func withCount(t *testing.T, db *gorm.DB, n int64) {
+	t.Helper()
+	err := db.Callback().Query().After("gorm:query").Register("test:count", func(db *gorm.DB) {
+		if count, ok := db.Statement.Dest.(*int64); ok {
+			*count = n
+			db.RowsAffected = 1
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListPropertiesPagination(t *testing.T) {
+	tests := []struct {
+		page, perPage         int
+		wantPage, wantPerPage int
+	}{
+		{0, 0, 1, DefaultPerPage},
+		{-3, -1, 1, DefaultPerPage},
+		{2, 50, 2, 50},
+		{1, MaxPerPage + 1, 1, MaxPerPage},
+	}
+	for _, tt := range tests {
+		db, _ := dryRunDB(t)
+		s := NewPropertyService(db, NewGeocodingService(db))
+
+		page, err := s.ListProperties(PropertyFilter{Page: tt.page, PerPage: tt.perPage}, "sr", Actor{Role: RoleAgent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Page != tt.wantPage || page.PerPage != tt.wantPerPage {
+			t.Errorf("page %d, per page %d: got page %d, per page %d, want %d, %d",
+				tt.page, tt.perPage, page.Page, page.PerPage, tt.wantPage, tt.wantPerPage)
+		}
+		if page.Items == nil || page.Total != 0 || page.TotalPages != 0 {
+			t.Errorf("empty page = %+v", page)
+		}
+	}
+}
+
+func TestListPropertiesSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		query   string
+		wantErr error
+		want    []string
+	}{
+		{"", "", nil, []string{"ORDER BY properties.created_at DESC,properties.id DESC", "LIMIT 20 OFFSET 20"}},
+		{"", "stan", nil, []string{"ORDER BY search_rank DESC,properties.id DESC"}},
+		{"price", "stan", nil, []string{"ORDER BY property_details.price ASC NULLS LAST,properties.id DESC"}},
+		{"-price_per_m2", "", nil, []string{
+			"LEFT JOIN property_details",
+			"ORDER BY property_details.price / NULLIF(property_details.living_area, 0) DESC NULLS LAST",
+		}},
+		{"-updated_at", "", nil, []string{"ORDER BY properties.updated_at DESC NULLS LAST"}},
+		{"title", "", ErrInvalidSort, nil},
+		{"-", "", ErrInvalidSort, nil},
+		{"price;DROP TABLE properties", "", ErrInvalidSort, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			db, statements := dryRunDB(t)
+			withCount(t, db, 45)
+			s := NewPropertyService(db, NewGeocodingService(db))
+
+			// A dry run cannot scan the selected ids, so only the statement
+			// matters once the filter is valid
+			_, err := s.ListProperties(PropertyFilter{Query: tt.query, Sort: tt.sort, Page: 2}, "sr", Actor{Role: RoleAgent})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			selected := (*statements)[len(*statements)-1]
+			for _, want := range tt.want {
+				if !strings.Contains(selected, want) {
+					t.Errorf("statement lacks %q: %s", want, selected)
+				}
+			}
+		})
+	}
+}
+
+func TestListPropertiesGeoFilter(t *testing.T) {
+	lat, lng := 44.8125, 20.4612
+	badLat := 91.0
+	square := `{"type":"Polygon","coordinates":[[[20.4,44.8],[20.5,44.8],[20.5,44.9],[20.4,44.9]]]}`
+
+	tests := []struct {
+		name    string
+		filter  PropertyFilter
+		wantErr error
+		want    []string
+	}{
+		{"radius", PropertyFilter{Lat: &lat, Lng: &lng, Radius: 1500}, nil, []string{
+			"earth_box(ll_to_earth(44.8125, 20.4612), 1500)",
+			"AS distance",
+			"ORDER BY distance ASC",
+		}},
+		{"radius sorted by distance", PropertyFilter{Lat: &lat, Lng: &lng, Radius: 1500, Sort: "-distance"}, nil, []string{
+			"ORDER BY distance DESC NULLS LAST",
+		}},
+		{"bbox", PropertyFilter{BBox: "20.3,44.7,20.6,44.9"}, nil, []string{
+			"properties.latitude BETWEEN 44.7 AND 44.9 AND properties.longitude BETWEEN 20.3 AND 20.6",
+		}},
+		{"polygon", PropertyFilter{Polygon: square}, nil, []string{
+			"properties.latitude BETWEEN 44.8 AND 44.9",
+			"'((20.4,44.8),(20.5,44.8),(20.5,44.9),(20.4,44.9))'::polygon @> point(properties.longitude, properties.latitude)",
+		}},
+		{"radius without center", PropertyFilter{Radius: 1500}, ErrInvalidGeoFilter, nil},
+		{"radius without lng", PropertyFilter{Lat: &lat, Radius: 1500}, ErrInvalidGeoFilter, nil},
+		{"center without radius", PropertyFilter{Lat: &lat, Lng: &lng}, ErrInvalidGeoFilter, nil},
+		{"negative radius", PropertyFilter{Lat: &lat, Lng: &lng, Radius: -1}, ErrInvalidGeoFilter, nil},
+		{"radius too large", PropertyFilter{Lat: &lat, Lng: &lng, Radius: MaxSearchRadius + 1}, ErrInvalidGeoFilter, nil},
+		{"latitude out of range", PropertyFilter{Lat: &badLat, Lng: &lng, Radius: 1500}, ErrInvalidGeoFilter, nil},
+		{"invalid bbox", PropertyFilter{BBox: "1,2,3"}, ErrInvalidGeoFilter, nil},
+		{"invalid polygon", PropertyFilter{Polygon: "{}"}, ErrInvalidGeoFilter, nil},
+		{"distance without radius", PropertyFilter{Sort: "distance"}, ErrInvalidSort, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, statements := dryRunDB(t)
+			withCount(t, db, 1)
+			s := NewPropertyService(db, NewGeocodingService(db))
+
+			_, err := s.ListProperties(tt.filter, "sr", Actor{Role: RoleAgent})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			selected := (*statements)[len(*statements)-1]
+			for _, want := range tt.want {
+				if !strings.Contains(selected, want) {
+					t.Errorf("statement lacks %q: %s", want, selected)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdatePropertyVersion(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	property := createTestProperty(t, db, models.Sale, user.ID)
+	if property.Version != 1 {
+		t.Fatalf("new property has version %d", property.Version)
+	}
+
+	first := *property
+	first.Status = models.New
+	if err := s.UpdateProperty(&first, 1, agent); err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 2 {
+		t.Errorf("version after update = %d, want 2", first.Version)
+	}
+
+	// A second edit of version 1 must not overwrite the first
+	stale := *property
+	stale.Status = models.Shared
+	if err := s.UpdateProperty(&stale, 1, agent); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update: error = %v, want ErrVersionConflict", err)
+	}
+	stored, err := s.GetProperty(property.ID, "", agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.New || stored.Version != 2 {
+		t.Errorf("stored status %s, version %d after the conflict, want new, 2", stored.Status, stored.Version)
+	}
+
+	// Version 0 overwrites whatever is stored
+	if err := s.UpdateProperty(&stale, 0, agent); err != nil {
+		t.Fatal(err)
+	}
+	if stale.Version != 3 {
+		t.Errorf("version after forced update = %d, want 3", stale.Version)
+	}
+}
+
+func TestSavePropertyIgnoresProtectedFields(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	deletedBy := uint(7)
+	existing := &models.Property{ID: 1, AgentCode: "A1", PropertyCode: "P1", Version: 3}
+	property := &models.Property{
+		ID:        1,
+		Status:    models.Ready,
+		DeletedAt: gorm.DeletedAt{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		DeletedBy: &deletedBy,
+		Owner:     models.PropertyOwner{ID: 9, PropertyID: 2, ContractNumber: "C-9"},
+	}
+
+	if err := s.saveProperty(db, property, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	if property.DeletedAt.Valid || property.DeletedBy != nil {
+		t.Errorf("deletion taken from the request: %+v %v", property.DeletedAt, property.DeletedBy)
+	}
+	if property.Version != 4 {
+		t.Errorf("version = %d, want 4", property.Version)
+	}
+	var updated bool
+	for _, statement := range *statements {
+		if strings.Contains(statement, "property_owners") {
+			t.Errorf("owner written: %s", statement)
+		}
+		if strings.HasPrefix(statement, `UPDATE "properties"`) {
+			updated = true
+			set := statement[:strings.Index(statement, "WHERE")]
+			for _, column := range []string{"deleted_at", "deleted_by", "agent_code", "lifecycle_state", "created_by"} {
+				if strings.Contains(set, `"`+column+`"`) {
+					t.Errorf("%s written: %s", column, statement)
+				}
+			}
+		}
+	}
+	if !updated {
+		t.Fatalf("no update of properties in %q", *statements)
+	}
+}
+
+func TestSavePropertyMovedLocation(t *testing.T) {
+	db, _ := dryRunDB(t)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	lat, lng := 44.8125, 20.4612
+	tests := []struct {
+		name     string
+		lat, lng float64
+		want     string
+	}{
+		{"unchanged", lat, lng, models.LocationSourceGazetteer},
+		{"moved", lat, lng + 0.001, models.LocationSourceManual},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &models.Property{ID: 1, Latitude: &lat, Longitude: &lng, LocationSource: models.LocationSourceGazetteer}
+			property := &models.Property{ID: 1, Latitude: &tt.lat, Longitude: &tt.lng, LocationSource: models.LocationSourceGazetteer}
+			if err := s.saveProperty(db, property, existing); err != nil {
+				t.Fatal(err)
+			}
+			if property.LocationSource != tt.want {
+				t.Errorf("location_source = %q, want %q", property.LocationSource, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	db, _ := dryRunDB(t)
+
+	tests := []struct {
+		name     string
+		actor    Actor
+		filtered bool
+	}{
+		{"anonymous", Actor{}, true},
+		{"viewer", Actor{UserID: 1, Role: RoleViewer}, false},
+		{"agent", Actor{UserID: 2, Role: RoleAgent}, false},
+		{"unknown role", Actor{UserID: 3, Role: "guest"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return visibleTo(tx.Model(&models.Property{}), tt.actor).Find(&[]models.Property{})
+			})
+			filtered := strings.Contains(sql, "lifecycle_state IN ('published','reserved')")
+			if filtered != tt.filtered {
+				t.Errorf("filtered = %v, want %v: %s", filtered, tt.filtered, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// backend/internal/services/rbac.go
+
+package services
+
+import "errors"
+
+var ErrForbidden = errors.New("insufficient permissions")
+
+const (
+	RoleAdmin       = "admin"
+	RoleSeniorAgent = "senior_agent"
+	RoleAgent       = "agent"
+	RoleViewer      = "viewer"
+)
+
+type Permission string
+
+const (
+	PermPropertiesRead   Permission = "properties:read"
+	PermPropertiesCreate Permission = "properties:create"
+	// Edit properties the user created or is assigned to
+	PermPropertiesEditOwn Permission = "properties:edit_own"
+	PermPropertiesEditAny Permission = "properties:edit_any"
+	PermPropertiesAssign  Permission = "properties:assign"
+	PermPropertiesExport  Permission = "properties:export"
+	PermDocumentsPrivate  Permission = "documents:read_private"
+	PermUsersManage       Permission = "users:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer: {
+		PermPropertiesRead,
+	},
+	RoleAgent: {
+		PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn,
+		PermPropertiesExport, PermDocumentsPrivate,
+	},
+	RoleSeniorAgent: {
+		PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn, PermPropertiesEditAny,
+		PermPropertiesAssign, PermPropertiesExport, PermDocumentsPrivate,
+	},
+	RoleAdmin: {
+		PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn, PermPropertiesEditAny,
+		PermPropertiesAssign, PermPropertiesExport, PermDocumentsPrivate, PermUsersManage,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor is the authenticated user a service call is made for.
+type Actor struct {
+	UserID uint
+	Role   string
+}
+
+func (a Actor) Can(permission Permission) bool {
+	return HasPermission(a.Role, permission)
+}
@@ -0,0 +1,82 @@
+// backend/internal/services/rbac_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"kuckuc/internal/models"
+)
+
+func TestHasPermission(t *testing.T) {
+	all := []Permission{
+		PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn, PermPropertiesEditAny,
+		PermPropertiesAssign, PermPropertiesExport, PermDocumentsPrivate, PermUsersManage,
+	}
+	granted := map[string][]Permission{
+		RoleViewer: {PermPropertiesRead},
+		RoleAgent: {PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn,
+			PermPropertiesExport, PermDocumentsPrivate},
+		RoleSeniorAgent: {PermPropertiesRead, PermPropertiesCreate, PermPropertiesEditOwn, PermPropertiesEditAny,
+			PermPropertiesAssign, PermPropertiesExport, PermDocumentsPrivate},
+		RoleAdmin: all,
+		"":        nil,
+		"root":    nil,
+	}
+
+	for role, permissions := range granted {
+		want := make(map[Permission]bool)
+		for _, p := range permissions {
+			want[p] = true
+		}
+		for _, p := range all {
+			if got := HasPermission(role, p); got != want[p] {
+				t.Errorf("HasPermission(%q, %s) = %v, want %v", role, p, got, want[p])
+			}
+			if got := (Actor{UserID: 1, Role: role}).Can(p); got != want[p] {
+				t.Errorf("Actor{Role: %q}.Can(%s) = %v, want %v", role, p, got, want[p])
+			}
+		}
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	for _, role := range []string{RoleAdmin, RoleSeniorAgent, RoleAgent, RoleViewer} {
+		if !ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false", role)
+		}
+	}
+	for _, role := range []string{"", "Admin", "superuser"} {
+		if ValidRole(role) {
+			t.Errorf("ValidRole(%q) = true", role)
+		}
+	}
+}
+
+func TestPropertyPermissions(t *testing.T) {
+	db, _ := dryRunDB(t)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	viewer := Actor{UserID: 1, Role: RoleViewer}
+	agent := Actor{UserID: 2, Role: RoleAgent}
+	senior := Actor{UserID: 3, Role: RoleSeniorAgent}
+
+	if err := s.CreateProperty(&models.Property{}, viewer); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer creating a property: error = %v, want ErrForbidden", err)
+	}
+	if err := s.AssignProperty(1, 2, agent); !errors.Is(err, ErrForbidden) {
+		t.Errorf("agent assigning a property: error = %v, want ErrForbidden", err)
+	}
+
+	// The dry run finds a property that nobody created or is assigned to
+	if err := s.AuthorizeEdit(viewer, 1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer editing a property: error = %v, want ErrForbidden", err)
+	}
+	if err := s.AuthorizeEdit(agent, 1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("agent editing another agent's property: error = %v, want ErrForbidden", err)
+	}
+	if err := s.AuthorizeEdit(senior, 1); err != nil {
+		t.Errorf("senior agent editing any property: error = %v", err)
+	}
+}
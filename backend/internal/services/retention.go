@@ -0,0 +1,289 @@
+// backend/internal/services/retention.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
+)
+
+// DefaultRetention is how long deleted properties and documents are kept
+// when DELETED_RETENTION_DAYS is not set.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// AuditLog.Action and AuditLog.EntityType values
+const (
+	AuditPurge          = "purge"
+	AuditEntityProperty = "property"
+	AuditEntityDocument = "document"
+)
+
+// RetentionFromEnv returns the retention period in DELETED_RETENTION_DAYS,
+// or DefaultRetention if it is not set.
+func RetentionFromEnv() (time.Duration, error) {
+	value := os.Getenv("DELETED_RETENTION_DAYS")
+	if value == "" {
+		return DefaultRetention, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return 0, errors.New("DELETED_RETENTION_DAYS must be a whole number of days, at least 1")
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// RetentionService purges deleted properties and documents, with their
+// files, once they have been deleted for longer than the retention period.
+// Every purge is written to the audit log.
+type RetentionService struct {
+	db        *gorm.DB
+	files     *FileService
+	retention time.Duration
+}
+
+func NewRetentionService(db *gorm.DB, files *FileService, retention time.Duration) *RetentionService {
+	return &RetentionService{db: db, files: files, retention: retention}
+}
+
+// PurgeAt is when something deleted at deletedAt is purged.
+func (s *RetentionService) PurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(s.retention)
+}
+
+// PurgeResult counts what a purge removed.
+type PurgeResult struct {
+	Properties int `json:"properties"`
+	Documents  int `json:"documents"`
+}
+
+// Run purges every interval until ctx is done, starting right away.
+func (s *RetentionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := s.Purge(time.Now())
+		if err != nil {
+			log.Printf("Failed to purge deleted properties: %v", err)
+		}
+		if result.Properties > 0 || result.Documents > 0 {
+			log.Printf("Purged %d deleted properties and %d deleted documents", result.Properties, result.Documents)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes the properties and documents deleted before now minus the
+// retention period. Each one is purged in its own transaction, so a failure
+// only keeps that one for the next run; the errors are joined.
+func (s *RetentionService) Purge(now time.Time) (PurgeResult, error) {
+	cutoff := now.Add(-s.retention)
+	var result PurgeResult
+	var errs []error
+
+	var propertyIDs []uint
+	if err := s.db.Unscoped().Model(&models.Property{}).
+		Where("deleted_at < ?", cutoff).Order("id").Pluck("id", &propertyIDs).Error; err != nil {
+		return result, err
+	}
+	for _, id := range propertyIDs {
+		purged, err := s.purgeProperty(id, cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("property %d: %w", id, err))
+		} else if purged {
+			result.Properties++
+		}
+	}
+
+	var documentIDs []uint
+	if err := s.db.Unscoped().Model(&models.Document{}).
+		Where("deleted_at < ?", cutoff).Order("id").Pluck("id", &documentIDs).Error; err != nil {
+		return result, errors.Join(append(errs, err)...)
+	}
+	for _, id := range documentIDs {
+		purged, err := s.purgeDocument(id, cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("document %d: %w", id, err))
+		} else if purged {
+			result.Documents++
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// purgeProperty removes a property with everything that belongs to it. It
+// reports false if the property was restored in the meantime. The files go
+// last, so the rows are only removed if the files are gone too.
+func (s *RetentionService) purgeProperty(id uint, cutoff time.Time) (bool, error) {
+	purged := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+
+		var property models.Property
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at < ?", cutoff).First(&property, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var documents []models.Document
+		if err := tx.Preload("Variants").Where("property_id = ?", id).Find(&documents).Error; err != nil {
+			return err
+		}
+
+		// Snapshots and document variants are removed with their rows
+		for _, model := range []interface{}{
+			&models.History{}, &models.PropertyDetails{}, &models.PropertyOwner{}, &models.Document{},
+		} {
+			if err := tx.Where("property_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&property).Error; err != nil {
+			return err
+		}
+
+		summaries := make([]map[string]interface{}, 0, len(documents))
+		for i := range documents {
+			summaries = append(summaries, documentSummary(&documents[i]))
+		}
+		if err := recordAudit(tx, AuditPurge, AuditEntityProperty, id, nil, map[string]interface{}{
+			"property_code": property.PropertyCode,
+			"agent_code":    property.AgentCode,
+			"deleted_at":    property.DeletedAt.Time,
+			"deleted_by":    property.DeletedBy,
+			"documents":     summaries,
+			"files":         documentFiles(documents),
+		}); err != nil {
+			return err
+		}
+
+		for i := range documents {
+			if err := s.deleteFiles(&documents[i]); err != nil {
+				return err
+			}
+		}
+		purged = true
+		return nil
+	})
+	return purged, err
+}
+
+// purgeDocument removes a deleted document and its files. It reports false
+// if the document was restored or purged with its property in the meantime.
+func (s *RetentionService) purgeDocument(id uint, cutoff time.Time) (bool, error) {
+	purged := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+
+		var document models.Document
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Variants").
+			Where("deleted_at < ?", cutoff).First(&document, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&document).Error; err != nil {
+			return err
+		}
+
+		details := documentSummary(&document)
+		details["property_id"] = document.PropertyID
+		details["deleted_at"] = document.DeletedAt.Time
+		details["deleted_by"] = document.DeletedBy
+		details["files"] = documentFiles([]models.Document{document})
+		if err := recordAudit(tx, AuditPurge, AuditEntityDocument, id, nil, details); err != nil {
+			return err
+		}
+
+		if err := s.deleteFiles(&document); err != nil {
+			return err
+		}
+		purged = true
+		return nil
+	})
+	return purged, err
+}
+
+// deleteFiles removes the file of a document and its variants. Files that
+// are already gone are skipped, so an interrupted purge can run again.
+func (s *RetentionService) deleteFiles(document *models.Document) error {
+	for _, file := range documentFiles([]models.Document{*document}) {
+		if err := s.files.DeleteFile(file); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("failed to delete file %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// documentFiles lists the files of documents and their variants.
+func documentFiles(documents []models.Document) []string {
+	var files []string
+	for _, document := range documents {
+		files = append(files, document.FilePath)
+		for _, variant := range document.Variants {
+			files = append(files, variant.FilePath)
+		}
+	}
+	return files
+}
+
+// recordAudit adds an entry to the audit log. actorID is nil for background
+// jobs.
+func recordAudit(tx *gorm.DB, action, entityType string, entityID uint, actorID *uint, details interface{}) error {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		ActorID:    actorID,
+		Details:    raw,
+	}).Error
+}
+
+// ListAuditLog returns the latest limit audit log entries, newest first.
+// Empty filters match everything.
+func (s *RetentionService) ListAuditLog(action, entityType string, entityID uint, limit int) ([]models.AuditLog, error) {
+	query := s.db.Order("created_at DESC, id DESC").Limit(limit)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID != 0 {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
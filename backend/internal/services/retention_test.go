@@ -0,0 +1,132 @@
+// backend/internal/services/retention_test.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kuckuc/internal/models"
+	"kuckuc/internal/storage"
+)
+
+func TestRetentionFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultRetention, false},
+		{"7", 7 * 24 * time.Hour, false},
+		{"0", 0, true},
+		{"-3", 0, true},
+		{"2w", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DELETED_RETENTION_DAYS", tt.value)
+		got, err := RetentionFromEnv()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("RetentionFromEnv() with %q = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+// failingStorage fails every Delete while fail is set.
+type failingStorage struct {
+	storage.Storage
+	fail bool
+}
+
+func (s *failingStorage) Delete(ctx context.Context, key string) error {
+	if s.fail {
+		return errors.New("storage unavailable")
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+func TestPurge(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &failingStorage{Storage: local, fail: true}
+	retention := NewRetentionService(db, NewFileService(store, "secret"), time.Hour)
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	property := createTestProperty(t, db, models.Sale, user.ID)
+
+	key := "document/1/contract.pdf"
+	if err := local.Put(context.Background(), key, strings.NewReader("%PDF-1.4"), 8, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	document := models.Document{PropertyID: property.ID, FileType: "document", FilePath: key}
+	if err := db.Create(&document).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteProperty(property.ID, 0, agent); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is old enough yet
+	if result, err := retention.Purge(time.Now()); err != nil || result.Properties != 0 {
+		t.Fatalf("early purge = %+v, %v", result, err)
+	}
+
+	// The rows stay as long as the files can't be deleted
+	later := time.Now().Add(2 * time.Hour)
+	if _, err := retention.Purge(later); err == nil {
+		t.Fatal("purge succeeded while the storage failed")
+	}
+	var count int64
+	db.Unscoped().Model(&models.Document{}).Where("id = ?", document.ID).Count(&count)
+	if count != 1 {
+		t.Error("document row removed although its file was not")
+	}
+	db.Model(&models.AuditLog{}).Count(&count)
+	if count != 0 {
+		t.Errorf("failed purge wrote %d audit entries", count)
+	}
+
+	store.fail = false
+	result, err := retention.Purge(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Properties != 1 {
+		t.Errorf("purged %d properties, want 1", result.Properties)
+	}
+	if _, err := local.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("file after the purge: error = %v, want ErrNotFound", err)
+	}
+	db.Unscoped().Model(&models.Property{}).Where("id = ?", property.ID).Count(&count)
+	if count != 0 {
+		t.Error("property row left after the purge")
+	}
+
+	entries, err := retention.ListAuditLog(AuditPurge, AuditEntityProperty, property.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(entries))
+	}
+	var details struct {
+		PropertyCode string   `json:"property_code"`
+		Files        []string `json:"files"`
+	}
+	if err := json.Unmarshal(entries[0].Details, &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.PropertyCode != property.PropertyCode || len(details.Files) != 1 || details.Files[0] != key {
+		t.Errorf("audit details = %s", entries[0].Details)
+	}
+}
@@ -0,0 +1,237 @@
+// backend/internal/services/sessions.go
+
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; session revoked")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+const (
+	// AccessTokenTTL is kept short; clients renew access tokens with Refresh.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session stays alive without being used.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// TokenPair is returned by Login and Refresh. The access token keeps the
+// "token" JSON key of the original login response.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// ClientInfo describes where a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// startSession creates an auth session with its first refresh token.
+func (s *AuthService) startSession(user *models.User, client ClientInfo) (*TokenPair, error) {
+	now := time.Now()
+	session := models.AuthSession{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+
+	var pair *TokenPair
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		pair, err = s.issueTokens(tx, user, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh rotates a refresh token: the presented token is spent and a new
+// pair is issued for the same session. Presenting a spent token means it
+// leaked, so the whole session with every token derived from it is revoked.
+func (s *AuthService) Refresh(refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		var session models.AuthSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", token.SessionID).Error; err != nil {
+			return err
+		}
+		if session.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil {
+			log.Printf("Refresh token reuse detected, revoking session %s of user %d", session.ID, session.UserID)
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+		if now.After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.DisabledAt != nil {
+			return ErrUserDisabled
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   now.Add(RefreshTokenTTL),
+		}).Error; err != nil {
+			return err
+		}
+
+		pair, err = s.issueTokens(tx, &user, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// Logout revokes the session the refresh token belongs to.
+func (s *AuthService) Logout(refreshToken string) error {
+	var token models.RefreshToken
+	err := s.db.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&models.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", token.SessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// LogoutAll revokes every session of the user, signing them out everywhere.
+func (s *AuthService) LogoutAll(userID uint) error {
+	return revokeSessions(s.db, userID)
+}
+
+// ListSessions returns the active sessions of a user, most recent first.
+func (s *AuthService) ListSessions(userID uint) ([]models.AuthSession, error) {
+	var sessions []models.AuthSession
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// checkSession fails unless the session exists, belongs to the user and is
+// neither revoked nor expired.
+func (s *AuthService) checkSession(sessionID string, userID uint) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrSessionRevoked
+	}
+	var count int64
+	err := s.db.Model(&models.AuthSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// issueTokens stores a new refresh token for the session and signs an
+// access token to go with it.
+func (s *AuthService) issueTokens(tx *gorm.DB, user *models.User, sessionID string) (*TokenPair, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := s.accessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: accessToken, ExpiresAt: expiresAt, RefreshToken: refreshToken}, nil
+}
+
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.AuthSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// randomToken returns 256 random bits, URL-safe encoded.
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is how opaque tokens are stored, so a database leak does not
+// reveal usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,72 @@
+// backend/internal/services/sessions_test.go
+
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRefreshTokenReuse(t *testing.T) {
+	db := testDB(t)
+	s := NewAuthService(db, testKeySet(t), nil)
+
+	user := createTestUser(t, s, "agent@kuckuc.rs", RoleAgent)
+	pair, err := s.Login(user.Email, testPassword, ClientInfo{UserAgent: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Presenting the spent token again revokes the session, including the
+	// token it was rotated into
+	if _, err := s.Refresh(pair.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.Refresh(rotated.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("token of the revoked session: error = %v, want ErrSessionRevoked", err)
+	}
+	sessions, err := s.ListSessions(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions still active after reuse", len(sessions))
+	}
+
+	if _, err := s.Refresh("not-a-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	db := testDB(t)
+	s := NewAuthService(db, testKeySet(t), nil)
+
+	user := createTestUser(t, s, "agent@kuckuc.rs", RoleAgent)
+	first, err := s.Login(user.Email, testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Login(user.Email, testPassword, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Logout(first.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(first.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refresh after logout: error = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Refresh(second.RefreshToken); err != nil {
+		t.Errorf("other session was signed out: %v", err)
+	}
+}
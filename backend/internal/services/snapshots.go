@@ -0,0 +1,294 @@
+// backend/internal/services/snapshots.go
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"kuckuc/internal/models"
+)
+
+// ErrSnapshotNotFound is returned for history entries of another property
+// and for entries written before snapshots were kept.
+var ErrSnapshotNotFound = errors.New("no snapshot of the property at this history entry")
+
+// ownerFields are the PropertyOwner fields kept in snapshots and restored.
+var ownerFields = []string{
+	"properties_count", "contract_status", "contract_number", "contract_end_date", "contract_file_path",
+}
+
+// RestorePreview is what restoring a property to a history entry would do.
+type RestorePreview struct {
+	HistoryID uint             `json:"history_id"`
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Property  *models.Property `json:"property"`
+	// Changes go from the current property to the snapshot
+	Changes []FieldChange `json:"changes"`
+}
+
+// snapshotProperty stores the property with its details and owner as they
+// are after the history entry historyID, deleted or not. Documents and
+// history are left out; they are never restored.
+func snapshotProperty(tx *gorm.DB, propertyID, historyID uint) error {
+	var property models.Property
+	if err := tx.Unscoped().Preload("Details").Preload("Owner").First(&property, propertyID).Error; err != nil {
+		return err
+	}
+	property.Documents, property.History = nil, nil
+
+	data, err := json.Marshal(property)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.PropertySnapshot{
+		PropertyID: propertyID,
+		HistoryID:  historyID,
+		Version:    property.Version,
+		Data:       data,
+	}).Error
+}
+
+// loadSnapshot returns the snapshot of the property at the history entry
+// historyID and the property it holds.
+func loadSnapshot(tx *gorm.DB, propertyID, historyID uint) (*models.PropertySnapshot, *models.Property, error) {
+	var snapshot models.PropertySnapshot
+	err := tx.Where("property_id = ? AND history_id = ?", propertyID, historyID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var property models.Property
+	if err := json.Unmarshal(snapshot.Data, &property); err != nil {
+		return nil, nil, err
+	}
+	return &snapshot, &property, nil
+}
+
+// PreviewRestore returns the property as it was after the history entry
+// historyID, with the changes restoring it would make. The owner and its
+// changes are left out unless actor may see the owner contract.
+func (s *PropertyService) PreviewRestore(id, historyID uint, actor Actor) (*RestorePreview, error) {
+	var current models.Property
+	if err := s.db.Preload("Details").Preload("Owner").First(&current, id).Error; err != nil {
+		return nil, err
+	}
+	snapshot, property, err := loadSnapshot(s.db, id, historyID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := restoreChanges(&current, property)
+	if err != nil {
+		return nil, err
+	}
+	if !canSeeOwner(actor) {
+		property.Owner = models.PropertyOwner{}
+		changes = withoutOwnerChanges(changes)
+	}
+	return &RestorePreview{
+		HistoryID: historyID,
+		Version:   snapshot.Version,
+		CreatedAt: snapshot.CreatedAt,
+		Property:  property,
+		Changes:   changes,
+	}, nil
+}
+
+// RestoreProperty sets the fields, details and owner of a property back to
+// what they were after the history entry historyID, and records that as a
+// new history entry, so the restore can be undone the same way. The
+// lifecycle state, assignment and documents are not restored. version is
+// only checked if it is not 0. A restore that changes nothing saves nothing.
+func (s *PropertyService) RestoreProperty(id, historyID uint, version int, actor Actor) (*models.Property, error) {
+	var property models.Property
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.lockForEdit(tx, actor, id, version)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("property_id = ?", id).Find(&existing.Details).Error; err != nil {
+			return err
+		}
+		var owner models.PropertyOwner
+		if err := tx.Where("property_id = ?", id).Order("id").Limit(1).Find(&owner).Error; err != nil {
+			return err
+		}
+		existing.Owner = owner
+
+		_, restored, err := loadSnapshot(tx, id, historyID)
+		if err != nil {
+			return err
+		}
+
+		changes, err := restoreChanges(existing, restored)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return tx.Preload("Details").Preload("Owner").First(&property, id).Error
+		}
+
+		before, err := propertyDocument(existing)
+		if err != nil {
+			return err
+		}
+		after, err := propertyDocument(restored)
+		if err != nil {
+			return err
+		}
+		updated, err := propertyFromDocument(existing, after)
+		if err != nil {
+			return err
+		}
+		updated.Owner = models.PropertyOwner{}
+		if err := s.saveProperty(tx, updated, existing); err != nil {
+			return err
+		}
+		removed := removedLanguages(before, after)
+		if len(removed) > 0 {
+			if err := tx.Where("property_id = ? AND language IN ?", id, removed).
+				Delete(&models.PropertyDetails{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := restoreOwner(tx, id, owner, restored.Owner); err != nil {
+			return err
+		}
+
+		// Compared with what was stored, so the history also shows what
+		// geocoding changed
+		saved, err := s.loadDocument(tx, updated)
+		if err != nil {
+			return err
+		}
+		changes = diffDocuments(before, saved)
+		ownerChanges, err := diffOwners(owner, restored.Owner)
+		if err != nil {
+			return err
+		}
+		if err := recordHistory(tx, id, HistoryRestore, actor, historyDetails{
+			Action:       "property_restored",
+			RestoredFrom: historyID,
+			Changes:      append(changes, ownerChanges...),
+		}); err != nil {
+			return err
+		}
+
+		return tx.Preload("Details").Preload("Owner").First(&property, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &property, nil
+}
+
+// canSeeOwner reports whether actor may see the owner contract of a
+// property, which is as private as the contract document itself.
+func canSeeOwner(actor Actor) bool {
+	return actor.Can(PermDocumentsPrivate)
+}
+
+// withoutOwnerChanges returns changes without the owner.* ones.
+func withoutOwnerChanges(changes []FieldChange) []FieldChange {
+	kept := make([]FieldChange, 0, len(changes))
+	for _, change := range changes {
+		if !strings.HasPrefix(change.Field, "owner.") {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// restoreOwner sets the owner of the property to the restored one. An owner
+// missing from the snapshot is removed.
+func restoreOwner(tx *gorm.DB, propertyID uint, current, restored models.PropertyOwner) error {
+	switch {
+	case restored.ID == 0 && current.ID == 0:
+		return nil
+	case restored.ID == 0:
+		return tx.Where("property_id = ?", propertyID).Delete(&models.PropertyOwner{}).Error
+	case current.ID == 0:
+		restored.ID = 0
+		restored.PropertyID = propertyID
+		return tx.Create(&restored).Error
+	}
+	return tx.Model(&models.PropertyOwner{}).Where("id = ?", current.ID).Updates(map[string]interface{}{
+		"properties_count":   restored.PropertiesCount,
+		"contract_status":    restored.ContractStatus,
+		"contract_number":    restored.ContractNumber,
+		"contract_end_date":  restored.ContractEndDate,
+		"contract_file_path": restored.ContractFilePath,
+	}).Error
+}
+
+// restoreChanges returns the changes from current to restored, fields and
+// details first, then the owner.
+func restoreChanges(current, restored *models.Property) ([]FieldChange, error) {
+	before, err := propertyDocument(current)
+	if err != nil {
+		return nil, err
+	}
+	after, err := propertyDocument(restored)
+	if err != nil {
+		return nil, err
+	}
+	ownerChanges, err := diffOwners(current.Owner, restored.Owner)
+	if err != nil {
+		return nil, err
+	}
+	return append(diffDocuments(before, after), ownerChanges...), nil
+}
+
+// diffOwners returns the changed ownerFields as "owner.<field>". A missing
+// owner has nil values.
+func diffOwners(before, after models.PropertyOwner) ([]FieldChange, error) {
+	old, err := ownerDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := ownerDocument(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := append([]string(nil), ownerFields...)
+	sort.Strings(fields)
+	var changes []FieldChange
+	for _, field := range fields {
+		if !reflect.DeepEqual(old[field], updated[field]) {
+			changes = append(changes, FieldChange{Field: "owner." + field, Before: old[field], After: updated[field]})
+		}
+	}
+	return changes, nil
+}
+
+// ownerDocument returns the ownerFields of owner, or nil if there is none.
+func ownerDocument(owner models.PropertyOwner) (map[string]interface{}, error) {
+	if owner.ID == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(owner)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+	document := make(map[string]interface{}, len(ownerFields))
+	for _, field := range ownerFields {
+		document[field] = all[field]
+	}
+	return document, nil
+}
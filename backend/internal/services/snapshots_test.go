@@ -0,0 +1,215 @@
+// backend/internal/services/snapshots_test.go
+
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"kuckuc/internal/models"
+)
+
+func TestDiffOwners(t *testing.T) {
+	owner := models.PropertyOwner{
+		ID:               3,
+		PropertyID:       1,
+		PropertiesCount:  2,
+		ContractStatus:   "active",
+		ContractNumber:   "C-17",
+		ContractEndDate:  time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC),
+		ContractFilePath: "contracts/c-17.pdf",
+	}
+	renewed := owner
+	renewed.ID = 4
+	renewed.ContractNumber = "C-18"
+	renewed.UpdatedAt = time.Now()
+
+	tests := []struct {
+		name          string
+		before, after models.PropertyOwner
+		want          string
+	}{
+		{"no owner", models.PropertyOwner{}, models.PropertyOwner{}, `null`},
+		{"same values", owner, renewed, `[{"field":"owner.contract_number","before":"C-17","after":"C-18"}]`},
+		{"owner added", models.PropertyOwner{}, owner, `[` +
+			`{"field":"owner.contract_end_date","before":null,"after":"2027-03-01T00:00:00Z"},` +
+			`{"field":"owner.contract_file_path","before":null,"after":"contracts/c-17.pdf"},` +
+			`{"field":"owner.contract_number","before":null,"after":"C-17"},` +
+			`{"field":"owner.contract_status","before":null,"after":"active"},` +
+			`{"field":"owner.properties_count","before":null,"after":2}]`},
+		{"owner removed", owner, models.PropertyOwner{}, `[` +
+			`{"field":"owner.contract_end_date","before":"2027-03-01T00:00:00Z","after":null},` +
+			`{"field":"owner.contract_file_path","before":"contracts/c-17.pdf","after":null},` +
+			`{"field":"owner.contract_number","before":"C-17","after":null},` +
+			`{"field":"owner.contract_status","before":"active","after":null},` +
+			`{"field":"owner.properties_count","before":2,"after":null}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := diffOwners(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("diffOwners() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreProperty(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	created := createTestProperty(t, db, models.Sale, user.ID)
+
+	for _, status := range []models.PropertyStatus{models.New, models.Shared} {
+		property := *created
+		property.Status = status
+		if err := s.UpdateProperty(&property, 0, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := s.GetPropertyHistory(created.ID, HistoryFilter{}, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d history entries, want 2", len(history))
+	}
+	// Newest first, so this is the entry that set the status to new
+	first := history[1].ID
+
+	preview, err := s.PreviewRestore(created.ID, first, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Property.Status != models.New || len(preview.Changes) != 1 ||
+		preview.Changes[0].Field != "status" || preview.Changes[0].After != string(models.New) {
+		t.Errorf("preview = %+v, changes %+v", preview.Property, preview.Changes)
+	}
+
+	restored, err := s.RestoreProperty(created.ID, first, 3, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Status != models.New || restored.Version != 4 {
+		t.Errorf("restored status %s, version %d, want new, 4", restored.Status, restored.Version)
+	}
+
+	// Restoring again changes nothing and records nothing
+	if _, err := s.RestoreProperty(created.ID, first, 0, agent); err != nil {
+		t.Fatal(err)
+	}
+	history, err = s.GetPropertyHistory(created.ID, HistoryFilter{}, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].ActionType != HistoryRestore {
+		t.Errorf("history after restoring = %+v, want one restore entry on top", history)
+	}
+
+	if _, err := s.PreviewRestore(created.ID, first+1000, agent); err != ErrSnapshotNotFound {
+		t.Errorf("unknown history entry: error = %v, want ErrSnapshotNotFound", err)
+	}
+}
+
+func TestRedactOwnerChanges(t *testing.T) {
+	if !canSeeOwner(Actor{UserID: 1, Role: RoleAgent}) || canSeeOwner(Actor{UserID: 2, Role: RoleViewer}) || canSeeOwner(Actor{}) {
+		t.Fatal("only roles with private documents may see the owner")
+	}
+
+	history := []models.History{
+		{ID: 1, Details: json.RawMessage(`{"action":"property_imported","changes":[` +
+			`{"field":"price","language":"sr","before":1,"after":2},` +
+			`{"field":"owner.contract_number","before":"C-1","after":"C-2"}]}`)},
+		{ID: 2, Details: json.RawMessage(`{"action":"property_state_changed","from":"draft","to":"published"}`)},
+		{ID: 3, Details: json.RawMessage(`"legacy"`)},
+	}
+	if err := redactOwnerChanges(history); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"action":"property_imported","changes":[{"field":"price","language":"sr","before":1,"after":2}]}`,
+		`{"action":"property_state_changed","from":"draft","to":"published"}`,
+		`"legacy"`,
+	}
+	for i, entry := range history {
+		if string(entry.Details) != want[i] {
+			t.Errorf("entry %d = %s, want %s", entry.ID, entry.Details, want[i])
+		}
+	}
+}
+
+func TestRestoreOwnerVisibility(t *testing.T) {
+	db := testDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	s := NewPropertyService(db, NewGeocodingService(db))
+
+	user := createTestUser(t, auth, "agent@kuckuc.rs", RoleAgent)
+	agent := Actor{UserID: user.ID, Role: RoleAgent}
+	viewer := Actor{UserID: createTestUser(t, auth, "viewer@kuckuc.rs", RoleViewer).ID, Role: RoleViewer}
+	created := createTestProperty(t, db, models.Sale, user.ID)
+
+	owner := models.PropertyOwner{PropertyID: created.ID, PropertiesCount: 1, ContractNumber: "C-17"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	property := *created
+	property.Status = models.New
+	if err := s.UpdateProperty(&property, 0, agent); err != nil {
+		t.Fatal(err)
+	}
+	history, err := s.GetPropertyHistory(created.ID, HistoryFilter{}, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withOwner := history[0].ID
+	if err := db.Delete(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := s.PreviewRestore(created.ID, withOwner, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Property.Owner.ContractNumber != "" {
+		t.Errorf("viewer sees the owner in the snapshot: %+v", preview.Property.Owner)
+	}
+	for _, change := range preview.Changes {
+		if strings.HasPrefix(change.Field, "owner.") {
+			t.Errorf("viewer sees owner change %+v", change)
+		}
+	}
+	if preview, err := s.PreviewRestore(created.ID, withOwner, agent); err != nil || preview.Property.Owner.ContractNumber != "C-17" {
+		t.Errorf("agent's preview = %+v, %v, want the owner", preview, err)
+	}
+
+	if _, err := s.RestoreProperty(created.ID, withOwner, 0, agent); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		actor     Actor
+		wantOwner bool
+	}{{agent, true}, {viewer, false}} {
+		history, err := s.GetPropertyHistory(created.ID, HistoryFilter{Action: HistoryRestore}, tt.actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 {
+			t.Fatalf("got %d restore entries, want 1", len(history))
+		}
+		if got := strings.Contains(string(history[0].Details), "owner.contract_number"); got != tt.wantOwner {
+			t.Errorf("%s sees owner changes: %v, want %v: %s", tt.actor.Role, got, tt.wantOwner, history[0].Details)
+		}
+	}
+}
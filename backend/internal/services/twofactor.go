@@ -0,0 +1,503 @@
+// backend/internal/services/twofactor.go
+
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication is not set up")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for this account")
+)
+
+const (
+	// ChallengeTTL is how long the second login step may take.
+	ChallengeTTL = 5 * time.Minute
+	// RecoveryCodeCount codes are issued on enrolment and regeneration.
+	RecoveryCodeCount = 10
+
+	// RFC 6238 defaults, the only parameters most authenticator apps support
+	totpPeriod = 30
+	totpDigits = 6
+	// Codes of the neighbouring time steps are accepted for clock drift
+	totpSkew = 1
+
+	challengeLogin  = "2fa"
+	challengeEnroll = "2fa_enroll"
+
+	LoginFailedTwoFactor = "wrong_2fa_code"
+)
+
+// LoginResult is returned by Login and VerifyLogin. Either the tokens are
+// set, or ChallengeToken is set and the login continues with VerifyLogin.
+// EnrollmentRequired means the account must set up two-factor
+// authentication first, see EnrollChallenge.
+type LoginResult struct {
+	*TokenPair
+	TwoFactorRequired  bool   `json:"two_factor_required,omitempty"`
+	EnrollmentRequired bool   `json:"enrollment_required,omitempty"`
+	ChallengeToken     string `json:"challenge_token,omitempty"`
+	// RecoveryCodes are returned once, when enrolment completes a login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// TwoFactorSetup is shown to the user to add the account to an
+// authenticator app.
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int64      `json:"recovery_codes_left"`
+}
+
+// challengeClaims identify a user who passed the password step. They are
+// issued for their own audience and have no session, so ValidateToken never
+// accepts them as access tokens.
+type challengeClaims struct {
+	UserID  uint   `json:"user_id"`
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+func (s *AuthService) challengeAudience() string {
+	return s.audience + "/2fa"
+}
+
+// completeLogin starts a session for a user who passed the password step,
+// unless a second factor is needed.
+func (s *AuthService) completeLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	purpose := ""
+	switch {
+	case user.TOTPEnabledAt != nil:
+		purpose = challengeLogin
+	case user.TwoFactorRequired:
+		purpose = challengeEnroll
+	}
+
+	if purpose == "" {
+		pair, err := s.startSession(user, client)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{TokenPair: pair}, nil
+	}
+
+	now := time.Now()
+	claims := &challengeClaims{
+		UserID:           user.ID,
+		Email:            user.Email,
+		Purpose:          purpose,
+		RegisteredClaims: s.registeredClaims(user.ID, s.challengeAudience(), now, now.Add(ChallengeTTL)),
+	}
+	token, err := s.keys.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: purpose == challengeEnroll,
+		ChallengeToken:     token,
+	}, nil
+}
+
+func (s *AuthService) parseChallenge(challengeToken string) (*challengeClaims, error) {
+	claims := &challengeClaims{}
+	if err := s.parseToken(challengeToken, s.challengeAudience(), claims); err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if claims.Purpose != challengeLogin && claims.Purpose != challengeEnroll {
+		return nil, ErrInvalidChallenge
+	}
+	return claims, nil
+}
+
+// VerifyLogin completes a login with a TOTP or recovery code. For accounts
+// that must enrol, the code confirms the secret from EnrollChallenge, which
+// enables two-factor authentication and returns the recovery codes.
+func (s *AuthService) VerifyLogin(challengeToken, code string, client ClientInfo) (*LoginResult, error) {
+	claims, err := s.parseChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.Check(claims.Email, client.IPAddress); err != nil {
+			s.recordFailedLogin(claims.Email, client, LoginFailedThrottled)
+			return nil, err
+		}
+	}
+
+	var user models.User
+	var recoveryCodes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, claims.UserID).Error; err != nil {
+			return ErrInvalidChallenge
+		}
+		if user.DisabledAt != nil {
+			return ErrInvalidCredentials
+		}
+		// Rolled back with the transaction if the code is wrong, so only a
+		// completed login uses up the challenge
+		if err := useChallenge(tx, claims); err != nil {
+			return err
+		}
+
+		if claims.Purpose == challengeEnroll {
+			if user.TOTPEnabledAt != nil || user.TOTPSecret == "" {
+				return ErrInvalidChallenge
+			}
+			if !s.checkTOTP(tx, &user, code) {
+				return ErrInvalidTwoFactorCode
+			}
+			var err error
+			recoveryCodes, err = s.enableTwoFactor(tx, &user)
+			return err
+		}
+
+		if user.TOTPEnabledAt == nil {
+			return ErrInvalidChallenge
+		}
+		ok, err := s.checkSecondFactor(tx, &user, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		s.recordFailedLogin(claims.Email, client, LoginFailedTwoFactor)
+		if s.limiter != nil {
+			if err := s.limiter.Fail(claims.Email, client.IPAddress); err != nil {
+				log.Printf("Failed to count login failure: %v", err)
+			}
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.Succeed(claims.Email); err != nil {
+			log.Printf("Failed to reset login failures of user %d: %v", user.ID, err)
+		}
+	}
+
+	pair, err := s.startSession(&user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: pair, RecoveryCodes: recoveryCodes}, nil
+}
+
+// useChallenge records the ID of a challenge, or returns ErrInvalidChallenge
+// if it was used before.
+func useChallenge(tx *gorm.DB, claims *challengeClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return ErrInvalidChallenge
+	}
+	if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.UsedChallenge{}).Error; err != nil {
+		return err
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UsedChallenge{ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// EnrollChallenge generates a TOTP secret for an account that must set up
+// two-factor authentication before its first login completes.
+func (s *AuthService) EnrollChallenge(challengeToken string) (*TwoFactorSetup, error) {
+	claims, err := s.parseChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != challengeEnroll {
+		return nil, ErrInvalidChallenge
+	}
+	return s.SetupTwoFactor(claims.UserID)
+}
+
+// SetupTwoFactor generates a new TOTP secret. It takes effect once
+// EnableTwoFactor confirms a code from it.
+func (s *AuthService) SetupTwoFactor(userID uint) (*TwoFactorSetup, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	user, err := s.updateUser(userID, func(tx *gorm.DB, user *models.User) error {
+		if user.TOTPEnabledAt != nil {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		user.TOTPSecret = secret
+		user.TOTPLastStep = 0
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: s.provisioningURI(user.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor confirms the secret from SetupTwoFactor with a code and
+// returns the recovery codes, which are shown only this once.
+func (s *AuthService) EnableTwoFactor(userID uint, code string) ([]string, error) {
+	var recoveryCodes []string
+	_, err := s.updateUser(userID, func(tx *gorm.DB, user *models.User) error {
+		if user.TOTPEnabledAt != nil {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTwoFactorNotSetUp
+		}
+		if !s.checkTOTP(tx, user, code) {
+			return ErrInvalidTwoFactorCode
+		}
+		var err error
+		recoveryCodes, err = s.enableTwoFactor(tx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a
+// current TOTP code.
+func (s *AuthService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var recoveryCodes []string
+	_, err := s.updateUser(userID, func(tx *gorm.DB, user *models.User) error {
+		if user.TOTPEnabledAt == nil {
+			return ErrTwoFactorNotSetUp
+		}
+		if !s.checkTOTP(tx, user, code) {
+			return ErrInvalidTwoFactorCode
+		}
+		var err error
+		recoveryCodes, err = s.newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a TOTP
+// or recovery code. Accounts where an admin requires it cannot do this.
+func (s *AuthService) DisableTwoFactor(userID uint, code string) error {
+	_, err := s.updateUser(userID, func(tx *gorm.DB, user *models.User) error {
+		if user.TOTPEnabledAt == nil {
+			return ErrTwoFactorNotSetUp
+		}
+		if user.TwoFactorRequired {
+			return ErrTwoFactorRequired
+		}
+		ok, err := s.checkSecondFactor(tx, user, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return resetTwoFactor(tx, user)
+	})
+	return err
+}
+
+// ResetTwoFactor lets an admin remove the second factor of a user who lost
+// their device. If two-factor authentication is required, the user enrols
+// again on the next login.
+func (s *AuthService) ResetTwoFactor(userID uint) (*models.User, error) {
+	return s.updateUser(userID, func(tx *gorm.DB, user *models.User) error {
+		return resetTwoFactor(tx, user)
+	})
+}
+
+// SetTwoFactorRequired makes two-factor authentication mandatory for a user.
+func (s *AuthService) SetTwoFactorRequired(userID uint, required bool) (*models.User, error) {
+	return s.UpdateUser(userID, UserChanges{TwoFactorRequired: &required})
+}
+
+func (s *AuthService) GetTwoFactorStatus(userID uint) (*TwoFactorStatus, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	status := &TwoFactorStatus{
+		Enabled:   user.TOTPEnabledAt != nil,
+		EnabledAt: user.TOTPEnabledAt,
+		Required:  user.TwoFactorRequired,
+	}
+	if err := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&status.RecoveryCodesLeft).Error; err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *AuthService) enableTwoFactor(tx *gorm.DB, user *models.User) ([]string, error) {
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	if err := tx.Model(user).Update("totp_enabled_at", now).Error; err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(tx, user.ID)
+}
+
+func resetTwoFactor(tx *gorm.DB, user *models.User) error {
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+}
+
+// checkSecondFactor accepts a TOTP code or an unused recovery code.
+func (s *AuthService) checkSecondFactor(tx *gorm.DB, user *models.User, code string) (bool, error) {
+	if s.checkTOTP(tx, user, code) {
+		return true, nil
+	}
+	return useRecoveryCode(tx, user.ID, code)
+}
+
+// checkTOTP verifies a code against the user's secret. Each time step is
+// accepted once, so an observed code cannot be replayed. tx must hold a lock
+// on the user row.
+func (s *AuthService) checkTOTP(tx *gorm.DB, user *models.User, code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits || user.TOTPSecret == "" {
+		return false
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(user.TOTPSecret)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= user.TOTPLastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			user.TOTPLastStep = step
+			if err := tx.Model(user).Update("totp_last_step", step).Error; err != nil {
+				log.Printf("Failed to store TOTP step of user %d: %v", user.ID, err)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode computes the RFC 6238 code (HMAC-SHA1) for a time step.
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+func (s *AuthService) provisioningURI(email, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", s.totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(s.totpIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// newRecoveryCodes replaces the user's recovery codes with fresh ones of the
+// form XXXXX-XXXXX.
+func (s *AuthService) newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := encoding.EncodeToString(raw)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) (bool, error) {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, so codes can be typed
+// as they are read.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+// backend/internal/services/twofactor_test.go
+
+package services
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kuckuc/internal/models"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(secret, tt.time/totpPeriod); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	step := time.Now().Unix() / totpPeriod
+	current := totpCode(secret, step)
+	auth := &AuthService{}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		secret   string
+		want     bool
+	}{
+		{"current step", current, 0, encoded, true},
+		{"with spaces", current[:3] + " " + current[3:], 0, encoded, true},
+		{"previous step", totpCode(secret, step-1), 0, encoded, true},
+		{"next step", totpCode(secret, step+1), 0, encoded, true},
+		{"two steps ago", totpCode(secret, step-2), 0, encoded, false},
+		{"step already used", current, step, encoded, false},
+		{"wrong code", strings.Repeat("0", totpDigits-1) + "x", 0, encoded, false},
+		{"too short", current[:5], 0, encoded, false},
+		{"no secret", current, 0, "", false},
+		{"invalid secret", current, 0, "not base32!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, statements := dryRunDB(t)
+			user := &models.User{ID: 1, TOTPSecret: tt.secret, TOTPLastStep: tt.lastStep}
+			if got := auth.checkTOTP(db, user, tt.code); got != tt.want {
+				t.Fatalf("checkTOTP(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+			if tt.want {
+				// The step is stored so the code cannot be used again
+				if user.TOTPLastStep < step-totpSkew || len(*statements) != 1 ||
+					!strings.Contains((*statements)[0], "totp_last_step") {
+					t.Errorf("step not stored: last step %d, statements %q", user.TOTPLastStep, *statements)
+				}
+				if auth.checkTOTP(db, user, tt.code) {
+					t.Error("code accepted twice")
+				}
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := hashRecoveryCode("ABCDE12345")
+	if len(want) != 64 {
+		t.Fatalf("hash %q is not hex SHA-256", want)
+	}
+
+	tests := []struct {
+		code string
+		same bool
+	}{
+		{"ABCDE12345", true},
+		{"abcde-12345", true},
+		{" ABCDE 12345 ", true},
+		{"ABCDE-1234-5", true},
+		{"ABCDE12346", false},
+		{"ABCDE1234", false},
+	}
+	for _, tt := range tests {
+		if got := hashRecoveryCode(tt.code) == want; got != tt.same {
+			t.Errorf("hashRecoveryCode(%q) matches: %v, want %v", tt.code, got, tt.same)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	db, _ := dryRunDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+	now := time.Now()
+
+	tests := []struct {
+		name           string
+		user           models.User
+		wantPurpose    string
+		wantEnrollment bool
+	}{
+		{"enabled", models.User{ID: 7, Email: "a@kuckuc.rs", TOTPEnabledAt: &now}, challengeLogin, false},
+		{"enabled and required", models.User{ID: 7, Email: "a@kuckuc.rs", TOTPEnabledAt: &now, TwoFactorRequired: true}, challengeLogin, false},
+		{"required", models.User{ID: 8, Email: "b@kuckuc.rs", TwoFactorRequired: true}, challengeEnroll, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := auth.completeLogin(&tt.user, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.TokenPair != nil || !first.TwoFactorRequired || first.EnrollmentRequired != tt.wantEnrollment {
+				t.Fatalf("completeLogin() = %+v", first)
+			}
+
+			claims, err := auth.parseChallenge(first.ChallengeToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != tt.user.ID || claims.Purpose != tt.wantPurpose || claims.ID == "" {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			// Every challenge has its own ID, which useChallenge records
+			second, err := auth.completeLogin(&tt.user, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := auth.parseChallenge(second.ChallengeToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other.ID == claims.ID {
+				t.Error("two challenges share an ID")
+			}
+
+			// A challenge is not an access token
+			if _, err := auth.ValidateToken(first.ChallengeToken); err == nil {
+				t.Error("challenge accepted as access token")
+			}
+		})
+	}
+}
+
+func TestUseChallenge(t *testing.T) {
+	db, statements := dryRunDB(t)
+	if err := useChallenge(db, &challengeClaims{}); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("challenge without ID: %v, want %v", err, ErrInvalidChallenge)
+	}
+	if len(*statements) != 0 {
+		t.Errorf("challenge without ID was recorded: %q", *statements)
+	}
+
+	auth := NewAuthService(nil, testKeySet(t), nil)
+	claims := &challengeClaims{RegisteredClaims: auth.registeredClaims(1, auth.challengeAudience(), time.Now(), time.Now().Add(ChallengeTTL))}
+	_ = useChallenge(db, claims)
+	if len(*statements) != 1 || !strings.Contains((*statements)[0], `INSERT INTO "used_challenges"`) ||
+		!strings.Contains((*statements)[0], "ON CONFLICT DO NOTHING") {
+		t.Errorf("statements = %q, want an insert that skips used IDs", *statements)
+	}
+}
@@ -0,0 +1,258 @@
+// backend/internal/services/upload.go
+
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Upload error codes returned to clients, see FileUpload.tsx
+const (
+	UploadErrInvalidFileType   = "invalid_file_type"
+	UploadErrEmptyFile         = "empty_file"
+	UploadErrFileTooLarge      = "file_too_large"
+	UploadErrUnsupportedFormat = "unsupported_extension"
+	UploadErrContentMismatch   = "content_type_mismatch"
+	UploadErrUnsupportedImage  = "unsupported_image_format"
+	UploadErrExecutableContent = "executable_content"
+	UploadErrUnreadableFile    = "unreadable_file"
+)
+
+type UploadError struct {
+	Code    string
+	Message string
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+// MaxUploadSizes limits the size of uploads per file type.
+var MaxUploadSizes = map[FileType]int64{
+	FileTypeImage:    20 << 20,
+	FileTypeVideo:    100 << 20,
+	FileTypeDocument: 25 << 20,
+}
+
+// ValidatedUpload is the result of ValidateUpload. Extension and ContentType
+// come from the file content, not from what the client claimed.
+type ValidatedUpload struct {
+	FileType    FileType
+	Extension   string
+	ContentType string
+}
+
+type sniffedFormat struct {
+	fileType    FileType
+	extension   string
+	contentType string
+}
+
+// ValidateUpload checks an uploaded file against the declared file type: the
+// size limit, the client extension and the real format detected from magic
+// bytes. Executables and macro-enabled Office documents are rejected.
+func (s *FileService) ValidateUpload(file *multipart.FileHeader, fileType FileType) (*ValidatedUpload, error) {
+	maxSize, ok := MaxUploadSizes[fileType]
+	if !ok {
+		return nil, &UploadError{Code: UploadErrInvalidFileType, Message: "invalid file type"}
+	}
+
+	if file.Size == 0 {
+		return nil, &UploadError{Code: UploadErrEmptyFile, Message: "file is empty"}
+	}
+	if file.Size > maxSize {
+		return nil, &UploadError{
+			Code:    UploadErrFileTooLarge,
+			Message: fmt.Sprintf("file exceeds the %d MB limit for %s files", maxSize>>20, fileType),
+		}
+	}
+
+	if !s.ValidateFileType(file.Filename, s.GetAllowedTypes(fileType)) {
+		return nil, &UploadError{
+			Code:    UploadErrUnsupportedFormat,
+			Message: fmt.Sprintf("allowed %s formats: %s", fileType, strings.Join(s.GetAllowedTypes(fileType), ", ")),
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, &UploadError{Code: UploadErrUnreadableFile, Message: "failed to read uploaded file"}
+	}
+	defer src.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(src, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, &UploadError{Code: UploadErrUnreadableFile, Message: "failed to read uploaded file"}
+	}
+	header = header[:n]
+
+	if isExecutable(header) {
+		return nil, &UploadError{Code: UploadErrExecutableContent, Message: "executable files are not allowed"}
+	}
+
+	format, err := sniffFormat(header, src, file.Size)
+	if err != nil {
+		return nil, err
+	}
+	if format == nil || format.fileType != fileType {
+		return nil, &UploadError{
+			Code:    UploadErrContentMismatch,
+			Message: fmt.Sprintf("file content does not match the declared type %s", fileType),
+		}
+	}
+
+	return &ValidatedUpload{
+		FileType:    fileType,
+		Extension:   format.extension,
+		ContentType: format.contentType,
+	}, nil
+}
+
+func isExecutable(header []byte) bool {
+	signatures := [][]byte{
+		[]byte("MZ"),             // Windows PE
+		[]byte("\x7fELF"),        // Linux ELF
+		{0xFE, 0xED, 0xFA, 0xCE}, // Mach-O 32
+		{0xFE, 0xED, 0xFA, 0xCF}, // Mach-O 64
+		{0xCE, 0xFA, 0xED, 0xFE}, // Mach-O 32, little endian
+		{0xCF, 0xFA, 0xED, 0xFE}, // Mach-O 64, little endian
+		{0xCA, 0xFE, 0xBA, 0xBE}, // Mach-O universal / Java class
+		[]byte("#!"),             // scripts
+	}
+	for _, sig := range signatures {
+		if bytes.HasPrefix(header, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffFormat identifies the file format from its first bytes. It returns
+// nil if the format is not one we accept.
+func sniffFormat(header []byte, src multipart.File, size int64) (*sniffedFormat, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return &sniffedFormat{FileTypeImage, ".jpg", "image/jpeg"}, nil
+	case bytes.HasPrefix(header, pngSignature):
+		return &sniffedFormat{FileTypeImage, ".png", "image/png"}, nil
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return &sniffedFormat{FileTypeImage, ".gif", "image/gif"}, nil
+
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return sniffISOMedia(string(header[8:12]))
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return &sniffedFormat{FileTypeVideo, ".avi", "video/x-msvideo"}, nil
+
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return &sniffedFormat{FileTypeDocument, ".pdf", "application/pdf"}, nil
+	case bytes.HasPrefix(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return &sniffedFormat{FileTypeDocument, ".doc", "application/msword"}, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return sniffDocx(src, size)
+	case isPlainText(header):
+		return &sniffedFormat{FileTypeDocument, ".txt", "text/plain; charset=utf-8"}, nil
+	}
+	return nil, nil
+}
+
+// mp4Brands are the major brands of ISO base media files that are MP4 or
+// QuickTime video. Other brands, e.g. HEIC and AVIF images or 3GP, are
+// rejected.
+var mp4Brands = map[string]bool{
+	"isom": true,
+	"iso2": true,
+	"mp41": true,
+	"mp42": true,
+	"avc1": true,
+	"M4V ": true,
+}
+
+// heifBrands are the major brands of HEIC and AVIF images, which phones save
+// photos as but browsers cannot show everywhere.
+var heifBrands = map[string]bool{
+	"heic": true,
+	"heix": true,
+	"hevc": true,
+	"mif1": true,
+	"msf1": true,
+	"avif": true,
+	"avis": true,
+}
+
+// sniffISOMedia identifies an ISO base media file by its major brand.
+func sniffISOMedia(brand string) (*sniffedFormat, error) {
+	switch {
+	case brand == "qt  ":
+		return &sniffedFormat{FileTypeVideo, ".mov", "video/quicktime"}, nil
+	case mp4Brands[brand]:
+		return &sniffedFormat{FileTypeVideo, ".mp4", "video/mp4"}, nil
+	case heifBrands[brand]:
+		return nil, &UploadError{
+			Code:    UploadErrUnsupportedImage,
+			Message: "HEIC and AVIF images are not supported, convert them to JPEG or PNG",
+		}
+	}
+	return nil, nil
+}
+
+// sniffDocx accepts ZIP archives that are Word documents without macros.
+func sniffDocx(src multipart.File, size int64) (*sniffedFormat, error) {
+	archive, err := zip.NewReader(src, size)
+	if err != nil {
+		return nil, nil
+	}
+
+	isDocx := false
+	for _, f := range archive.File {
+		name := strings.ToLower(f.Name)
+		if name == "word/document.xml" {
+			isDocx = true
+		}
+		if strings.HasSuffix(name, "vbaproject.bin") || filepath.Ext(name) == ".exe" {
+			return nil, &UploadError{Code: UploadErrExecutableContent, Message: "documents with macros are not allowed"}
+		}
+	}
+	if !isDocx {
+		return nil, nil
+	}
+
+	return &sniffedFormat{
+		FileTypeDocument,
+		".docx",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	}, nil
+}
+
+// isPlainText accepts UTF-8 text that does not look like HTML, which
+// browsers could otherwise render when the file is opened.
+func isPlainText(header []byte) bool {
+	// A multi-byte rune may be cut off at the end of the sample
+	sample := header
+	for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+		if tail := sample[len(sample)-i:]; utf8.RuneStart(tail[0]) {
+			if !utf8.FullRune(tail) {
+				sample = sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+	if len(sample) == 0 || !utf8.Valid(sample) || bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+
+	lower := bytes.ToLower(bytes.TrimSpace(sample))
+	for _, prefix := range []string{"<!doctype", "<html", "<script", "<svg", "<?xml"} {
+		if bytes.HasPrefix(lower, []byte(prefix)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+// backend/internal/services/upload_test.go
+
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// memoryFile is an uploaded file held in memory.
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
+
+func ftyp(brand string) []byte {
+	return append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p'}, brand...)
+}
+
+func zipWith(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := archive.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     []byte
+		contentType string // "" if the format is not accepted
+		errCode     string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "image/jpeg", ""},
+		{"png", append(append([]byte{}, pngSignature...), 0, 0), "image/png", ""},
+		{"gif", []byte("GIF89a...."), "image/gif", ""},
+		{"mp4 isom", ftyp("isom"), "video/mp4", ""},
+		{"mp4 mp42", ftyp("mp42"), "video/mp4", ""},
+		{"m4v", ftyp("M4V "), "video/mp4", ""},
+		{"quicktime", ftyp("qt  "), "video/quicktime", ""},
+		{"heic", ftyp("heic"), "", UploadErrUnsupportedImage},
+		{"avif", ftyp("avif"), "", UploadErrUnsupportedImage},
+		{"3gp", ftyp("3gp4"), "", ""},
+		{"short ftyp", []byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p'}, "", ""},
+		{"avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), "video/x-msvideo", ""},
+		{"wav", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), "", ""},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf", ""},
+		{"doc", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "application/msword", ""},
+		{"docx", zipWith(t, "[Content_Types].xml", "word/document.xml"),
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document", ""},
+		{"docm", zipWith(t, "word/document.xml", "word/vbaProject.bin"), "", UploadErrExecutableContent},
+		{"zip", zipWith(t, "photo.jpg"), "", ""},
+		{"text", []byte("Kuća na prodaju\n"), "text/plain; charset=utf-8", ""},
+		{"html", []byte("<!DOCTYPE html><html>"), "", ""},
+		{"binary", []byte{0x00, 0x01, 0x02}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.content
+			if len(header) > 512 {
+				header = header[:512]
+			}
+			src := memoryFile{bytes.NewReader(tt.content)}
+			format, err := sniffFormat(header, src, int64(len(tt.content)))
+
+			if tt.errCode != "" {
+				var uploadErr *UploadError
+				if !errors.As(err, &uploadErr) || uploadErr.Code != tt.errCode {
+					t.Fatalf("error = %v, want code %s", err, tt.errCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if format != nil {
+				got = format.contentType
+			}
+			if got != tt.contentType {
+				t.Errorf("content type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestIsPlainText(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   bool
+	}{
+		{"ascii", []byte("Hello"), true},
+		{"cyrillic", []byte("Продаја стана"), true},
+		{"cut off rune", []byte("Продаја")[:5], true},
+		{"invalid utf-8", []byte{'a', 0xFF, 0xFE, 'b'}, false},
+		{"invalid utf-8 at the end", []byte{'a', 'b', 0xFF}, false},
+		{"cut off rune after invalid byte", []byte{'a', 0xFF, 0xD0}, false},
+		{"nul byte", []byte("a\x00b"), false},
+		{"empty", []byte{}, false},
+		{"html", []byte("  <HTML><body>"), false},
+		{"script", []byte("<script>alert(1)</script>"), false},
+		{"svg", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\">"), false},
+		{"xml", []byte("<?xml version=\"1.0\"?>"), false},
+		{"angle bracket text", []byte("a < b"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPlainText(tt.header); got != tt.want {
+				t.Errorf("isPlainText(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// backend/internal/services/users.go
+
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kuckuc/internal/models"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserDisabled = errors.New("user is disabled")
+	ErrEmailTaken   = errors.New("email is already registered")
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrWeakPassword = errors.New("password does not meet the policy")
+	ErrLastAdmin    = errors.New("cannot remove the last active admin")
+	ErrUserInUse    = errors.New("user has property history and can only be disabled")
+)
+
+// MinPasswordLength is the shortest password accepted by ValidatePassword.
+const MinPasswordLength = 10
+
+var commonPasswords = map[string]bool{
+	"password123": true, "password1234": true, "qwerty12345": true, "1234567890": true,
+	"qwertyuiop1": true, "administrator": true, "kuckuc12345": true, "demo123456": true,
+}
+
+// ValidatePassword checks the password policy: at least MinPasswordLength
+// characters with letters and digits, not a common password and not
+// containing the email name.
+func ValidatePassword(password, email string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return fmt.Errorf("%w: must be at least %d characters long", ErrWeakPassword, MinPasswordLength)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: must contain both letters and digits", ErrWeakPassword)
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: password is too common", ErrWeakPassword)
+	}
+	if name, _, _ := strings.Cut(strings.ToLower(email), "@"); len(name) >= 3 && strings.Contains(lower, name) {
+		return fmt.Errorf("%w: must not contain the email address", ErrWeakPassword)
+	}
+	return nil
+}
+
+// GeneratePassword returns a random password that satisfies the policy.
+func GeneratePassword() (string, error) {
+	const letters = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+	const digits = "23456789"
+	const alphabet = letters + digits
+
+	for {
+		b := make([]byte, 16)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", err
+			}
+			b[i] = alphabet[n.Int64()]
+		}
+		password := string(b)
+		if strings.ContainsAny(password, digits) && strings.ContainsAny(password, letters) {
+			return password, nil
+		}
+	}
+}
+
+func (s *AuthService) ListUsers() ([]models.User, error) {
+	var users []models.User
+	if err := s.db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserByEmail looks a user up by email, case-insensitively.
+func (s *AuthService) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := s.db.Where("LOWER(email) = LOWER(?)", strings.TrimSpace(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *AuthService) CreateUser(email, password, role string) (*models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if err := ValidatePassword(password, email); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Email: email, PasswordHash: string(hash), Role: role}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = ?", email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+		return tx.Create(user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// MarkEmailVerified verifies a user without a link, e.g. for accounts created
+// on the server with kuckuc-admin.
+func (s *AuthService) MarkEmailVerified(userID uint) error {
+	return s.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error
+}
+
+// UserChanges lists the changes UpdateUser makes; nil fields are left alone.
+type UserChanges struct {
+	Role              *string
+	Disabled          *bool
+	TwoFactorRequired *bool
+}
+
+// UpdateUser applies changes in one transaction, so either all of them are
+// made or none.
+func (s *AuthService) UpdateUser(id uint, changes UserChanges) (*models.User, error) {
+	if changes.Role != nil && !ValidRole(*changes.Role) {
+		return nil, ErrInvalidRole
+	}
+	return s.updateUser(id, func(tx *gorm.DB, user *models.User) error {
+		if changes.Role != nil {
+			if err := s.setRole(tx, user, *changes.Role); err != nil {
+				return err
+			}
+		}
+		if changes.Disabled != nil {
+			if err := s.setDisabled(tx, user, *changes.Disabled); err != nil {
+				return err
+			}
+		}
+		if changes.TwoFactorRequired != nil {
+			user.TwoFactorRequired = *changes.TwoFactorRequired
+		}
+		return nil
+	})
+}
+
+func (s *AuthService) SetUserRole(id uint, role string) (*models.User, error) {
+	return s.UpdateUser(id, UserChanges{Role: &role})
+}
+
+// SetUserDisabled disables or re-enables a user. Disabling revokes all
+// sessions of the user.
+func (s *AuthService) SetUserDisabled(id uint, disabled bool) (*models.User, error) {
+	return s.UpdateUser(id, UserChanges{Disabled: &disabled})
+}
+
+func (s *AuthService) setRole(tx *gorm.DB, user *models.User, role string) error {
+	if user.Role == RoleAdmin && role != RoleAdmin {
+		if err := s.ensureOtherAdmin(tx, user.ID); err != nil {
+			return err
+		}
+	}
+	user.Role = role
+	return nil
+}
+
+func (s *AuthService) setDisabled(tx *gorm.DB, user *models.User, disabled bool) error {
+	if !disabled {
+		user.DisabledAt = nil
+		return nil
+	}
+	if user.Role == RoleAdmin {
+		if err := s.ensureOtherAdmin(tx, user.ID); err != nil {
+			return err
+		}
+	}
+	if user.DisabledAt == nil {
+		now := time.Now()
+		user.DisabledAt = &now
+	}
+	return revokeSessions(tx, user.ID)
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+func (s *AuthService) ResetPassword(id uint, password string) (*models.User, error) {
+	return s.updateUser(id, func(tx *gorm.DB, user *models.User) error {
+		if err := ValidatePassword(password, user.Email); err != nil {
+			return err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = string(hash)
+		return revokeSessions(tx, user.ID)
+	})
+}
+
+// DeleteUser removes a user that never worked on a property. Users with
+// history must be disabled instead, so the history keeps its author.
+func (s *AuthService) DeleteUser(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if user.Role == RoleAdmin {
+			if err := s.ensureOtherAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&models.History{}).Where("agent_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserInUse
+		}
+
+		// Deleted properties too, until they are purged
+		if err := tx.Unscoped().Model(&models.Property{}).Where("assigned_agent_id = ?", id).Update("assigned_agent_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Property{}).Where("created_by = ?", id).Update("created_by", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+}
+
+func (s *AuthService) updateUser(id uint, update func(tx *gorm.DB, user *models.User) error) (*models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if err := update(tx, &user); err != nil {
+			return err
+		}
+		return tx.Save(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ensureOtherAdmin fails if userID is the only active admin, so the system
+// cannot be locked out of user management.
+func (s *AuthService) ensureOtherAdmin(tx *gorm.DB, userID uint) error {
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("role = ? AND disabled_at IS NULL AND id <> ?", RoleAdmin, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// backend/internal/services/users_test.go
+
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"kuckuc/internal/models"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		wantErr  bool
+	}{
+		{"valid", "sunny4balcony", "agent@kuckuc.rs", false},
+		{"unicode letters", "кућа2024продаја", "agent@kuckuc.rs", false},
+		{"too short", "abc12345", "agent@kuckuc.rs", true},
+		// Counted in characters, not bytes
+		{"short in characters", "ђђђђђђђђ1", "agent@kuckuc.rs", true},
+		{"letters only", "sunnybalcony", "agent@kuckuc.rs", true},
+		{"digits only", "12345678901", "agent@kuckuc.rs", true},
+		{"common", "Password123", "agent@kuckuc.rs", true},
+		{"contains email name", "Milica2024x", "milica@kuckuc.rs", true},
+		{"short email name ignored", "ana2024house", "an@kuckuc.rs", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.password, tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePassword(%q) = %v, want error %v", tt.password, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrWeakPassword) {
+				t.Errorf("error %v is not ErrWeakPassword", err)
+			}
+		})
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		password, err := GeneratePassword()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidatePassword(password, "agent@kuckuc.rs"); err != nil {
+			t.Errorf("generated password %q fails the policy: %v", password, err)
+		}
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	db, statements := dryRunDB(t)
+	s := NewAuthService(db, testKeySet(t), nil)
+
+	if _, err := s.CreateUser("agent@kuckuc.rs", "sunny4balcony", "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("unknown role: error = %v, want ErrInvalidRole", err)
+	}
+	if _, err := s.CreateUser("agent@kuckuc.rs", "password123", RoleAgent); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("weak password: error = %v, want ErrWeakPassword", err)
+	}
+	if _, err := s.SetUserRole(1, "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("SetUserRole to unknown role: error = %v, want ErrInvalidRole", err)
+	}
+	if len(*statements) > 0 {
+		t.Errorf("invalid input reached the database: %q", *statements)
+	}
+}
+
+// testPassword is the password of users made by createTestUser.
+const testPassword = "sunny4balcony"
+
+// createTestUser adds a verified user who can log in with testPassword.
+func createTestUser(t *testing.T, auth *AuthService, email, role string) *models.User {
+	t.Helper()
+	user, err := auth.CreateUser(email, testPassword, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.MarkEmailVerified(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestLoginNormalizesEmail(t *testing.T) {
+	db, statements := dryRunDB(t)
+	auth := NewAuthService(db, testKeySet(t), nil)
+
+	if _, err := auth.Login("  Agent@Kuckuc.RS ", testPassword, ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("error = %v, want ErrInvalidCredentials from the empty dry run", err)
+	}
+	if len(*statements) == 0 || !strings.Contains((*statements)[0], `email = 'agent@kuckuc.rs'`) {
+		t.Errorf("user looked up with %q", *statements)
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("cost = %d, want %d like real password hashes", cost, bcrypt.DefaultCost)
+	}
+	if err := bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(testPassword)); err == nil {
+		t.Error("dummy hash matches a password")
+	}
+}
+
+func TestUpdateUserIsAtomic(t *testing.T) {
+	db := testDB(t)
+	s := NewAuthService(db, testKeySet(t), nil)
+
+	admin := createTestUser(t, s, "admin@kuckuc.rs", RoleAdmin)
+	required, disabled := true, true
+	_, err := s.UpdateUser(admin.ID, UserChanges{TwoFactorRequired: &required, Disabled: &disabled})
+	if !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("disabling the last admin: error = %v, want ErrLastAdmin", err)
+	}
+
+	user, err := s.GetUser(admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TwoFactorRequired || user.DisabledAt != nil {
+		t.Errorf("failed update was partly applied: two_factor_required = %v, disabled_at = %v", user.TwoFactorRequired, user.DisabledAt)
+	}
+
+	agentRole := RoleAgent
+	if _, err := s.UpdateUser(admin.ID, UserChanges{Role: &agentRole, TwoFactorRequired: &required}); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("demoting the last admin: error = %v, want ErrLastAdmin", err)
+	}
+
+	agent := createTestUser(t, s, "agent@kuckuc.rs", RoleAgent)
+	seniorRole := RoleSeniorAgent
+	updated, err := s.UpdateUser(agent.ID, UserChanges{Role: &seniorRole, TwoFactorRequired: &required})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Role != RoleSeniorAgent || !updated.TwoFactorRequired {
+		t.Errorf("role = %q, two_factor_required = %v after update", updated.Role, updated.TwoFactorRequired)
+	}
+}
@@ -0,0 +1,164 @@
+// backend/internal/storage/local.go
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage keeps files in a directory on the local disk.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if root == "" {
+		return nil, errors.New("local storage directory is not configured")
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+func (s *LocalStorage) fullPath(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	fullPath, err := s.fullPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error) {
+	fullPath, err := s.fullPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, nil, mapLocalError(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	return f, s.object(key, info), nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*Object, error) {
+	fullPath, err := s.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, mapLocalError(err)
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return s.object(key, info), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	fullPath, err := s.fullPath(key)
+	if err != nil {
+		return err
+	}
+	return mapLocalError(os.Remove(fullPath))
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	return filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(*s.object(key, info))
+	})
+}
+
+func (s *LocalStorage) object(key string, info fs.FileInfo) *Object {
+	return &Object{
+		Key:         key,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+	}
+}
+
+func mapLocalError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
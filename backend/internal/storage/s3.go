@@ -0,0 +1,117 @@
+// backend/internal/storage/s3.go
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage keeps files in a bucket of an S3-compatible service
+// (AWS S3, MinIO, ...).
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Storage(cfg Config) (*S3Storage, error) {
+	if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket must be configured")
+	}
+
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+
+	return &S3Storage{client: client, bucket: cfg.S3Bucket}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, mapS3Error(err)
+	}
+
+	// GetObject is lazy; Stat performs the request and reports missing keys
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, mapS3Error(err)
+	}
+
+	return obj, s.object(info), nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*Object, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	return s.object(info), nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return mapS3Error(s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return info.Err
+		}
+		if err := fn(*s.object(info)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) object(info minio.ObjectInfo) *Object {
+	return &Object{
+		Key:         info.Key,
+		Size:        info.Size,
+		ModTime:     info.LastModified,
+		ContentType: info.ContentType,
+	}
+}
+
+func mapS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
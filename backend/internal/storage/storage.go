@@ -0,0 +1,82 @@
+// backend/internal/storage/storage.go
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored file. Keys are slash-separated paths relative to
+// the storage root, e.g. "image/3/2025/01/<uuid>.jpg".
+type Object struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Storage is the file storage backend used by FileService.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns a seekable reader so callers can serve range requests.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+}
+
+type Config struct {
+	Driver string
+
+	// Local driver
+	LocalDir string
+
+	// S3 driver
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+// ConfigFromEnv reads the storage configuration from STORAGE_DRIVER,
+// UPLOAD_DIR and the S3_* variables.
+func ConfigFromEnv() Config {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+	useSSL, _ := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+
+	return Config{
+		Driver:      driver,
+		LocalDir:    os.Getenv("UPLOAD_DIR"),
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:    useSSL,
+	}
+}
+
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocalStorage(cfg.LocalDir)
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,162 @@
+// backend/internal/storage/storage_test.go
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStorage runs the behaviour FileService relies on against a driver.
+// Keys are created under a unique prefix so a shared bucket can be reused.
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("test-%d/", time.Now().UnixNano())
+	put := func(key, content, contentType string) {
+		t.Helper()
+		if err := store.Put(ctx, prefix+key, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+		t.Cleanup(func() { _ = store.Delete(ctx, prefix+key) })
+	}
+
+	put("image/1/photo.jpg", "jpeg data", "image/jpeg")
+	put("image/1/photo_thumbnail.webp", "webp data", "image/webp")
+	put("document/2/contract.pdf", "pdf data", "application/pdf")
+
+	t.Run("get", func(t *testing.T) {
+		file, info, err := store.Get(ctx, prefix+"image/1/photo.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if info.Key != prefix+"image/1/photo.jpg" || info.Size != 9 || info.ContentType != "image/jpeg" {
+			t.Errorf("object = %+v", info)
+		}
+
+		// Range requests seek into the object
+		if _, err := file.Seek(5, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		rest, err := io.ReadAll(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(rest) != "data" {
+			t.Errorf("read after seek = %q, want %q", rest, "data")
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		put("document/2/notes.txt", "first", "text/plain")
+		put("document/2/notes.txt", "second version", "text/plain")
+		info, err := store.Stat(ctx, prefix+"document/2/notes.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len("second version")) {
+			t.Errorf("size = %d after overwrite", info.Size)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, _, err := store.Get(ctx, prefix+"image/1/missing.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+		if _, err := store.Stat(ctx, prefix+"image/1/missing.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat = %v, want ErrNotFound", err)
+		}
+		// A directory is not an object
+		if _, err := store.Stat(ctx, prefix+"image/1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat of a directory = %v, want ErrNotFound", err)
+		}
+		if err := store.Delete(ctx, prefix+"image/1/missing.jpg"); err != nil && !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete = %v, want nil or ErrNotFound", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		var keys []string
+		err := store.List(ctx, prefix+"image/", func(object Object) error {
+			keys = append(keys, strings.TrimPrefix(object.Key, prefix))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(keys)
+		want := []string{"image/1/photo.jpg", "image/1/photo_thumbnail.webp"}
+		if strings.Join(keys, ",") != strings.Join(want, ",") {
+			t.Errorf("List = %v, want %v", keys, want)
+		}
+
+		stop := errors.New("stop")
+		if err := store.List(ctx, prefix, func(Object) error { return stop }); !errors.Is(err, stop) {
+			t.Errorf("List did not return the callback error: %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		put("image/3/deleted.jpg", "data", "image/jpeg")
+		if err := store.Delete(ctx, prefix+"image/3/deleted.jpg"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Stat(ctx, prefix+"image/3/deleted.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, store)
+
+	// Keys cannot escape the storage directory
+	ctx := context.Background()
+	if err := store.Put(ctx, "../../escape.txt", strings.NewReader("x"), 1, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(root + "/escape.txt"); err != nil {
+		t.Errorf("../ key was not kept inside the root: %v", err)
+	}
+	if err := store.Put(ctx, "/", strings.NewReader("x"), 1, "text/plain"); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+}
+
+// TestS3Storage runs against a MinIO or other S3-compatible service when
+// S3_TEST_ENDPOINT is set, e.g. with `make test-s3`.
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+	bucket := os.Getenv("S3_TEST_BUCKET")
+	if bucket == "" {
+		bucket = "kuckuc-test"
+	}
+
+	store, err := NewS3Storage(Config{
+		Driver:      "s3",
+		S3Endpoint:  endpoint,
+		S3Region:    os.Getenv("S3_REGION"),
+		S3Bucket:    bucket,
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, store)
+}
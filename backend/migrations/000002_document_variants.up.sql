@@ -0,0 +1,17 @@
+-- backend/migrations/000002_document_variants.up.sql
+
+-- Resized copies of image documents (thumbnail, medium, large)
+CREATE TABLE document_variants (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL REFERENCES property_documents(id) ON DELETE CASCADE,
+    variant VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    file_path TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (document_id, variant)
+);
+
+CREATE INDEX idx_document_variants_file_path ON document_variants(file_path);
@@ -0,0 +1,38 @@
+-- backend/migrations/000003_property_search.up.sql
+
+-- Full-text search over property details in the language of each row
+
+CREATE OR REPLACE FUNCTION property_search_config(lang TEXT) RETURNS regconfig AS $$
+    SELECT COALESCE(
+        (SELECT c.oid::regconfig FROM pg_ts_config c WHERE c.cfgname = CASE lang
+            WHEN 'sr' THEN 'serbian'
+            WHEN 'ru' THEN 'russian'
+            WHEN 'en' THEN 'english'
+        END),
+        'simple'::regconfig
+    )
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE property_details ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION property_details_search_update() RETURNS trigger AS $$
+DECLARE
+    cfg regconfig := property_search_config(NEW.language);
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector(cfg, COALESCE(NEW.city, '')), 'A') ||
+        setweight(to_tsvector(cfg, COALESCE(NEW.district, '')), 'A') ||
+        setweight(to_tsvector(cfg, COALESCE(NEW.address, '')), 'B') ||
+        setweight(to_tsvector(cfg, COALESCE(NEW.description, '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER property_details_search_update
+    BEFORE INSERT OR UPDATE OF language, city, district, address, description ON property_details
+    FOR EACH ROW EXECUTE FUNCTION property_details_search_update();
+
+-- Fill the column for existing rows through the trigger
+UPDATE property_details SET city = city;
+
+CREATE INDEX idx_property_details_search ON property_details USING GIN (search_vector);
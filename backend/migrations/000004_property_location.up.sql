@@ -0,0 +1,22 @@
+-- backend/migrations/000004_property_location.up.sql
+
+-- Coordinates of each property, searched with the earthdistance extension
+-- (ships with the standard postgres images, unlike PostGIS)
+
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE properties
+    ADD COLUMN latitude DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION,
+    ADD CONSTRAINT properties_latitude_check CHECK (latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT properties_longitude_check CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT properties_location_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Radius search: earth_box(...) @> ll_to_earth(latitude, longitude)
+CREATE INDEX idx_properties_earth ON properties USING GIST (ll_to_earth(latitude, longitude))
+    WHERE latitude IS NOT NULL;
+
+-- Bounding box and polygon search
+CREATE INDEX idx_properties_lat_lng ON properties (latitude, longitude)
+    WHERE latitude IS NOT NULL;
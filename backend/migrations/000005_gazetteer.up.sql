@@ -0,0 +1,35 @@
+-- backend/migrations/000005_gazetteer.up.sql
+
+-- Local gazetteer used for offline geocoding, filled by cmd/tools/import_gazetteer
+
+CREATE TABLE gazetteer_places (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('settlement', 'district', 'street')),
+    parent_id INTEGER REFERENCES gazetteer_places(id) ON DELETE CASCADE,
+    -- Settlement size: 4 city, 3 town, 2 village, 1 hamlet
+    rank SMALLINT NOT NULL DEFAULT 0,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    source_id VARCHAR(100) NOT NULL,
+    parent_source_id VARCHAR(100),
+    UNIQUE (source, source_id)
+);
+
+CREATE INDEX idx_gazetteer_places_parent ON gazetteer_places(parent_id);
+CREATE INDEX idx_gazetteer_places_earth ON gazetteer_places USING GIST (ll_to_earth(latitude, longitude));
+
+-- One row per spelling: OSM name, name:sr, name:sr-Latn, name:en, name:ru, ...
+CREATE TABLE gazetteer_names (
+    id SERIAL PRIMARY KEY,
+    place_id INTEGER NOT NULL REFERENCES gazetteer_places(id) ON DELETE CASCADE,
+    language VARCHAR(10) NOT NULL,
+    name TEXT NOT NULL,
+    normalized TEXT NOT NULL
+);
+
+CREATE INDEX idx_gazetteer_names_normalized ON gazetteer_names(normalized);
+CREATE INDEX idx_gazetteer_names_place ON gazetteer_names(place_id);
+
+-- Where property coordinates come from: 'manual' or 'gazetteer'
+ALTER TABLE properties ADD COLUMN location_source VARCHAR(20);
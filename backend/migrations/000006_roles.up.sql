@@ -0,0 +1,20 @@
+-- backend/migrations/000006_roles.up.sql
+
+-- Roles: admin, senior_agent, agent, viewer (formerly guest).
+-- New enum values cannot be used in the same transaction they are added in,
+-- so no rows are updated to them here.
+ALTER TYPE user_role RENAME VALUE 'guest' TO 'viewer';
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'senior_agent';
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'admin';
+
+-- Ownership: agents may only edit properties they created or are assigned to
+ALTER TABLE properties
+    ADD COLUMN created_by INTEGER REFERENCES users(id),
+    ADD COLUMN assigned_agent_id INTEGER REFERENCES users(id);
+
+UPDATE properties p SET created_by = h.agent_id
+FROM property_history h
+WHERE h.property_id = p.id AND h.action_type = 'create';
+
+CREATE INDEX idx_properties_created_by ON properties(created_by);
+CREATE INDEX idx_properties_assigned_agent ON properties(assigned_agent_id);
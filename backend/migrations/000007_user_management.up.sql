@@ -0,0 +1,4 @@
+-- backend/migrations/000007_user_management.up.sql
+
+-- Disabled users cannot log in and their tokens are rejected
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,27 @@
+-- backend/migrations/000008_auth_sessions.up.sql
+
+-- A session is one login. Its refresh tokens form a rotation family: each
+-- token is used once, and reusing one revokes the whole session.
+CREATE TABLE auth_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_auth_sessions_user ON auth_sessions(user_id);
+
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refresh_tokens_session ON refresh_tokens(session_id);
@@ -0,0 +1,23 @@
+-- backend/migrations/000009_login_throttling.up.sql
+
+-- Failed login attempts, kept for auditing
+CREATE TABLE login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    reason VARCHAR(30) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(LOWER(email), created_at);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip_address, created_at);
+
+-- Failure counters shared by all API replicas when LOGIN_THROTTLE_STORE=postgres.
+-- Keys are "account:<email>" and "ip:<address>".
+CREATE TABLE login_throttles (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
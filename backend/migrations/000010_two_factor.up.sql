@@ -0,0 +1,21 @@
+-- backend/migrations/000010_two_factor.up.sql
+
+-- TOTP two-factor authentication. totp_secret is set during enrolment and
+-- only takes effect once totp_enabled_at is set. totp_last_step is the last
+-- accepted time step, so a code cannot be used twice.
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64),
+    ADD COLUMN totp_enabled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN two_factor_required BOOLEAN NOT NULL DEFAULT false;
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
@@ -0,0 +1,19 @@
+-- backend/migrations/000011_email_tokens.up.sql
+
+-- New users must verify their email address before they can log in. Existing
+-- users are treated as verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+UPDATE users SET email_verified_at = created_at;
+
+-- Single-use tokens sent by email, stored as SHA-256 hashes
+CREATE TABLE email_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL CHECK (purpose IN ('password_reset', 'email_verification')),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_tokens_user ON email_tokens(user_id, purpose);
@@ -0,0 +1,30 @@
+-- backend/migrations/000012_oidc.up.sql
+
+-- Accounts at the OpenID Connect provider linked to users. Users created by
+-- single sign-on have an empty password_hash and cannot log in with a password.
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- Single sign-on logins in progress. The row keeps the PKCE verifier and the
+-- nonce between the redirect to the provider and the callback, then the hash
+-- of the one-time code the frontend exchanges for tokens.
+CREATE TABLE oidc_logins (
+    state_hash CHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
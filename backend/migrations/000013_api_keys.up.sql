@@ -0,0 +1,23 @@
+-- backend/migrations/000013_api_keys.up.sql
+
+-- API keys for partner integrations and scripts. A key acts as its user,
+-- limited to its scopes, and is stored as a SHA-256 hash; prefix is the
+-- start of the key, kept to tell keys apart.
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    -- Requests per minute
+    rate_limit INTEGER NOT NULL DEFAULT 60 CHECK (rate_limit > 0),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
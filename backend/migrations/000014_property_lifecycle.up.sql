@@ -0,0 +1,26 @@
+-- backend/migrations/000014_property_lifecycle.up.sql
+
+-- Listing lifecycle: draft -> published -> reserved -> sold/rented -> archived.
+-- The allowed transitions are enforced in PropertyService. is_active is kept
+-- for older clients and is true while a property is published or reserved.
+-- The *_at columns hold the last time the property entered that state;
+-- closed_at is when it was sold or rented.
+
+ALTER TABLE properties
+    ADD COLUMN lifecycle_state VARCHAR(20) NOT NULL DEFAULT 'draft',
+    ADD COLUMN state_changed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN published_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN reserved_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT properties_lifecycle_state_check
+        CHECK (lifecycle_state IN ('draft', 'published', 'reserved', 'sold', 'rented', 'archived'));
+
+-- Active properties were visible, inactive ones taken down
+UPDATE properties SET
+    lifecycle_state = CASE WHEN COALESCE(is_active, true) THEN 'published' ELSE 'archived' END,
+    state_changed_at = updated_at,
+    published_at = created_at,
+    archived_at = CASE WHEN COALESCE(is_active, true) THEN NULL ELSE updated_at END;
+
+CREATE INDEX idx_properties_lifecycle_state ON properties (lifecycle_state);
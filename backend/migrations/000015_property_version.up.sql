@@ -0,0 +1,7 @@
+-- backend/migrations/000015_property_version.up.sql
+
+-- Optimistic concurrency: every change to a property increments version,
+-- which is sent to clients as the ETag. Updates with an older version are
+-- rejected instead of overwriting someone else's edit.
+
+ALTER TABLE properties ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
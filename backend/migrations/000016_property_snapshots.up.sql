@@ -0,0 +1,15 @@
+-- backend/migrations/000016_property_snapshots.up.sql
+
+-- State of a property, its details and owner right after each history
+-- entry, so a listing can be restored to any entry. data is the property as
+-- JSON. Entries written before this migration have no snapshot.
+CREATE TABLE property_snapshots (
+    id SERIAL PRIMARY KEY,
+    property_id INTEGER NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    history_id INTEGER NOT NULL UNIQUE REFERENCES property_history(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_property_snapshots_property ON property_snapshots(property_id);
@@ -0,0 +1,32 @@
+-- backend/migrations/000017_soft_delete.up.sql
+
+-- Deleted properties and documents are kept, with their files, until the
+-- retention job purges them (DELETED_RETENTION_DAYS after deleted_at).
+-- Until then they can be restored. A document of a deleted property is
+-- hidden with it.
+ALTER TABLE properties
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE property_documents
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_properties_deleted_at ON properties(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_property_documents_deleted_at ON property_documents(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Actions that cannot be undone, such as purges. Rows outlive what they
+-- describe, so entity_id is not a foreign key. actor_id is NULL for the
+-- retention job.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
@@ -0,0 +1,29 @@
+-- backend/migrations/000018_export_jobs.up.sql
+
+-- Exports run in the background. The ZIP is written to file storage under
+-- result_key and removed, with the job, at expires_at. A worker touches
+-- updated_at while it runs, so jobs left behind by a stopped server can be
+-- told apart from slow ones.
+CREATE TABLE export_jobs (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    language VARCHAR(2) NOT NULL,
+    property_ids JSONB NOT NULL DEFAULT '[]',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    result_key TEXT,
+    result_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT export_jobs_status_check
+        CHECK (status IN ('queued', 'running', 'completed', 'failed', 'canceled'))
+);
+
+CREATE INDEX idx_export_jobs_user ON export_jobs(user_id, created_at);
+CREATE INDEX idx_export_jobs_status ON export_jobs(status);
+CREATE INDEX idx_export_jobs_expires_at ON export_jobs(expires_at) WHERE expires_at IS NOT NULL;
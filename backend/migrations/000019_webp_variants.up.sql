@@ -0,0 +1,6 @@
+-- backend/migrations/000019_webp_variants.up.sql
+
+-- Every variant is stored as JPEG and as WebP
+ALTER TABLE document_variants DROP CONSTRAINT document_variants_document_id_variant_key;
+ALTER TABLE document_variants ADD CONSTRAINT document_variants_document_id_variant_format_key
+    UNIQUE (document_id, variant, format);
@@ -0,0 +1,7 @@
+-- backend/migrations/000020_export_warnings.up.sql
+
+-- Files and histories left out of an export because they could not be read.
+-- skipped counts all of them; warnings holds the first 100 messages.
+ALTER TABLE export_jobs
+    ADD COLUMN skipped INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN warnings JSONB NOT NULL DEFAULT '[]';
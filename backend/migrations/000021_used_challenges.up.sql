@@ -0,0 +1,10 @@
+-- backend/migrations/000021_used_challenges.up.sql
+
+-- IDs (jti) of two-factor login challenges that completed a login, so a
+-- challenge token works once. Rows are removed once the challenge expires.
+CREATE TABLE used_challenges (
+    id UUID PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_used_challenges_expires_at ON used_challenges(expires_at);
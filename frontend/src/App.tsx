@@ -10,12 +10,32 @@ import PropertyList from './components/Properties/PropertyList';
 import DashboardPanel from './components/Dashboard/DashboardPanel';
 import PropertyForm from './components/Properties/PropertyForm';
 import ProtectedRoute from './components/Auth/ProtectedRoute';
+import ForgotPasswordForm from './components/Auth/ForgotPasswordForm';
+import ResetPasswordForm from './components/Auth/ResetPasswordForm';
+import VerifyEmail from './components/Auth/VerifyEmail';
+import SsoCallback from './components/Auth/SsoCallback';
 
 const router = createBrowserRouter([
   {
     path: "/login",
     element: <LoginForm />
   },
+  {
+    path: "/login/sso",
+    element: <SsoCallback />
+  },
+  {
+    path: "/forgot-password",
+    element: <ForgotPasswordForm />
+  },
+  {
+    path: "/reset-password",
+    element: <ResetPasswordForm />
+  },
+  {
+    path: "/verify-email",
+    element: <VerifyEmail />
+  },
   {
     path: "/properties",
     element: <ProtectedRoute><PropertyList /></ProtectedRoute>
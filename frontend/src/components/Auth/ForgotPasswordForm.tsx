@@ -0,0 +1,66 @@
+// frontend/src/components/Auth/ForgotPasswordForm.tsx
+import React, { useState } from 'react';
+import { Link } from 'react-router-dom';
+import { getApiUrl } from '../../config/api';
+
+const ForgotPasswordForm = () => {
+  const [email, setEmail] = useState('');
+  const [sent, setSent] = useState(false);
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      const response = await fetch(getApiUrl('/api/auth/forgot-password'), {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ email }),
+      });
+      if (!response.ok) {
+        throw new Error('Request failed');
+      }
+      setSent(true);
+    } catch (err) {
+      setError('Something went wrong. Please try again.');
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-6">
+        <h2 className="text-center text-2xl font-extrabold text-gray-900">Forgot your password?</h2>
+        {sent ? (
+          <p className="text-sm text-gray-600 text-center">
+            If an account exists for {email}, we have sent a link to reset the password. The link is valid for one hour.
+          </p>
+        ) : (
+          <form className="space-y-4" onSubmit={handleSubmit}>
+            <input
+              type="email"
+              autoComplete="email"
+              required
+              className="appearance-none rounded-md block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 focus:outline-none focus:ring-indigo-500 focus:border-indigo-500 sm:text-sm"
+              placeholder="Email address"
+              value={email}
+              onChange={(e) => setEmail(e.target.value)}
+            />
+            {error && <div className="text-red-500 text-sm text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full py-2 px-4 text-sm font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700"
+            >
+              Send reset link
+            </button>
+          </form>
+        )}
+        <div className="text-center text-sm">
+          <Link to="/login" className="text-indigo-600 hover:text-indigo-500">Back to sign in</Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ForgotPasswordForm;
@@ -1,37 +1,185 @@
 // frontend/src/components/Auth/LoginForm.tsx
-import React, { useState } from 'react';
-import { useNavigate } from 'react-router-dom';
+import React, { useEffect, useState } from 'react';
+import { Link, useLocation, useNavigate } from 'react-router-dom';
 import { getApiUrl } from '../../config/api';
+import { saveTokens } from '../../config/auth';
+
+interface TwoFactorSetup {
+  secret: string;
+  provisioning_uri: string;
+}
 
 const LoginForm = () => {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
+  // Second step for accounts with two-factor authentication
+  const [challenge, setChallenge] = useState('');
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [unverified, setUnverified] = useState(false);
+  // Name of the single sign-on provider, if it is configured
+  const [ssoName, setSsoName] = useState('');
   const navigate = useNavigate();
+  const location = useLocation();
+
+  useEffect(() => {
+    fetch(getApiUrl('/api/auth/oidc'))
+      .then((response) => response.json())
+      .then((data) => setSsoName(data.enabled ? data.name : ''))
+      .catch(() => setSsoName(''));
+  }, []);
+
+  const post = (path: string, body: object) =>
+    fetch(getApiUrl(path), {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(body),
+    });
+
+  const handleLoginResponse = async (response: Response) => {
+    if (response.status === 429) {
+      const retryAfter = response.headers.get('Retry-After');
+      setError(`Too many failed attempts. Try again in ${retryAfter || 'a few'} seconds.`);
+      return;
+    }
+    if (response.status === 403) {
+      setUnverified(true);
+      setError('Your email address is not verified yet. Check your inbox for the verification link.');
+      return;
+    }
+    if (!response.ok) {
+      throw new Error('Invalid credentials');
+    }
+
+    await handleLoginData(await response.json());
+  };
+
+  const handleLoginData = async (data: any) => {
+    if (data.challenge_token) {
+      setChallenge(data.challenge_token);
+      setCode('');
+      setError('');
+      if (data.enrollment_required) {
+        const enroll = await post('/api/auth/2fa/enroll', { challenge_token: data.challenge_token });
+        if (!enroll.ok) throw new Error('Enrollment failed');
+        setSetup(await enroll.json());
+      }
+      return;
+    }
+
+    saveTokens(data);
+    if (data.recovery_codes?.length) {
+      setRecoveryCodes(data.recovery_codes);
+      return;
+    }
+    navigate('/properties');
+  };
+
+  // Single sign-on hands over its two-factor challenge to continue here
+  useEffect(() => {
+    const loginResult = (location.state as { loginResult?: object } | null)?.loginResult;
+    if (loginResult) {
+      navigate(location.pathname, { replace: true, state: null });
+      handleLoginData(loginResult).catch(() => setError('Enrollment failed. Please sign in again.'));
+    }
+  }, []);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     try {
-      const response = await fetch(getApiUrl('/api/auth/login'), {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-        },
-        body: JSON.stringify({ email, password }),
-      });
-
-      if (!response.ok) {
-        throw new Error('Invalid credentials');
-      }
-
-      const data = await response.json();
-      localStorage.setItem('token', data.token);
-      navigate('/properties');
+      await handleLoginResponse(await post('/api/auth/login', { email, password }));
     } catch (err) {
       setError('Login failed. Please check your credentials.');
     }
   };
 
+  const handleVerify = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      await handleLoginResponse(await post('/api/auth/2fa/verify', { challenge_token: challenge, code }));
+    } catch (err) {
+      setError('Invalid code. Please try again.');
+    }
+  };
+
+  const handleResendVerification = async () => {
+    await post('/api/auth/verify-email/resend', { email });
+    setUnverified(false);
+    setError('A new verification link has been sent.');
+  };
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+        <div className="max-w-md w-full space-y-6">
+          <h2 className="text-center text-2xl font-extrabold text-gray-900">Save your recovery codes</h2>
+          <p className="text-sm text-gray-600">
+            Each code can be used once instead of an authenticator code. They will not be shown again.
+          </p>
+          <ul className="grid grid-cols-2 gap-2 font-mono text-center bg-white border rounded p-4">
+            {recoveryCodes.map((c) => (
+              <li key={c}>{c}</li>
+            ))}
+          </ul>
+          <button
+            onClick={() => navigate('/properties')}
+            className="w-full py-2 px-4 text-sm font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700"
+          >
+            Continue
+          </button>
+        </div>
+      </div>
+    );
+  }
+
+  if (challenge) {
+    return (
+      <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+        <div className="max-w-md w-full space-y-6">
+          <h2 className="text-center text-2xl font-extrabold text-gray-900">Two-factor authentication</h2>
+          {setup ? (
+            <div className="text-sm text-gray-600 space-y-2">
+              <p>Your account requires two-factor authentication. Add it to your authenticator app with this key:</p>
+              <p className="font-mono text-center bg-white border rounded p-2 break-all">{setup.secret}</p>
+              <p>
+                or open <a href={setup.provisioning_uri} className="text-indigo-600 underline">this link</a> on your phone,
+                then enter the code it shows.
+              </p>
+            </div>
+          ) : (
+            <p className="text-sm text-gray-600">
+              Enter the code from your authenticator app or one of your recovery codes.
+            </p>
+          )}
+          <form className="space-y-4" onSubmit={handleVerify}>
+            <input
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              required
+              autoFocus
+              className="appearance-none rounded-md block w-full px-3 py-2 border border-gray-300 text-center tracking-widest focus:outline-none focus:ring-indigo-500 focus:border-indigo-500"
+              placeholder="123456"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+            />
+            {error && <div className="text-red-500 text-sm text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full py-2 px-4 text-sm font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700"
+            >
+              Verify
+            </button>
+          </form>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
       <div className="max-w-md w-full space-y-8">
@@ -79,6 +227,13 @@ const LoginForm = () => {
           {error && (
             <div className="text-red-500 text-sm text-center">{error}</div>
           )}
+          {unverified && (
+            <div className="text-center text-sm">
+              <button type="button" onClick={handleResendVerification} className="text-indigo-600 hover:text-indigo-500">
+                Send the link again
+              </button>
+            </div>
+          )}
 
           <div>
             <button
@@ -88,6 +243,21 @@ const LoginForm = () => {
               Sign in
             </button>
           </div>
+          {ssoName && (
+            <div>
+              <a
+                href={getApiUrl('/api/auth/oidc/login')}
+                className="w-full flex justify-center py-2 px-4 border border-gray-300 text-sm font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50"
+              >
+                Sign in with {ssoName}
+              </a>
+            </div>
+          )}
+          <div className="text-center text-sm">
+            <Link to="/forgot-password" className="text-indigo-600 hover:text-indigo-500">
+              Forgot your password?
+            </Link>
+          </div>
         </form>
       </div>
     </div>
@@ -0,0 +1,84 @@
+// frontend/src/components/Auth/ResetPasswordForm.tsx
+import React, { useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { getApiUrl } from '../../config/api';
+
+const ResetPasswordForm = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [password, setPassword] = useState('');
+  const [confirm, setConfirm] = useState('');
+  const [done, setDone] = useState(false);
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (password !== confirm) {
+      setError('Passwords do not match.');
+      return;
+    }
+    try {
+      const response = await fetch(getApiUrl('/api/auth/reset-password'), {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ token, password }),
+      });
+      if (!response.ok) {
+        const data = await response.json();
+        setError(data.error || 'Failed to reset password.');
+        return;
+      }
+      setDone(true);
+    } catch (err) {
+      setError('Something went wrong. Please try again.');
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-6">
+        <h2 className="text-center text-2xl font-extrabold text-gray-900">Choose a new password</h2>
+        {done ? (
+          <p className="text-sm text-gray-600 text-center">
+            Your password has been changed and you have been signed out on all devices.
+          </p>
+        ) : (
+          <form className="space-y-4" onSubmit={handleSubmit}>
+            <input
+              type="password"
+              autoComplete="new-password"
+              required
+              className="appearance-none rounded-md block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 focus:outline-none focus:ring-indigo-500 focus:border-indigo-500 sm:text-sm"
+              placeholder="New password (at least 10 characters with letters and digits)"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+            />
+            <input
+              type="password"
+              autoComplete="new-password"
+              required
+              className="appearance-none rounded-md block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 focus:outline-none focus:ring-indigo-500 focus:border-indigo-500 sm:text-sm"
+              placeholder="Repeat password"
+              value={confirm}
+              onChange={(e) => setConfirm(e.target.value)}
+            />
+            {error && <div className="text-red-500 text-sm text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full py-2 px-4 text-sm font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700"
+            >
+              Set password
+            </button>
+          </form>
+        )}
+        <div className="text-center text-sm">
+          <Link to="/login" className="text-indigo-600 hover:text-indigo-500">Back to sign in</Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ResetPasswordForm;
@@ -0,0 +1,72 @@
+// frontend/src/components/Auth/SsoCallback.tsx
+import React, { useEffect, useRef, useState } from 'react';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
+import { getApiUrl } from '../../config/api';
+import { saveTokens } from '../../config/auth';
+
+// The API sends the browser here after single sign-on, with a one-time code
+// to exchange for tokens or with an error. Accounts with two-factor
+// authentication continue on the sign-in form.
+const SsoCallback = () => {
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState(searchParams.get('error') || '');
+  // The code works once, so it must not be exchanged again when the effect
+  // runs twice in development
+  const exchanged = useRef(false);
+  const navigate = useNavigate();
+
+  useEffect(() => {
+    const code = searchParams.get('code');
+    if (!code) {
+      setError((current) => current || 'Single sign-on failed.');
+      return;
+    }
+    if (exchanged.current) {
+      return;
+    }
+    exchanged.current = true;
+
+    const exchange = async () => {
+      try {
+        const response = await fetch(getApiUrl('/api/auth/oidc/exchange'), {
+          method: 'POST',
+          headers: {
+            'Content-Type': 'application/json',
+          },
+          body: JSON.stringify({ code }),
+        });
+        const data = await response.json();
+        if (!response.ok) {
+          setError(data.error || 'Single sign-on failed.');
+          return;
+        }
+        if (data.challenge_token) {
+          // The second factor is asked for by the sign-in form
+          navigate('/login', { replace: true, state: { loginResult: data } });
+          return;
+        }
+        saveTokens(data);
+        navigate('/properties', { replace: true });
+      } catch (err) {
+        setError('Single sign-on failed.');
+      }
+    };
+    exchange();
+  }, [searchParams, navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-6 text-center">
+        <h2 className="text-2xl font-extrabold text-gray-900">Single sign-on</h2>
+        {error ? (
+          <p className="text-sm text-red-500">{error}</p>
+        ) : (
+          <p className="text-sm text-gray-600">Signing in...</p>
+        )}
+        {error && <Link to="/login" className="text-sm text-indigo-600 hover:text-indigo-500">Back to sign in</Link>}
+      </div>
+    </div>
+  );
+};
+
+export default SsoCallback;
@@ -0,0 +1,43 @@
+// frontend/src/components/Auth/VerifyEmail.tsx
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { getApiUrl } from '../../config/api';
+
+const VerifyEmail = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState<'pending' | 'verified' | 'failed'>('pending');
+
+  useEffect(() => {
+    const verify = async () => {
+      try {
+        const response = await fetch(getApiUrl('/api/auth/verify-email'), {
+          method: 'POST',
+          headers: {
+            'Content-Type': 'application/json',
+          },
+          body: JSON.stringify({ token: searchParams.get('token') || '' }),
+        });
+        setStatus(response.ok ? 'verified' : 'failed');
+      } catch (err) {
+        setStatus('failed');
+      }
+    };
+    verify();
+  }, [searchParams]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-6 text-center">
+        <h2 className="text-2xl font-extrabold text-gray-900">Email verification</h2>
+        {status === 'pending' && <p className="text-sm text-gray-600">Verifying...</p>}
+        {status === 'verified' && <p className="text-sm text-gray-600">Your email address is verified. You can sign in now.</p>}
+        {status === 'failed' && (
+          <p className="text-sm text-red-500">This link is invalid or has expired. Sign in to request a new one.</p>
+        )}
+        <Link to="/login" className="text-sm text-indigo-600 hover:text-indigo-500">Go to sign in</Link>
+      </div>
+    </div>
+  );
+};
+
+export default VerifyEmail;
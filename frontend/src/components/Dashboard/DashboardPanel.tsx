@@ -1,29 +1,78 @@
-import React, { useEffect, useState } from 'react';
-import { Property } from '../../types';
+import React, { useEffect, useRef, useState } from 'react';
+import { ExportJob, LifecycleState, Property, PropertyPage } from '../../types';
 import { getApiUrl } from '../../config/api';
+import { authFetch } from '../../config/auth';
+
+// Mirrors lifecycleTransitions in backend/internal/services/lifecycle.go.
+// The backend also checks the deal type and the user's role.
+const TRANSITIONS: Record<LifecycleState, { to: LifecycleState; label: string; needsReason?: boolean }[]> = {
+    draft: [
+        { to: 'published', label: 'Publish' },
+        { to: 'archived', label: 'Archive' },
+    ],
+    published: [
+        { to: 'reserved', label: 'Reserve' },
+        { to: 'sold', label: 'Mark sold' },
+        { to: 'rented', label: 'Mark rented' },
+        { to: 'draft', label: 'Unpublish' },
+        { to: 'archived', label: 'Archive' },
+    ],
+    reserved: [
+        { to: 'sold', label: 'Mark sold' },
+        { to: 'rented', label: 'Mark rented' },
+        { to: 'published', label: 'Cancel reservation', needsReason: true },
+        { to: 'archived', label: 'Archive' },
+    ],
+    sold: [
+        { to: 'archived', label: 'Archive' },
+        { to: 'published', label: 'Reopen', needsReason: true },
+    ],
+    rented: [
+        { to: 'archived', label: 'Archive' },
+        { to: 'published', label: 'Reopen', needsReason: true },
+    ],
+    archived: [
+        { to: 'draft', label: 'Back to draft', needsReason: true },
+        { to: 'published', label: 'Republish', needsReason: true },
+    ],
+};
+
+const STATE_STYLES: Record<LifecycleState, string> = {
+    draft: 'bg-gray-100 text-gray-800',
+    published: 'bg-green-100 text-green-800',
+    reserved: 'bg-yellow-100 text-yellow-800',
+    sold: 'bg-blue-100 text-blue-800',
+    rented: 'bg-blue-100 text-blue-800',
+    archived: 'bg-red-100 text-red-800',
+};
 
 const DashboardPanel = () => {
     const [properties, setProperties] = useState<Property[]>([]);
     const [selectedProperties, setSelectedProperties] = useState<Set<number>>(new Set());
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState('');
+    const [exportJob, setExportJob] = useState<ExportJob | null>(null);
+    const pollTimer = useRef<number>();
 
     useEffect(() => {
         fetchProperties();
+        return () => window.clearTimeout(pollTimer.current);
     }, []);
 
     const fetchProperties = async () => {
         setLoading(true);
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(getApiUrl('/api/properties'), {
-                headers: {
-                    'Authorization': `Bearer ${token}`,
-                },
-            });
-            if (!response.ok) throw new Error('Failed to fetch properties');
-            const data = await response.json();
-            setProperties(data);
+            // Панель показывает все объекты, поэтому проходим по всем страницам
+            const all: Property[] = [];
+            let next: string | null = '/api/properties?per_page=100';
+            while (next) {
+                const response = await authFetch(getApiUrl(next));
+                if (!response.ok) throw new Error('Failed to fetch properties');
+                const data: PropertyPage = await response.json();
+                all.push(...data.items);
+                next = data.next;
+            }
+            setProperties(all);
         } catch (err) {
             setError('Failed to load properties');
         } finally {
@@ -48,43 +97,96 @@ const DashboardPanel = () => {
         }
 
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(getApiUrl('/api/properties/export'), {
+            const response = await authFetch(getApiUrl('/api/properties/export'), {
                 method: 'POST',
                 headers: {
-                    'Authorization': `Bearer ${token}`,
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify({
                     property_ids: Array.from(selectedProperties)
                 })
             });
-
-            const blob = await response.blob();
-            const url = window.URL.createObjectURL(blob);
-            const a = document.createElement('a');
-            a.href = url;
-            a.download = 'properties_export.zip';
-            document.body.appendChild(a);
-            a.click();
-            window.URL.revokeObjectURL(url);
+            const data = await response.json().catch(() => ({}));
+            if (response.status === 429) {
+                setError('You already have exports running; wait for them to finish or cancel one');
+                return;
+            }
+            if (!response.ok) {
+                setError(data.error || 'Export failed');
+                return;
+            }
+            setError('');
+            setExportJob(data);
+            pollExport(data.id);
         } catch (err) {
             setError('Export failed');
         }
     };
 
-    const togglePropertyStatus = async (id: number, isActive: boolean) => {
+    // Экспорт выполняется в фоне: опрашиваем статус до завершения
+    const pollExport = (id: string) => {
+        pollTimer.current = window.setTimeout(async () => {
+            try {
+                const response = await authFetch(getApiUrl(`/api/exports/${id}`));
+                if (!response.ok) throw new Error('Failed to fetch export');
+                const job: ExportJob = await response.json();
+                setExportJob(job);
+                if (job.status === 'queued' || job.status === 'running') {
+                    pollExport(id);
+                } else if (job.status === 'completed' && job.download_url) {
+                    window.location.href = getApiUrl(job.download_url);
+                }
+            } catch (err) {
+                setError('Failed to fetch export progress');
+            }
+        }, 2000);
+    };
+
+    const cancelExport = async () => {
+        if (!exportJob) return;
+        window.clearTimeout(pollTimer.current);
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(getApiUrl('/api/properties/${id}/status'), {
-                method: 'PUT',
+            const response = await authFetch(getApiUrl(`/api/exports/${exportJob.id}/cancel`), { method: 'POST' });
+            const data = await response.json().catch(() => ({}));
+            if (!response.ok) {
+                setError(data.error || 'Failed to cancel export');
+                pollExport(exportJob.id);
+                return;
+            }
+            setExportJob(data);
+        } catch (err) {
+            setError('Failed to cancel export');
+        }
+    };
+
+    const exportRunning = exportJob !== null && (exportJob.status === 'queued' || exportJob.status === 'running');
+
+    const transitionProperty = async (property: Property, to: LifecycleState, needsReason?: boolean) => {
+        let reason = '';
+        if (needsReason) {
+            const answer = window.prompt('Reason for this change');
+            if (!answer || !answer.trim()) return;
+            reason = answer.trim();
+        }
+
+        try {
+            const response = await authFetch(getApiUrl(`/api/properties/${property.id}/transitions`), {
+                method: 'POST',
                 headers: {
-                    'Authorization': `Bearer ${token}`,
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({ is_active: isActive }),
+                body: JSON.stringify({ state: to, reason }),
             });
-            if (!response.ok) throw new Error('Failed to update status');
+            if (response.status === 403) {
+                setError('You do not have permission to change this property');
+                return;
+            }
+            if (!response.ok) {
+                const data = await response.json().catch(() => ({}));
+                setError(data.error || 'Failed to update property status');
+                return;
+            }
+            setError('');
             fetchProperties();
         } catch (err) {
             setError('Failed to update property status');
@@ -104,7 +206,7 @@ const DashboardPanel = () => {
                     </button>
                     <button
                         onClick={exportSelected}
-                        disabled={selectedProperties.size === 0}
+                        disabled={selectedProperties.size === 0 || exportRunning}
                         className="px-4 py-2 bg-blue-500 text-white rounded hover:bg-blue-600 disabled:bg-gray-400"
                     >
                         Export Selected ({selectedProperties.size})
@@ -112,6 +214,37 @@ const DashboardPanel = () => {
                 </div>
             </div>
 
+            {exportJob && (
+                <div className="bg-blue-50 border border-blue-200 text-blue-800 px-4 py-3 rounded mb-4 flex justify-between items-center">
+                    <span>
+                        {exportJob.status === 'queued' && 'Export queued...'}
+                        {exportJob.status === 'running' && `Exporting ${exportJob.processed} of ${exportJob.total} properties...`}
+                        {exportJob.status === 'completed' && (
+                            <>
+                                Export ready.{' '}
+                                <a href={getApiUrl(exportJob.download_url || '')} className="underline">Download</a>
+                                {exportJob.skipped > 0 && (
+                                    <span title={exportJob.warnings.join('\n')}>
+                                        {' '}({exportJob.skipped} files could not be read and were left out)
+                                    </span>
+                                )}
+                            </>
+                        )}
+                        {exportJob.status === 'failed' && `Export failed: ${exportJob.error || 'unknown error'}`}
+                        {exportJob.status === 'canceled' && 'Export canceled'}
+                    </span>
+                    {exportRunning ? (
+                        <button onClick={cancelExport} className="px-3 py-1 bg-red-500 text-white rounded hover:bg-red-600">
+                            Cancel
+                        </button>
+                    ) : (
+                        <button onClick={() => setExportJob(null)} className="px-3 py-1 text-blue-800 hover:underline">
+                            Dismiss
+                        </button>
+                    )}
+                </div>
+            )}
+
             {error && (
                 <div className="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">
                     {error}
@@ -172,27 +305,32 @@ const DashboardPanel = () => {
                                         €{property.details[0]?.price.toLocaleString()}
                                     </td>
                                     <td className="px-6 py-4 whitespace-nowrap">
-                                        <span className={`px-2 inline-flex text-xs leading-5 font-semibold rounded-full ${property.is_active
-                                            ? 'bg-green-100 text-green-800'
-                                            : 'bg-red-100 text-red-800'
-                                            }`}>
-                                            {property.is_active ? 'Active' : 'Inactive'}
+                                        <span className={`px-2 inline-flex text-xs leading-5 font-semibold rounded-full ${STATE_STYLES[property.lifecycle_state || 'draft']}`}>
+                                            {property.lifecycle_state || 'draft'}
                                         </span>
                                     </td>
                                     <td className="px-6 py-4 whitespace-nowrap text-sm font-medium space-x-2">
-                                        <button
-                                            onClick={() => {
-                                                if (property.id !== undefined) {
-                                                    togglePropertyStatus(property.id, !property.is_active);
-                                                }
-                                            }}
-                                            className={`px-3 py-1 rounded ${property.is_active
-                                                ? 'bg-red-100 text-red-600 hover:bg-red-200'
-                                                : 'bg-green-100 text-green-600 hover:bg-green-200'
-                                                }`}
-                                        >
-                                            {property.is_active ? 'Deactivate' : 'Activate'}
-                                        </button>
+                                        {property.id !== undefined && (
+                                            <select
+                                                value=""
+                                                onChange={(e) => {
+                                                    const transition = TRANSITIONS[property.lifecycle_state || 'draft']
+                                                        .find((t) => t.to === e.target.value);
+                                                    if (transition) {
+                                                        transitionProperty(property, transition.to, transition.needsReason);
+                                                    }
+                                                }}
+                                                className="px-2 py-1 rounded border border-gray-300 text-sm"
+                                            >
+                                                <option value="" disabled>Change status…</option>
+                                                {TRANSITIONS[property.lifecycle_state || 'draft']
+                                                    .filter((t) => !(t.to === 'sold' && property.deal_type !== 'sale')
+                                                        && !(t.to === 'rented' && property.deal_type !== 'rent'))
+                                                    .map((t) => (
+                                                        <option key={t.to} value={t.to}>{t.label}</option>
+                                                    ))}
+                                            </select>
+                                        )}
                                         {property.id !== undefined && (
                                             <button
                                                 onClick={() => window.location.href = `/property/edit/${property.id}`}
@@ -1,7 +1,8 @@
 // frontend/src/components/Files/FileUpload.tsx
 import React, { useState, useEffect, useCallback } from 'react';
 import { useTranslation } from '../../localization/translations';
-import { getApiUrl } from '../../config/api';
+import { getApiUrl, API_BASE_URL } from '../../config/api';
+import { authFetch } from '../../config/auth';
 
 
 interface FileUploadProps {
@@ -9,6 +10,27 @@ interface FileUploadProps {
     onUploadComplete: () => void;
 }
 
+// Лимиты совпадают с services.MaxUploadSizes на сервере
+const MAX_FILE_SIZES: Record<string, number> = {
+    image: 20 * 1024 * 1024,
+    video: 100 * 1024 * 1024,
+    document: 25 * 1024 * 1024,
+};
+
+// Коды ошибок, которые возвращает сервер при отклонении файла
+const UPLOAD_ERROR_KEYS = {
+    invalid_file_type: 'uploadErrorInvalidFileType',
+    empty_file: 'uploadErrorEmptyFile',
+    file_too_large: 'uploadErrorFileTooLarge',
+    unsupported_extension: 'uploadErrorUnsupportedExtension',
+    content_type_mismatch: 'uploadErrorContentMismatch',
+    unsupported_image_format: 'uploadErrorUnsupportedImageFormat',
+    executable_content: 'uploadErrorExecutableContent',
+    unreadable_file: 'uploadErrorUnreadableFile',
+} as const;
+
+type UploadErrorCode = keyof typeof UPLOAD_ERROR_KEYS;
+
 interface UploadedFile {
     id: number;
     file_type: string;
@@ -38,12 +60,7 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
             if (!propertyId) return;
 
             try {
-                const token = localStorage.getItem('token');
-                const response = await fetch(getApiUrl('/api/properties/${propertyId}'), {
-                    headers: {
-                        'Authorization': `Bearer ${token}`
-                    }
-                });
+                const response = await authFetch(getApiUrl('/api/properties/${propertyId}'));
 
                 if (!response.ok) {
                     throw new Error('Failed to fetch files');
@@ -78,18 +95,26 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
 
     // 3. Потом все обработчики событий
     const handleFileSelect = (e: React.ChangeEvent<HTMLInputElement>) => {
-        const maxFileSize = 100 * 1024 * 1024;
+        const maxFileSize = MAX_FILE_SIZES[fileType];
         if (e.target.files) {
             const file = e.target.files[0];
             if (file && file.size > maxFileSize) {
-                setError('File size exceeds 100MB limit');
+                setError(t('uploadErrorFileTooLarge'));
                 e.target.value = '';
                 return;
             }
+            setError('');
             setSelectedFiles(e.target.files);
         }
     };
 
+    const uploadErrorMessage = (code: string | undefined, fallback: string) => {
+        if (code && code in UPLOAD_ERROR_KEYS) {
+            return t(UPLOAD_ERROR_KEYS[code as UploadErrorCode]);
+        }
+        return fallback;
+    };
+
 
     const handleUpload = async () => {
         if (!selectedFiles) {
@@ -116,12 +141,8 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
             uploadUrl.searchParams.append('file_type', fileType);
             uploadUrl.searchParams.append('is_public', String(isPublic));
     
-            const token = localStorage.getItem('token');
-            const response = await fetch(uploadUrl.toString(), {
+            const response = await authFetch(uploadUrl.toString(), {
                 method: 'POST',
-                headers: {
-                    'Authorization': `Bearer ${token}`,
-                },
                 body: formData
             });
 
@@ -129,12 +150,13 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
             console.log('Server response:', responseText);
 
             if (!response.ok) {
+                let errorData: { error?: string; code?: string } = {};
                 try {
-                    const errorData = JSON.parse(responseText);
-                    throw new Error(errorData.error || 'Upload failed');
+                    errorData = JSON.parse(responseText);
                 } catch (e) {
                     throw new Error(`Upload failed: ${response.status} ${response.statusText}`);
                 }
+                throw new Error(uploadErrorMessage(errorData.code, errorData.error || 'Upload failed'));
             }
 
             const data = JSON.parse(responseText);
@@ -152,13 +174,11 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
 
     const handleVisibilityToggle = async (fileId: number, newIsPublic: boolean) => {
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(
+            const response = await authFetch(
                 getApiUrl(`/api/properties/${propertyId}/files/${fileId}/visibility`),
                 {
                     method: 'PUT',
                     headers: {
-                        'Authorization': `Bearer ${token}`,
                         'Content-Type': 'application/json'
                     },
                     body: JSON.stringify({ is_public: newIsPublic })
@@ -179,16 +199,28 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
         }
     };
 
+    const handleOpen = async (fileId: number) => {
+        try {
+            const response = await authFetch(getApiUrl(`/api/properties/${propertyId}/files/${fileId}/url`));
+
+            if (!response.ok) {
+                throw new Error('Failed to get file link');
+            }
+
+            // Приватные файлы получают временную подписанную ссылку
+            const data = await response.json();
+            window.open(`${API_BASE_URL}${data.url}`, '_blank');
+        } catch (err) {
+            setError(err instanceof Error ? err.message : 'Failed to open file');
+        }
+    };
+
     const handleDelete = async (fileId: number) => {
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(
+            const response = await authFetch(
                 getApiUrl(`/api/properties/${propertyId}/files/${fileId}`),
                 {
                     method: 'DELETE',
-                    headers: {
-                        'Authorization': `Bearer ${token}`
-                    }
                 }
             );
 
@@ -277,7 +309,10 @@ const FileUpload: React.FC<FileUploadProps> = React.memo(({ propertyId, onUpload
                         {uploadedFiles.map(file => (
                             <div key={file.id} className="flex items-center justify-between p-2 bg-gray-50 rounded">
                                 <div className="flex items-center">
-                                    <span className="text-sm text-gray-600">
+                                    <span
+                                        onClick={() => handleOpen(file.id)}
+                                        className="text-sm text-gray-600 cursor-pointer hover:underline"
+                                    >
                                         {file.file_path.split('/').pop()}
                                     </span>
                                 </div>
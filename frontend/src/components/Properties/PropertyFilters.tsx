@@ -3,6 +3,7 @@ import React from 'react';
 import { PropertyType, DealType } from '../../types';
 
 interface FilterValues {
+  q: string;
   property_type: string;
   deal_type: string;
   city: string;
@@ -12,6 +13,7 @@ interface FilterValues {
   rooms_max: string;
   area_min: string;
   area_max: string;
+  sort: string;
 }
 
 interface PropertyFiltersProps {
@@ -27,6 +29,33 @@ const PropertyFilters: React.FC<PropertyFiltersProps> = ({ filters, onChange })
 
   return (
     <div className="bg-white p-4 rounded-lg shadow mb-6">
+      <div className="mb-4 flex gap-4">
+        <input
+          type="search"
+          name="q"
+          value={filters.q}
+          onChange={handleChange}
+          placeholder="Search by address, district, description or property code"
+          className="flex-1 rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500"
+        />
+        <select
+          name="sort"
+          value={filters.sort}
+          onChange={handleChange}
+          className="rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500"
+        >
+          <option value="">{filters.q ? 'Best match' : 'Newest first'}</option>
+          <option value="created_at">Oldest first</option>
+          <option value="-updated_at">Recently updated</option>
+          <option value="price">Price: low to high</option>
+          <option value="-price">Price: high to low</option>
+          <option value="price_per_m2">Price per m²: low to high</option>
+          <option value="-price_per_m2">Price per m²: high to low</option>
+          <option value="-area">Largest area</option>
+          <option value="area">Smallest area</option>
+        </select>
+      </div>
+
       <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-4 gap-4">
         <div>
           <label className="block text-sm font-medium text-gray-700 mb-1">
@@ -4,6 +4,8 @@ import { useParams, useNavigate } from 'react-router-dom';
 import { useTranslation } from '../../localization/translations';
 import FileUpload from '../Files/FileUpload';
 import { getApiUrl } from '../../config/api';
+import { authFetch } from '../../config/auth';
+import { mergeProperty, resolveConflicts, MergeConflict } from './mergeProperty';
 import {
     Property,
     PropertyType,
@@ -25,6 +27,15 @@ const PropertyForm = () => {
     const t = useTranslation(activeLanguage);
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState('');
+    // Версия с сервера, на которой основаны правки, и ее ETag для If-Match
+    const [base, setBase] = useState<Partial<Property> | null>(null);
+    const [etag, setEtag] = useState<string | null>(null);
+    const [conflict, setConflict] = useState<{
+        current: Property;
+        merged: Partial<Property>;
+        conflicts: MergeConflict[];
+        keepMine: Set<string>;
+    } | null>(null);
 
     const [property, setProperty] = useState<Partial<Property>>({
         property_type: 'apartment' as PropertyType,
@@ -114,15 +125,13 @@ const PropertyForm = () => {
 
     const fetchProperty = async () => {
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(getApiUrl('/api/properties/${id}'), {
-                headers: {
-                    'Authorization': `Bearer ${token}`
-                }
-            });
+            const response = await authFetch(getApiUrl(`/api/properties/${id}`));
             if (!response.ok) throw new Error('Failed to fetch property');
             const data = await response.json();
             setProperty(data);
+            setBase(data);
+            setEtag(response.headers.get('ETag'));
+            setConflict(null);
         } catch (err) {
             setError('Failed to load property');
         }
@@ -138,19 +147,28 @@ const PropertyForm = () => {
         setLoading(true);
         setError('');
         try {
-            const token = localStorage.getItem('token');
-            const response = await fetch(
+            const response = await authFetch(
                 getApiUrl(isEditing ? `/api/properties/${id}` : '/api/properties'),
                 {
                     method: isEditing ? 'PUT' : 'POST',
                     headers: {
-                        'Authorization': `Bearer ${token}`,
                         'Content-Type': 'application/json',
+                        ...(isEditing && etag && { 'If-Match': etag }),
                     },
                     body: JSON.stringify(property),
                 }
             );
 
+            if (response.status === 409) {
+                // Объект изменили параллельно: объединяем правки с текущей версией
+                const data = await response.json();
+                const { merged, conflicts } = mergeProperty(base || {}, property, data.current);
+                setConflict({ current: data.current, merged, conflicts, keepMine: new Set() });
+                return;
+            }
+            if (response.status === 403) {
+                throw new Error('You do not have permission to edit this property');
+            }
             if (!response.ok) {
                 throw new Error('Failed to save property');
             }
@@ -158,8 +176,10 @@ const PropertyForm = () => {
             const savedProperty = await response.json();
             if (!isEditing) {
                 setTempId(savedProperty.id);
-                setProperty(savedProperty);
             }
+            setProperty(savedProperty);
+            setBase(savedProperty);
+            setEtag(response.headers.get('ETag'));
 
             // Показываем сообщение об успехе
             // и возможность загрузить файлы
@@ -172,6 +192,31 @@ const PropertyForm = () => {
     };
 
 
+    const toggleKeepMine = (key: string) => {
+        setConflict(prev => {
+            if (!prev) return prev;
+            const keepMine = new Set(prev.keepMine);
+            if (keepMine.has(key)) {
+                keepMine.delete(key);
+            } else {
+                keepMine.add(key);
+            }
+            return { ...prev, keepMine };
+        });
+    };
+
+    // Продолжаем редактирование поверх текущей версии сервера
+    const applyMerge = () => {
+        if (!conflict) return;
+        setProperty(resolveConflicts(conflict.merged, conflict.conflicts, conflict.keepMine));
+        setBase(conflict.current);
+        setEtag(`"${conflict.current.version}"`);
+        setConflict(null);
+    };
+
+    const formatValue = (value: unknown) =>
+        value === null || value === undefined || value === '' ? '—' : typeof value === 'object' ? JSON.stringify(value) : String(value);
+
     // Исправим типизацию updateOwner
     const updateOwner = (field: keyof PropertyOwner, value: any) => {
         const processedValue = field === 'contract_end_date' ? formatDateForAPI(value) : value;
@@ -196,6 +241,48 @@ const PropertyForm = () => {
                         {error}
                     </div>
                 )}
+                {conflict && (
+                    <div className="bg-yellow-50 border border-yellow-400 text-yellow-900 px-4 py-3 rounded mb-4 space-y-3">
+                        <p>{t('editConflict')}</p>
+                        {conflict.conflicts.length > 0 && (
+                            <>
+                                <p>{t('chooseConflicts')}</p>
+                                <table className="min-w-full text-sm">
+                                    <tbody>
+                                        {conflict.conflicts.map(c => {
+                                            const key = c.path.join('.');
+                                            const mine = conflict.keepMine.has(key);
+                                            return (
+                                                <tr key={key}>
+                                                    <td className="pr-4 py-1 font-mono">{key}</td>
+                                                    <td className="pr-4 py-1">
+                                                        <label className="inline-flex items-center gap-1">
+                                                            <input type="radio" checked={mine} onChange={() => !mine && toggleKeepMine(key)} />
+                                                            {t('yourValue')}: {formatValue(c.mine)}
+                                                        </label>
+                                                    </td>
+                                                    <td className="py-1">
+                                                        <label className="inline-flex items-center gap-1">
+                                                            <input type="radio" checked={!mine} onChange={() => mine && toggleKeepMine(key)} />
+                                                            {t('theirValue')}: {formatValue(c.theirs)}
+                                                        </label>
+                                                    </td>
+                                                </tr>
+                                            );
+                                        })}
+                                    </tbody>
+                                </table>
+                            </>
+                        )}
+                        <button
+                            type="button"
+                            onClick={applyMerge}
+                            className="px-4 py-2 rounded-md text-sm font-medium text-white bg-yellow-600 hover:bg-yellow-700"
+                        >
+                            {t('applyMerge')}
+                        </button>
+                    </div>
+                )}
                 <div className="mb-6 flex justify-between items-center">
                     <h1 className="text-3xl font-bold">
                         {isEditing ? t('editProperty') : t('addProperty')}
@@ -263,6 +350,40 @@ const PropertyForm = () => {
                                     <option value="rent">{t('rent')}</option>
                                 </select>
                             </div>
+
+                            <div>
+                                <label className="block text-sm font-medium text-gray-700">Latitude</label>
+                                <input
+                                    type="number"
+                                    step="any"
+                                    min={-90}
+                                    max={90}
+                                    value={property.latitude ?? ''}
+                                    onChange={e => setProperty(prev => ({
+                                        ...prev,
+                                        latitude: e.target.value === '' ? null : Number(e.target.value),
+                                        location_source: 'manual'
+                                    }))}
+                                    className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500"
+                                />
+                            </div>
+
+                            <div>
+                                <label className="block text-sm font-medium text-gray-700">Longitude</label>
+                                <input
+                                    type="number"
+                                    step="any"
+                                    min={-180}
+                                    max={180}
+                                    value={property.longitude ?? ''}
+                                    onChange={e => setProperty(prev => ({
+                                        ...prev,
+                                        longitude: e.target.value === '' ? null : Number(e.target.value),
+                                        location_source: 'manual'
+                                    }))}
+                                    className="mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500"
+                                />
+                            </div>
                         </div>
                     </div>
 
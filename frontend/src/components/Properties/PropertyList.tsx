@@ -1,19 +1,31 @@
 import React, { useEffect, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
-import { Property } from '../../types';
+import { Property, PropertyPage } from '../../types';
 import PropertyFilters from './PropertyFilters';
 import PropertyDetail from './PropertyDetail';
 import { useTranslation } from '../../localization/translations';
 import { getApiUrl, getFileUrl } from '../../config/api';
+import { authFetch, logout, logoutAllSessions } from '../../config/auth';
+
+// Сервер выделяет совпадения тегами <mark>; рендерим их без dangerouslySetInnerHTML
+const renderHighlight = (highlight: string) =>
+    highlight.split(/(<mark>.*?<\/mark>)/g).map((part, i) =>
+        part.startsWith('<mark>')
+            ? <mark key={i}>{part.slice(6, -7)}</mark>
+            : <React.Fragment key={i}>{part}</React.Fragment>
+    );
 
 const PropertyList = () => {
     const [properties, setProperties] = useState<Property[]>([]);
+    const [page, setPage] = useState(1);
+    const [pageInfo, setPageInfo] = useState<PropertyPage | null>(null);
     const [selectedProperty, setSelectedProperty] = useState<Property | null>(null);
     const [language, setLanguage] = useState('ru');
     const t = useTranslation(language);
     const [error, setError] = useState('');
     const navigate = useNavigate();
     const [filters, setFilters] = useState({
+        q: '',
         property_type: '',
         deal_type: '',
         city: '',
@@ -23,16 +35,25 @@ const PropertyList = () => {
         rooms_max: '',
         area_min: '',
         area_max: '',
+        sort: '',
     });
 
     useEffect(() => {
         fetchProperties();
-    }, [language, filters]);
+    }, [language, filters, page]);
+
+    const handleFiltersChange = (newFilters: typeof filters) => {
+        setFilters(newFilters);
+        setPage(1);
+    };
 
     const fetchProperties = async () => {
         try {
             const params = new URLSearchParams({
                 language,
+                page: String(page),
+                ...(filters.sort && { sort: filters.sort }),
+                ...(filters.q && { q: filters.q }),
                 ...(filters.property_type && { property_type: filters.property_type }),
                 ...(filters.deal_type && { deal_type: filters.deal_type }),
                 ...(filters.city && { city: filters.city }),
@@ -44,10 +65,8 @@ const PropertyList = () => {
                 ...(filters.area_max && { area_max: filters.area_max }),
             });
 
-            const token = localStorage.getItem('token');
-            const response = await fetch(getApiUrl(`/api/properties?${params.toString()}`), {
+            const response = await authFetch(getApiUrl(`/api/properties?${params.toString()}`), {
                 headers: {
-                    'Authorization': `Bearer ${token}`,
                     'Content-Type': 'application/json',
                 },
             });
@@ -55,16 +74,23 @@ const PropertyList = () => {
             if (!response.ok) {
                 throw new Error('Failed to fetch properties');
             }
-            const data = await response.json();
-            setProperties(data);
+            const data: PropertyPage = await response.json();
+            setProperties(data.items);
+            setPageInfo(data);
         } catch (error) {
             console.error('Error fetching properties:', error);
             setError('Failed to load properties');
         }
     };
 
-    const handleLogout = () => {
-        localStorage.removeItem('token');
+    const handleLogout = async () => {
+        await logout();
+        navigate('/login');
+    };
+
+    const handleLogoutAll = async () => {
+        if (!window.confirm(t('logoutAllConfirm'))) return;
+        await logoutAllSessions();
         navigate('/login');
     };
 
@@ -98,10 +124,16 @@ const PropertyList = () => {
                     >
                         {t('logout')}
                     </button>
+                    <button
+                        onClick={handleLogoutAll}
+                        className="px-4 py-2 border border-red-500 text-red-500 rounded hover:bg-red-50"
+                    >
+                        {t('logoutAll')}
+                    </button>
                 </div>
             </div>
 
-            <PropertyFilters filters={filters} onChange={setFilters} />
+            <PropertyFilters filters={filters} onChange={handleFiltersChange} />
 
             {error && (
                 <div className="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">
@@ -148,6 +180,12 @@ const PropertyList = () => {
                             {property.details[0]?.city}, {property.details[0]?.district}
                         </div>
 
+                        {property.highlight && (
+                            <p className="text-sm text-gray-600 mb-4">
+                                {renderHighlight(property.highlight)}
+                            </p>
+                        )}
+
                         <div className="space-y-2 mb-4">
                             <div className="flex justify-between text-sm">
                                 <span>{t('livingArea')}:</span>
@@ -196,6 +234,28 @@ const PropertyList = () => {
                 ))}
             </div>
 
+            {pageInfo && pageInfo.total_pages > 1 && (
+                <div className="flex justify-center items-center gap-4 mt-8">
+                    <button
+                        onClick={() => setPage(page - 1)}
+                        disabled={!pageInfo.prev}
+                        className="px-4 py-2 border rounded disabled:opacity-50"
+                    >
+                        {t('previousPage')}
+                    </button>
+                    <span className="text-sm text-gray-600">
+                        {pageInfo.page} {t('of')} {pageInfo.total_pages} ({pageInfo.total})
+                    </span>
+                    <button
+                        onClick={() => setPage(page + 1)}
+                        disabled={!pageInfo.next}
+                        className="px-4 py-2 border rounded disabled:opacity-50"
+                    >
+                        {t('nextPage')}
+                    </button>
+                </div>
+            )}
+
             {properties.length === 0 && !error && (
                 <div className="text-center py-12 text-gray-500">
                     {t('noPropertiesFound')}
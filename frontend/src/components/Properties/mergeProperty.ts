@@ -0,0 +1,108 @@
+// frontend/src/components/Properties/mergeProperty.ts
+import { Property } from '../../types';
+
+// Путь к полю: ['price'], ['details', 'en', 'price'] или ['owner', 'contract_number']
+export type FieldPath = string[];
+
+export interface MergeConflict {
+    path: FieldPath;
+    mine: unknown;
+    theirs: unknown;
+}
+
+export interface MergeResult {
+    merged: Partial<Property>;
+    conflicts: MergeConflict[];
+}
+
+// Поля, которые меняет только сервер
+const SERVER_FIELDS = new Set([
+    'id', 'version', 'agent_code', 'property_code', 'created_at', 'updated_at',
+    'created_by', 'assigned_agent_id', 'lifecycle_state', 'is_active', 'state_changed_at',
+    'published_at', 'reserved_at', 'closed_at', 'archived_at', 'documents', 'history',
+]);
+
+const same = (a: unknown, b: unknown) => JSON.stringify(a ?? null) === JSON.stringify(b ?? null);
+
+// Трехстороннее слияние плоского объекта: поле, измененное только одной
+// стороной, берется с этой стороны; измененное обеими по-разному - конфликт,
+// пока не выбрано иное, остается серверное значение
+const mergeFields = (
+    base: Record<string, any>,
+    mine: Record<string, any>,
+    theirs: Record<string, any>,
+    prefix: FieldPath,
+    conflicts: MergeConflict[],
+    skip: Set<string> = new Set(),
+) => {
+    const merged: Record<string, any> = { ...theirs };
+    const keys = new Set([...Object.keys(mine), ...Object.keys(theirs)]);
+    keys.forEach(key => {
+        if (skip.has(key) || same(base[key], mine[key])) return;
+        if (same(base[key], theirs[key]) || same(mine[key], theirs[key])) {
+            merged[key] = mine[key];
+            return;
+        }
+        conflicts.push({ path: [...prefix, key], mine: mine[key], theirs: theirs[key] });
+    });
+    return merged;
+};
+
+// mergeProperty объединяет локальные правки (mine), сделанные поверх base,
+// с текущей версией сервера (theirs). Описания сопоставляются по языку.
+export const mergeProperty = (
+    base: Partial<Property>,
+    mine: Partial<Property>,
+    theirs: Property,
+): MergeResult => {
+    const conflicts: MergeConflict[] = [];
+    const merged = mergeFields(base, mine, theirs, [], conflicts,
+        new Set([...Array.from(SERVER_FIELDS), 'details', 'owner'])) as Partial<Property>;
+
+    const languages = new Set([
+        ...(mine.details || []).map(d => d.language),
+        ...(theirs.details || []).map(d => d.language),
+    ]);
+    merged.details = Array.from(languages).map(language => {
+        const find = (p: Partial<Property>) => (p.details || []).find(d => d.language === language);
+        const theirDetail = find(theirs);
+        const myDetail = find(mine);
+        if (!myDetail) return theirDetail!;
+        if (!theirDetail) return myDetail;
+        return mergeFields(find(base) || {}, myDetail, theirDetail, ['details', language], conflicts,
+            new Set(['id', 'property_id'])) as typeof theirDetail;
+    });
+
+    if (mine.owner || theirs.owner) {
+        merged.owner = mergeFields(base.owner || {}, mine.owner || {}, theirs.owner || {}, ['owner'], conflicts,
+            new Set(['id', 'property_id', 'created_at', 'updated_at'])) as Property['owner'];
+    }
+
+    return { merged, conflicts };
+};
+
+// resolveConflicts записывает в merged значения, выбранные пользователем
+export const resolveConflicts = (
+    merged: Partial<Property>,
+    conflicts: MergeConflict[],
+    keepMine: Set<string>,
+): Partial<Property> => {
+    const result: Partial<Property> = {
+        ...merged,
+        details: merged.details?.map(d => ({ ...d })),
+        owner: merged.owner && { ...merged.owner },
+    };
+    conflicts.forEach(conflict => {
+        if (!keepMine.has(conflict.path.join('.'))) return;
+        const [first, second, third] = conflict.path;
+        if (first === 'details') {
+            const detail = result.details?.find(d => d.language === second);
+            if (detail) (detail as any)[third] = conflict.mine;
+        } else if (first === 'owner') {
+            if (result.owner) (result.owner as any)[second] = conflict.mine;
+        } else {
+            (result as any)[first] = conflict.mine;
+        }
+    });
+    return result;
+};
@@ -0,0 +1,105 @@
+import { getApiUrl } from './api';
+
+// Access tokens live for 15 minutes; the refresh token is rotated on every
+// refresh and can be used only once.
+const TOKEN_KEY = 'token';
+const REFRESH_TOKEN_KEY = 'refresh_token';
+
+export interface TokenPair {
+  token: string;
+  refresh_token: string;
+  expires_at: string;
+}
+
+export const saveTokens = (pair: TokenPair) => {
+  localStorage.setItem(TOKEN_KEY, pair.token);
+  localStorage.setItem(REFRESH_TOKEN_KEY, pair.refresh_token);
+};
+
+export const clearTokens = () => {
+  localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
+// Concurrent requests share one refresh, otherwise the second one would reuse
+// the spent refresh token and revoke the session.
+let refreshing: Promise<boolean> | null = null;
+
+const refreshTokens = (): Promise<boolean> => {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+      if (!refreshToken) {
+        return false;
+      }
+      try {
+        const response = await fetch(getApiUrl('/api/auth/refresh'), {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        if (!response.ok) {
+          clearTokens();
+          return false;
+        }
+        saveTokens(await response.json());
+        return true;
+      } catch {
+        return false;
+      }
+    })().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+const withToken = (init: RequestInit): RequestInit => {
+  const headers = new Headers(init.headers);
+  const token = localStorage.getItem(TOKEN_KEY);
+  if (token) {
+    headers.set('Authorization', `Bearer ${token}`);
+  }
+  return { ...init, headers };
+};
+
+// authFetch is fetch with the access token. On 401 it refreshes the tokens
+// once and retries; if that fails the user is sent to the login page.
+export const authFetch = async (input: string, init: RequestInit = {}): Promise<Response> => {
+  const response = await fetch(input, withToken(init));
+  if (response.status !== 401) {
+    return response;
+  }
+
+  if (!(await refreshTokens())) {
+    clearTokens();
+    window.location.assign('/login');
+    return response;
+  }
+  return fetch(input, withToken(init));
+};
+
+export const logout = async () => {
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (refreshToken) {
+    try {
+      await fetch(getApiUrl('/api/auth/logout'), {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+    } catch (error) {
+      console.error('Logout error:', error);
+    }
+  }
+  clearTokens();
+};
+
+export const logoutAllSessions = async () => {
+  try {
+    await authFetch(getApiUrl('/api/auth/logout-all'), { method: 'POST' });
+  } catch (error) {
+    console.error('Logout error:', error);
+  }
+  clearTokens();
+};
@@ -64,6 +64,8 @@ export const translations = {
         // Auth related
         login: 'Prijava',
         logout: 'Odjava',
+        logoutAll: 'Odjavi sve sesije',
+        logoutAllConfirm: 'Odjaviti se sa svih uređaja?',
 
         // Navigation
         home: 'Početna',
@@ -94,13 +96,28 @@ export const translations = {
         uploading: 'Otpremanje...',
         upload: 'Otpremi',
         uploadedFiles: 'Otpremljeni fajlovi',
+        uploadErrorInvalidFileType: 'Nepoznata vrsta fajla',
+        uploadErrorEmptyFile: 'Fajl je prazan',
+        uploadErrorFileTooLarge: 'Fajl je prevelik (slike do 20 MB, video do 100 MB, dokumenti do 25 MB)',
+        uploadErrorUnsupportedExtension: 'Format fajla nije podržan',
+        uploadErrorContentMismatch: 'Sadržaj fajla ne odgovara izabranoj vrsti',
+        uploadErrorUnsupportedImageFormat: 'HEIC i AVIF slike nisu podržane, sačuvajte ih kao JPEG ili PNG',
+        uploadErrorExecutableContent: 'Izvršni fajlovi i dokumenti sa makroima nisu dozvoljeni',
+        uploadErrorUnreadableFile: 'Fajl nije moguće pročitati',
         public: 'Javno',
         private: 'Privatno',
         finish: 'Završi',
         floor: 'Sprat',
         of: 'od',
         description: 'Opis',
-        noPropertiesFound: 'Nisu pronađene nekretnine koje odgovaraju vašim kriterijumima'
+        previousPage: 'Prethodna',
+        nextPage: 'Sledeća',
+        noPropertiesFound: 'Nisu pronađene nekretnine koje odgovaraju vašim kriterijumima',
+        editConflict: 'Neko drugi je izmenio ovu nekretninu dok ste je uređivali. Izmene koje se ne preklapaju su spojene.',
+        chooseConflicts: 'Izaberite vrednost za svako polje koje ste oboje izmenili:',
+        yourValue: 'Vaše',
+        theirValue: 'Njihovo',
+        applyMerge: 'Primeni i nastavi uređivanje'
     },
     en: {
         // Property types
@@ -165,6 +182,8 @@ export const translations = {
         // Auth related
         login: 'Login',
         logout: 'Logout',
+        logoutAll: 'Log out all sessions',
+        logoutAllConfirm: 'Log out on all devices?',
 
         // Navigation
         home: 'Home',
@@ -195,13 +214,28 @@ export const translations = {
         uploading: 'Uploading...',
         upload: 'Upload',
         uploadedFiles: 'Uploaded Files',
+        uploadErrorInvalidFileType: 'Unknown file type',
+        uploadErrorEmptyFile: 'The file is empty',
+        uploadErrorFileTooLarge: 'The file is too large (images up to 20 MB, videos up to 100 MB, documents up to 25 MB)',
+        uploadErrorUnsupportedExtension: 'This file format is not supported',
+        uploadErrorContentMismatch: 'The file content does not match the selected type',
+        uploadErrorUnsupportedImageFormat: 'HEIC and AVIF images are not supported, save them as JPEG or PNG',
+        uploadErrorExecutableContent: 'Executable files and documents with macros are not allowed',
+        uploadErrorUnreadableFile: 'The file could not be read',
         public: 'Public',
         private: 'Private',
         finish: 'Finish',
         floor: 'Floor',
         of: 'of',
         description: 'Description',
-        noPropertiesFound: 'No properties found matching your criteria'
+        previousPage: 'Previous',
+        nextPage: 'Next',
+        noPropertiesFound: 'No properties found matching your criteria',
+        editConflict: 'Someone else changed this property while you were editing it. Changes that do not overlap have been merged.',
+        chooseConflicts: 'Choose a value for each field you both changed:',
+        yourValue: 'Yours',
+        theirValue: 'Theirs',
+        applyMerge: 'Apply and continue editing'
     },
     ru: {
         // Property types
@@ -266,6 +300,8 @@ export const translations = {
         // Auth related
         login: 'Войти',
         logout: 'Выйти',
+        logoutAll: 'Выйти на всех устройствах',
+        logoutAllConfirm: 'Завершить все сеансы на всех устройствах?',
 
         // Navigation
         home: 'Главная',
@@ -296,13 +332,28 @@ export const translations = {
         uploading: 'Загрузка...',
         upload: 'Загрузить',
         uploadedFiles: 'Загруженные файлы',
+        uploadErrorInvalidFileType: 'Неизвестный тип файла',
+        uploadErrorEmptyFile: 'Файл пустой',
+        uploadErrorFileTooLarge: 'Файл слишком большой (изображения до 20 МБ, видео до 100 МБ, документы до 25 МБ)',
+        uploadErrorUnsupportedExtension: 'Формат файла не поддерживается',
+        uploadErrorContentMismatch: 'Содержимое файла не соответствует выбранному типу',
+        uploadErrorUnsupportedImageFormat: 'Изображения HEIC и AVIF не поддерживаются, сохраните их в JPEG или PNG',
+        uploadErrorExecutableContent: 'Исполняемые файлы и документы с макросами запрещены',
+        uploadErrorUnreadableFile: 'Не удалось прочитать файл',
         public: 'Публичный',
         private: 'Приватный',
         finish: 'Завершить',
         floor: 'Этаж',
         of: 'из',
         description: 'Описание',
-        noPropertiesFound: 'Не найдено объектов по вашим критериям'
+        previousPage: 'Назад',
+        nextPage: 'Вперёд',
+        noPropertiesFound: 'Не найдено объектов по вашим критериям',
+        editConflict: 'Пока вы редактировали объект, его изменил кто-то другой. Непересекающиеся изменения объединены.',
+        chooseConflicts: 'Выберите значение для каждого поля, которое изменили вы оба:',
+        yourValue: 'Ваше',
+        theirValue: 'Их',
+        applyMerge: 'Применить и продолжить'
     }
 };
 
@@ -1,6 +1,7 @@
 export type PropertyType = 'house' | 'apartment' | 'office';
 export type DealType = 'sale' | 'rent';
 export type PropertyStatus = 'ready' | 'new' | 'shared';
+export type LifecycleState = 'draft' | 'published' | 'reserved' | 'sold' | 'rented' | 'archived';
 export type ContractStatus = 'active' | 'pending' | 'expired';
 
 export interface PropertyDetail {
@@ -51,13 +52,60 @@ export interface Property {
     property_type: PropertyType;
     deal_type: DealType;
     status: PropertyStatus;
+    lifecycle_state?: LifecycleState;
     is_active: boolean;
+    state_changed_at?: string | null;
+    published_at?: string | null;
+    reserved_at?: string | null;
+    closed_at?: string | null;
+    archived_at?: string | null;
+    version?: number;
+    latitude?: number | null;
+    longitude?: number | null;
+    location_source?: 'manual' | 'gazetteer' | null;
+    created_by?: number | null;
+    assigned_agent_id?: number | null;
     details: PropertyDetail[];
     owner?: PropertyOwner;
     documents?: any[];
     created_at?: string;
     updated_at?: string;
+    search_rank?: number;
+    highlight?: string;
+    distance?: number;
 }
+
+export interface PropertyPage {
+    items: Property[];
+    total: number;
+    page: number;
+    per_page: number;
+    total_pages: number;
+    next: string | null;
+    prev: string | null;
+}
+export type ExportJobStatus = 'queued' | 'running' | 'completed' | 'failed' | 'canceled';
+
+// Background export, see backend/internal/handlers/export.go
+export interface ExportJob {
+    id: string;
+    status: ExportJobStatus;
+    language: string;
+    total: number;
+    processed: number;
+    // Files and histories left out because they could not be read
+    skipped: number;
+    warnings: string[];
+    result_size: number;
+    error?: string;
+    created_at: string;
+    started_at: string | null;
+    finished_at: string | null;
+    expires_at: string | null;
+    download_url?: string;
+    download_expires_at?: string;
+}
+
 export interface ProtectedRouteProps {
     children: React.ReactNode;
 }
\ No newline at end of file